@@ -40,6 +40,8 @@ func main() {
 		logger.Error(err, "Failed to create vLLM simulator")
 		return
 	}
+	// reload the configuration on SIGHUP
+	signals.SetupReloadHandler(ctx, vllmSim.Reload)
 	if err := vllmSim.Start(ctx); err != nil {
 		logger.Error(err, "vLLM simulator failed")
 	}
@@ -43,3 +43,20 @@ func SetupSignalHandler(parentCtx context.Context) context.Context {
 
 	return ctx
 }
+
+// SetupReloadHandler calls reload on each SIGHUP until the context is cancelled
+func SetupReloadHandler(ctx context.Context, reload func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c:
+				reload()
+			}
+		}
+	}()
+}
@@ -17,11 +17,14 @@ limitations under the License.
 package common
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"math"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -41,12 +44,34 @@ const (
 	FailureTypeServerError    = "server_error"
 	FailureTypeInvalidRequest = "invalid_request"
 	FailureTypeModelNotFound  = "model_not_found"
-	dummy                     = "dummy"
+	// Streaming failure type constants
+	StreamFailureTypeConnectionClose = "connection_close"
+	StreamFailureTypeErrorEvent      = "error_event"
+	StreamFailureTypeStall           = "stall"
+	StreamFailureTypeMalformedChunk  = "malformed_chunk"
+	dummy                            = "dummy"
+	// Rate limit key constants
+	RateLimitByAPIKey = "api-key"
+	RateLimitByModel  = "model"
+	// Scheduling policy constants
+	SchedulingPolicyFCFS     = "fcfs"
+	SchedulingPolicyPriority = "priority"
 )
 
 type Configuration struct {
 	// Port defines on which port the simulator runs
 	Port int `yaml:"port" json:"port"`
+	// FleetSize is the number of simulators to run in one process, each simulator simulates a different
+	// pod and runs on its own port, consecutive ports starting from Port are used, default is 1
+	FleetSize int `yaml:"fleet-size" json:"fleet-size"`
+	// FleetStatusPort is the port of the fleet's aggregated status page, defaults to the port
+	// after the fleet's ports
+	FleetStatusPort int `yaml:"fleet-status-port" json:"fleet-status-port"`
+	// FleetProfilesString is a list of settings of the fleet's simulators as strings
+	FleetProfilesString []string `yaml:"fleet-profiles" json:"fleet-profiles"`
+	// FleetProfiles is a list of settings that override the global settings of the fleet's simulators,
+	// the simulator with index i uses the profile with index i modulo the number of profiles
+	FleetProfiles []ModelOverrides
 	// Model defines the current base model name
 	Model string `yaml:"model" json:"model"`
 	// ServedModelNames is one or many model names exposed by the API
@@ -55,9 +80,29 @@ type Configuration struct {
 	MaxLoras int `yaml:"max-loras" json:"max-loras"`
 	// MaxCPULoras defines maximum number of LoRAs to store in CPU memory
 	MaxCPULoras int `yaml:"max-cpu-loras" json:"max-cpu-loras"`
+	// LoraLoadLatency is the time in milliseconds to load a LoRA adapter from the CPU memory to the GPU,
+	// when a request to a LoRA adapter that is not loaded to the GPU is scheduled
+	LoraLoadLatency int `yaml:"lora-load-latency" json:"lora-load-latency"`
+	// LoraDiskLoadLatency is the additional time in milliseconds to load a LoRA adapter from the disk,
+	// when the LoRA adapter is not in the CPU memory, i.e., it was evicted since more than MaxCPULoras
+	// LoRA adapters were used, it is also the duration of a load LoRA adapter request
+	LoraDiskLoadLatency int `yaml:"lora-disk-load-latency" json:"lora-disk-load-latency"`
+	// ValidateLoraPath defines if the path of a LoRA adapter loaded by a load LoRA adapter request
+	// must be a local directory that contains adapter_config.json
+	ValidateLoraPath bool `yaml:"validate-lora-path" json:"validate-lora-path"`
+	// LoraResolverDir is a local directory used to resolve requests to unknown LoRA adapters, an adapter
+	// is loaded on its first request if the directory contains a subdirectory with the adapter's name
+	// with adapter_config.json, optional
+	LoraResolverDir string `yaml:"lora-resolver-dir" json:"lora-resolver-dir"`
 	// MaxNumSeqs is maximum number of sequences per iteration (the maximum
 	// number of inference requests that could be processed at the same time)
 	MaxNumSeqs int `yaml:"max-num-seqs" json:"max-num-seqs"`
+	// SchedulingPolicy defines the order in which waiting requests are handled, valid values: fcfs (first
+	// come first served) and priority (by the requests' priority and then by their arrival time), defaults to fcfs
+	SchedulingPolicy string `yaml:"scheduling-policy" json:"scheduling-policy"`
+	// PriorityPreemption defines if running requests are preempted in favor of higher priority requests
+	// when all the sequences are busy, can be set only with priority scheduling policy
+	PriorityPreemption bool `yaml:"priority-preemption" json:"priority-preemption"`
 	// MaxModelLen is the model's context window, the maximum number of tokens
 	// in a single request including input and output. Default value is 1024.
 	MaxModelLen int `yaml:"max-model-len" json:"max-model-len"`
@@ -65,6 +110,15 @@ type Configuration struct {
 	LoraModulesString []string `yaml:"lora-modules" json:"lora-modules"`
 	// LoraModules is a list of LoRA adapters
 	LoraModules []LoraModule
+	// ModelProfilesString is a list of served model names' profiles as strings
+	ModelProfilesString []string `yaml:"model-profiles" json:"model-profiles"`
+	// ModelProfiles is a list of profiles of served model names, that override the global latency
+	// and response settings for requests to these names
+	ModelProfiles []ModelProfile
+	// AdditionalModelsString is a list of additional base models as strings
+	AdditionalModelsString []string `yaml:"additional-models" json:"additional-models"`
+	// AdditionalModels is a list of base models that are served in addition to Model
+	AdditionalModels []BaseModel
 
 	// TimeToFirstToken time before the first token will be returned, in milliseconds
 	TimeToFirstToken int `yaml:"time-to-first-token" json:"time-to-first-token"`
@@ -87,6 +141,10 @@ type Configuration struct {
 	// KVCacheTransferLatency
 	KVCacheTransferLatencyStdDev int `yaml:"kv-cache-transfer-latency-std-dev" json:"kv-cache-transfer-latency-std-dev"`
 
+	// TranscriptionLatencyPerSecond is the time to transcribe one second of audio, in milliseconds,
+	// optional, default is 0
+	TranscriptionLatencyPerSecond int `yaml:"transcription-latency-per-second" json:"transcription-latency-per-second"`
+
 	// Mode defines the simulator response generation mode, valid values: echo, random
 	Mode string `yaml:"mode" json:"mode"`
 	// Seed defines random seed for operations
@@ -142,12 +200,167 @@ type Configuration struct {
 	// FakeMetrics is a set of metrics to send to Prometheus instead of the real data
 	FakeMetrics *Metrics `yaml:"fake-metrics" json:"fake-metrics"`
 
+	// ImageTokens is the number of prompt tokens of an image in a chat completion request, or of a
+	// single image tile if ImageTileSize is set, optional, defaults to 576
+	ImageTokens int `yaml:"image-tokens" json:"image-tokens"`
+	// ImageTileSize is the size in pixels of a square image tile, if set, the number of tokens of an
+	// image sent as a data URL is ImageTokens per tile, optional, defaults to 0 (no tiling)
+	ImageTileSize int `yaml:"image-tile-size" json:"image-tile-size"`
+	// AudioTokens is the number of prompt tokens of an audio input, optional, defaults to 200
+	AudioTokens int `yaml:"audio-tokens" json:"audio-tokens"`
+	// VideoTokens is the number of prompt tokens of a video, optional, defaults to 1024
+	VideoTokens int `yaml:"video-tokens" json:"video-tokens"`
+
 	// FailureInjectionRate is the probability (0-100) of injecting failures
 	FailureInjectionRate int `yaml:"failure-injection-rate" json:"failure-injection-rate"`
 	// FailureTypes is a list of specific failure types to inject (empty means all types)
 	FailureTypes []string `yaml:"failure-types" json:"failure-types"`
+	// StreamFailureInjectionRate is the probability (0-100) of injecting failures in the middle
+	// of streamed responses
+	StreamFailureInjectionRate int `yaml:"stream-failure-injection-rate" json:"stream-failure-injection-rate"`
+	// StreamFailureTypes is a list of specific streaming failure types to inject (empty means all types)
+	StreamFailureTypes []string `yaml:"stream-failure-types" json:"stream-failure-types"`
+	// StreamFailureAfterTokens is the number of tokens that are sent before a streaming failure,
+	// 0 means a random number of the response's tokens
+	StreamFailureAfterTokens int `yaml:"stream-failure-after-tokens" json:"stream-failure-after-tokens"`
+	// StreamStallTime is the time in milliseconds a stalled stream is kept open before the
+	// connection is closed
+	StreamStallTime int `yaml:"stream-stall-time" json:"stream-stall-time"`
+
+	// EnableRequestInjection enables forcing failures and latencies on a single request by its
+	// x-sim-* headers
+	EnableRequestInjection bool `yaml:"enable-request-injection" json:"enable-request-injection"`
+
+	// HangInjectionRate is the probability (0-100) of requests that hang without a response
+	HangInjectionRate int `yaml:"hang-injection-rate" json:"hang-injection-rate"`
+	// HangTime is the time in milliseconds a hanging request is kept before its connection is closed
+	HangTime int `yaml:"hang-time" json:"hang-time"`
+	// TTFTSpikeInjectionRate is the probability (0-100) of requests with a spike in their time to first token
+	TTFTSpikeInjectionRate int `yaml:"ttft-spike-injection-rate" json:"ttft-spike-injection-rate"`
+	// TTFTSpikeMultiplier is the multiplier of the time to first token of requests with a spike
+	TTFTSpikeMultiplier float64 `yaml:"ttft-spike-multiplier" json:"ttft-spike-multiplier"`
+	// StutterInjectionRate is the probability (0-100) of responses with long random gaps between tokens
+	StutterInjectionRate int `yaml:"stutter-injection-rate" json:"stutter-injection-rate"`
+	// StutterMaxGap is the maximal time in milliseconds of a gap between tokens in a stuttering response
+	StutterMaxGap int `yaml:"stutter-max-gap" json:"stutter-max-gap"`
+	// SlowDripInjectionRate is the probability (0-100) of responses whose tokens are sent slowly
+	SlowDripInjectionRate int `yaml:"slow-drip-injection-rate" json:"slow-drip-injection-rate"`
+	// SlowDripInterTokenLatency is the time in milliseconds between tokens in a slow drip response
+	SlowDripInterTokenLatency int `yaml:"slow-drip-inter-token-latency" json:"slow-drip-inter-token-latency"`
+
+	// RateLimitRequestsPerMinute is the number of requests allowed per minute for each rate limit key,
+	// 0 means no limit
+	RateLimitRequestsPerMinute int `yaml:"rate-limit-requests-per-minute" json:"rate-limit-requests-per-minute"`
+	// RateLimitTokensPerMinute is the number of tokens (prompt tokens and max completion tokens) allowed
+	// per minute for each rate limit key, 0 means no limit
+	RateLimitTokensPerMinute int `yaml:"rate-limit-tokens-per-minute" json:"rate-limit-tokens-per-minute"`
+	// RateLimitBy defines the key of the rate limits, the API key of the request (api-key)
+	// or the model of the request (model)
+	RateLimitBy string `yaml:"rate-limit-by" json:"rate-limit-by"`
+
+	// APIKeys are the keys that authorize requests to the /v1 API, requests are not authorized if
+	// there are no keys
+	APIKeys []string `yaml:"api-key" json:"-"`
+	// APIKeyFile is the path of a file with keys that authorize requests to the /v1 API, one key per line
+	APIKeyFile string `yaml:"api-key-file" json:"api-key-file"`
+	// fileAPIKeys are the keys read from APIKeyFile
+	fileAPIKeys []string
+
+	// ScenarioFile is the path of a YAML file with a scenario of timed phases that the simulator plays back
+	ScenarioFile string `yaml:"scenario-file" json:"scenario-file"`
+	// ScenarioStart defines when the scenario is played, from the simulator's start (startup)
+	// or when it is started by the admin API (admin)
+	ScenarioStart string `yaml:"scenario-start" json:"scenario-start"`
+	// scenario is the scenario read from ScenarioFile
+	scenario *Scenario
+
+	// StartupDelay is the time in milliseconds after the simulator's start before it is ready,
+	// simulates the loading of the model
+	StartupDelay int `yaml:"startup-delay" json:"startup-delay"`
+	// ReadinessQueueThreshold is the number of waiting requests above which the simulator is not ready,
+	// 0 means no threshold
+	ReadinessQueueThreshold int `yaml:"readiness-queue-threshold" json:"readiness-queue-threshold"`
+	// LivenessFailureInjectionRate is the probability (0-100) of failing liveness checks
+	LivenessFailureInjectionRate int `yaml:"liveness-failure-injection-rate" json:"liveness-failure-injection-rate"`
+	// ShutdownGracePeriod is the time in milliseconds that the simulator waits on shutdown for the running
+	// and waiting requests to finish before it aborts them
+	ShutdownGracePeriod int `yaml:"shutdown-grace-period" json:"shutdown-grace-period"`
+
+	// EnableSleepMode enables the /sleep, /wake_up, /is_sleeping and /reset_prefix_cache endpoints
+	EnableSleepMode bool `yaml:"enable-sleep-mode" json:"enable-sleep-mode"`
+	// WakeUpLatency is the time in milliseconds that waking up from sleep mode takes
+	WakeUpLatency int `yaml:"wake-up-latency" json:"wake-up-latency"`
+
+	// AdminAPIKey is the key that authorizes requests to the admin API, which is enabled only if
+	// the key is set
+	AdminAPIKey string `yaml:"admin-api-key" json:"-"`
+
+	// ConfigFile is the path of the configuration file, set from the config command line parameter
+	ConfigFile string `yaml:"-" json:"config"`
+	// ConfigReloadInterval is the interval in milliseconds of checking whether the configuration file
+	// has changed, the changes that can be applied at runtime are applied, 0 disables the check
+	ConfigReloadInterval int `yaml:"config-reload-interval" json:"config-reload-interval"`
+}
+
+// GetAPIKeys returns the keys that authorize requests to the /v1 API, the keys of the configuration and
+// the keys of the API key file
+func (c *Configuration) GetAPIKeys() []string {
+	return append(slices.Clone(c.APIKeys), c.fileAPIKeys...)
+}
+
+// GetScenario returns the scenario read from the scenario file, nil if there is no scenario file
+func (c *Configuration) GetScenario() *Scenario {
+	return c.scenario
 }
 
+// readAPIKeys reads the API keys in the given file, one key per line, empty lines and lines that
+// start with # are ignored
+func readAPIKeys(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api key file: %w", err)
+	}
+	var keys []string
+	for _, line := range strings.Split(string(data), "\n") {
+		key := strings.TrimSpace(line)
+		if key != "" && !strings.HasPrefix(key, "#") {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// validFailureTypes are the types of failures that can be injected
+var validFailureTypes = map[string]bool{
+	FailureTypeRateLimit:      true,
+	FailureTypeInvalidAPIKey:  true,
+	FailureTypeContextLength:  true,
+	FailureTypeServerError:    true,
+	FailureTypeInvalidRequest: true,
+	FailureTypeModelNotFound:  true,
+}
+
+// StreamFailureTypes are the types of failures that can be injected in the middle of streamed responses
+var StreamFailureTypes = []string{StreamFailureTypeConnectionClose, StreamFailureTypeErrorEvent,
+	StreamFailureTypeStall, StreamFailureTypeMalformedChunk}
+
+// runtimeParams are the parameters that can be changed with the admin API while the simulator is running
+var runtimeParams = []string{
+	"time-to-first-token", "time-to-first-token-std-dev", "inter-token-latency", "inter-token-latency-std-dev",
+	"kv-cache-transfer-latency", "kv-cache-transfer-latency-std-dev", "transcription-latency-per-second",
+	"lora-load-latency", "lora-disk-load-latency", "max-num-seqs", "max-loras", "max-cpu-loras", "mode",
+	"failure-injection-rate", "failure-types", "fake-metrics", "lora-modules", "stream-failure-injection-rate",
+	"stream-failure-types", "stream-failure-after-tokens", "stream-stall-time", "hang-injection-rate", "hang-time",
+	"ttft-spike-injection-rate", "ttft-spike-multiplier", "stutter-injection-rate", "stutter-max-gap",
+	"slow-drip-injection-rate", "slow-drip-inter-token-latency", "rate-limit-requests-per-minute",
+	"rate-limit-tokens-per-minute", "rate-limit-by", "readiness-queue-threshold", "liveness-failure-injection-rate",
+	"shutdown-grace-period", "wake-up-latency",
+}
+
+// reloadIgnoredParams are ignored when the configuration is reloaded: the JSON keys of parsed parameters
+// that duplicate other parameters, and the seed, which is random by default so it changes on every reload
+var reloadIgnoredParams = []string{"lora-modules", "ModelProfiles", "AdditionalModels", "FleetProfiles", "seed"}
+
 type Metrics struct {
 	// LoraMetrics
 	LoraMetrics []LorasMetrics `json:"loras"`
@@ -158,6 +371,46 @@ type Metrics struct {
 	WaitingRequests int64 `yaml:"waiting-requests" json:"waiting-requests"`
 	// KVCacheUsagePercentage  is the fraction of KV-cache blocks currently in use (from 0 to 1)
 	KVCacheUsagePercentage float32 `yaml:"kv-cache-usage" json:"kv-cache-usage"`
+	// RunningRequestsSeries, if set, replaces RunningRequests with a value that changes over time
+	RunningRequestsSeries *MetricSeries `yaml:"running-requests-series" json:"running-requests-series,omitempty"`
+	// WaitingRequestsSeries, if set, replaces WaitingRequests with a value that changes over time
+	WaitingRequestsSeries *MetricSeries `yaml:"waiting-requests-series" json:"waiting-requests-series,omitempty"`
+	// KVCacheUsageSeries, if set, replaces KVCacheUsagePercentage with a value that changes over time
+	KVCacheUsageSeries *MetricSeries `yaml:"kv-cache-usage-series" json:"kv-cache-usage-series,omitempty"`
+	// UpdateInterval is the interval in milliseconds of updating the metrics that change over time,
+	// optional, default is 1000
+	UpdateInterval int `yaml:"update-interval" json:"update-interval,omitempty"`
+}
+
+// HasSeries returns true if any of the fake metrics changes over time
+func (m *Metrics) HasSeries() bool {
+	return m.RunningRequestsSeries != nil || m.WaitingRequestsSeries != nil || m.KVCacheUsageSeries != nil
+}
+
+// ValuesAt returns the fake numbers of running and waiting requests and the KV cache usage
+// at the given time since the fake metrics started
+func (m *Metrics) ValuesAt(elapsed time.Duration) (float64, float64, float64) {
+	running := float64(m.RunningRequests)
+	if m.RunningRequestsSeries != nil {
+		running = math.Round(m.RunningRequestsSeries.ValueAt(elapsed))
+	}
+	waiting := float64(m.WaitingRequests)
+	if m.WaitingRequestsSeries != nil {
+		waiting = math.Round(m.WaitingRequestsSeries.ValueAt(elapsed))
+	}
+	kvCacheUsage := float64(m.KVCacheUsagePercentage)
+	if m.KVCacheUsageSeries != nil {
+		kvCacheUsage = m.KVCacheUsageSeries.ValueAt(elapsed)
+	}
+	return running, waiting, kvCacheUsage
+}
+
+// GetUpdateInterval returns the interval of updating the metrics that change over time
+func (m *Metrics) GetUpdateInterval() time.Duration {
+	if m.UpdateInterval == 0 {
+		return defaultSeriesUpdateInterval * time.Millisecond
+	}
+	return time.Duration(m.UpdateInterval) * time.Millisecond
 }
 
 type LorasMetrics struct {
@@ -176,6 +429,244 @@ type LoraModule struct {
 	Path string `json:"path"`
 	// BaseModelName is the LoRA's base model
 	BaseModelName string `json:"base_model_name"`
+	// ModelOverrides override the global settings for requests to this LoRA
+	ModelOverrides
+}
+
+// BaseModel is a base model that is served in addition to the main model, with its own served model
+// names, LoRAs (LoRAs with its name as base_model_name) and settings
+type BaseModel struct {
+	// Name is the model's name
+	Name string `json:"name"`
+	// ServedModelNames are the model names exposed by the API, defaults to the model's name
+	ServedModelNames []string `json:"served-model-name"`
+	// ModelOverrides override the global settings for requests to this model and its LoRAs
+	ModelOverrides
+}
+
+// ModelProfile overrides the global settings for requests to a served model name
+type ModelProfile struct {
+	// Name is the served model name
+	Name string `json:"name"`
+	// ModelOverrides override the global settings for requests to this served model name
+	ModelOverrides
+}
+
+// ModelOverrides are latency and response settings of a LoRA or a served model name, that override
+// the global settings, settings that are not defined use the global settings
+type ModelOverrides struct {
+	// TimeToFirstToken overrides the time to first token, in milliseconds
+	TimeToFirstToken *int `json:"time-to-first-token,omitempty"`
+	// InterTokenLatency overrides the time between generated tokens, in milliseconds
+	InterTokenLatency *int `json:"inter-token-latency,omitempty"`
+	// Mode overrides the response mode: echo or random
+	Mode string `json:"mode,omitempty"`
+	// MaxModelLen overrides the model's context window
+	MaxModelLen *int `json:"max-model-len,omitempty"`
+	// FailureInjectionRate overrides the probability (0-100) of injecting failures
+	FailureInjectionRate *int `json:"failure-injection-rate,omitempty"`
+}
+
+func (o *ModelOverrides) validate() error {
+	if o.TimeToFirstToken != nil && *o.TimeToFirstToken < 0 {
+		return errors.New("time to first token cannot be negative")
+	}
+	if o.InterTokenLatency != nil && *o.InterTokenLatency < 0 {
+		return errors.New("inter token latency cannot be negative")
+	}
+	if o.Mode != "" && o.Mode != ModeEcho && o.Mode != ModeRandom {
+		return fmt.Errorf("invalid mode '%s'", o.Mode)
+	}
+	if o.MaxModelLen != nil && *o.MaxModelLen < 1 {
+		return errors.New("max model len cannot be less than 1")
+	}
+	if o.FailureInjectionRate != nil && (*o.FailureInjectionRate < 0 || *o.FailureInjectionRate > 100) {
+		return errors.New("failure injection rate should be between 0 and 100")
+	}
+	return nil
+}
+
+// apply returns a copy of the configuration with the overrides applied
+func (o *ModelOverrides) apply(c *Configuration) *Configuration {
+	config := *c
+	if o.TimeToFirstToken != nil {
+		config.TimeToFirstToken = *o.TimeToFirstToken
+	}
+	if o.InterTokenLatency != nil {
+		config.InterTokenLatency = *o.InterTokenLatency
+	}
+	if o.Mode != "" {
+		config.Mode = o.Mode
+	}
+	if o.MaxModelLen != nil {
+		config.MaxModelLen = *o.MaxModelLen
+	}
+	if o.FailureInjectionRate != nil {
+		config.FailureInjectionRate = *o.FailureInjectionRate
+	}
+	return &config
+}
+
+// FleetMemberConfig returns the configuration of the simulator with the given index in the fleet,
+// with its own port and with the settings of its fleet profile, if defined
+func (c *Configuration) FleetMemberConfig(index int) *Configuration {
+	copied := *c
+	config := &copied
+	if len(c.FleetProfiles) > 0 {
+		config = c.FleetProfiles[index%len(c.FleetProfiles)].apply(c)
+	}
+	config.Port = c.Port + index
+	config.FleetSize = 1
+	config.FleetStatusPort = 0
+	return config
+}
+
+// WithRuntimeChanges returns a copy of the configuration with the given changes, a JSON object of
+// parameters that can be changed at runtime and their new values, the copy is validated
+func (c *Configuration) WithRuntimeChanges(changes []byte) (*Configuration, error) {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(changes, &params); err != nil {
+		return nil, err
+	}
+	for param := range params {
+		if !slices.Contains(runtimeParams, param) {
+			return nil, fmt.Errorf("parameter '%s' cannot be changed at runtime", param)
+		}
+	}
+
+	config := *c
+	// lists and fake metrics are replaced, not merged
+	if _, ok := params["failure-types"]; ok {
+		config.FailureTypes = nil
+	}
+	if _, ok := params["stream-failure-types"]; ok {
+		config.StreamFailureTypes = nil
+	}
+	if _, ok := params["fake-metrics"]; ok {
+		config.FakeMetrics = nil
+	}
+	// LoRA adapters are given as JSON objects, not as JSON strings like in the command line
+	if loras, ok := params["lora-modules"]; ok {
+		config.LoraModules = nil
+		if err := json.Unmarshal(loras, &config.LoraModules); err != nil {
+			return nil, err
+		}
+		delete(params, "lora-modules")
+		var err error
+		if changes, err = json.Marshal(params); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(changes, &config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// ReloadChanges compares the configuration with the given reloaded configuration, returns the changed
+// parameters that can be changed at runtime as a JSON object of parameters and their new values (nil
+// if there are none), and the names of the changed parameters that require a restart
+func (c *Configuration) ReloadChanges(reloaded *Configuration) ([]byte, []string, error) {
+	current, err := toJSONMap(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	reloadedParams, err := toJSONMap(reloaded)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := make(map[string]json.RawMessage)
+	var restartParams []string
+	for param, value := range reloadedParams {
+		if bytes.Equal(value, current[param]) || slices.Contains(reloadIgnoredParams, param) {
+			continue
+		}
+		switch {
+		case param == "LoraModules":
+			changes["lora-modules"] = value
+		case slices.Contains(runtimeParams, param):
+			changes[param] = value
+		default:
+			restartParams = append(restartParams, param)
+		}
+	}
+	// the keys are not compared as JSON, since they are omitted from it to keep them out of the logs
+	if !slices.Equal(c.APIKeys, reloaded.APIKeys) {
+		restartParams = append(restartParams, "api-key")
+	}
+	if c.AdminAPIKey != reloaded.AdminAPIKey {
+		restartParams = append(restartParams, "admin-api-key")
+	}
+	slices.Sort(restartParams)
+
+	if len(changes) == 0 {
+		return nil, restartParams, nil
+	}
+	data, err := json.Marshal(changes)
+	return data, restartParams, err
+}
+
+// toJSONMap returns the JSON values of the configuration's parameters
+func toJSONMap(c *Configuration) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var params map[string]json.RawMessage
+	err = json.Unmarshal(data, &params)
+	return params, err
+}
+
+// ModelConfigs returns the configurations of the LoRAs, the served model names that have overrides
+// and the served model names of the additional models, the key is the LoRA's or the served model name
+func (c *Configuration) ModelConfigs() map[string]*Configuration {
+	configs := make(map[string]*Configuration)
+	baseConfigs := map[string]*Configuration{c.Model: c}
+	for _, model := range c.AdditionalModels {
+		config := model.apply(c)
+		config.Model = model.Name
+		config.ServedModelNames = model.ServedModelNames
+		baseConfigs[model.Name] = config
+		for _, name := range model.ServedModelNames {
+			configs[name] = config
+		}
+	}
+	for _, profile := range c.ModelProfiles {
+		base := c
+		if config, ok := configs[profile.Name]; ok {
+			base = config
+		}
+		configs[profile.Name] = profile.apply(base)
+	}
+	for _, lora := range c.LoraModules {
+		base := c
+		if config, ok := baseConfigs[lora.BaseModelName]; ok {
+			base = config
+		}
+		configs[lora.Name] = lora.apply(base)
+	}
+	return configs
+}
+
+// BaseModelNames returns the names of the base models of the served model names of the additional
+// models and of their LoRAs, the name of a base model is its first served model name
+func (c *Configuration) BaseModelNames() map[string]string {
+	names := make(map[string]string)
+	for _, model := range c.AdditionalModels {
+		for _, name := range model.ServedModelNames {
+			names[name] = model.ServedModelNames[0]
+		}
+		for _, lora := range c.LoraModules {
+			if lora.BaseModelName == model.Name {
+				names[lora.Name] = model.ServedModelNames[0]
+			}
+		}
+	}
+	return names
 }
 
 // Needed to parse values that contain multiple strings
@@ -208,6 +699,42 @@ func (c *Configuration) unmarshalLoras() error {
 	return nil
 }
 
+func (c *Configuration) unmarshalModelProfiles() error {
+	c.ModelProfiles = make([]ModelProfile, 0)
+	for _, jsonStr := range c.ModelProfilesString {
+		var profile ModelProfile
+		if err := json.Unmarshal([]byte(jsonStr), &profile); err != nil {
+			return err
+		}
+		c.ModelProfiles = append(c.ModelProfiles, profile)
+	}
+	return nil
+}
+
+func (c *Configuration) unmarshalAdditionalModels() error {
+	c.AdditionalModels = make([]BaseModel, 0)
+	for _, jsonStr := range c.AdditionalModelsString {
+		var model BaseModel
+		if err := json.Unmarshal([]byte(jsonStr), &model); err != nil {
+			return err
+		}
+		c.AdditionalModels = append(c.AdditionalModels, model)
+	}
+	return nil
+}
+
+func (c *Configuration) unmarshalFleetProfiles() error {
+	c.FleetProfiles = make([]ModelOverrides, 0)
+	for _, jsonStr := range c.FleetProfilesString {
+		var profile ModelOverrides
+		if err := json.Unmarshal([]byte(jsonStr), &profile); err != nil {
+			return err
+		}
+		c.FleetProfiles = append(c.FleetProfiles, profile)
+	}
+	return nil
+}
+
 func (c *Configuration) unmarshalFakeMetrics(fakeMetricsString string) error {
 	var metrics *Metrics
 	if err := json.Unmarshal([]byte(fakeMetricsString), &metrics); err != nil {
@@ -231,11 +758,23 @@ func (c *Configuration) unmarshalLoraFakeMetrics() error {
 	return nil
 }
 
-func newConfig() *Configuration {
+// NewDefaultConfig returns a configuration with the default values of all the parameters,
+// the model has to be set before the configuration is used
+func NewDefaultConfig() *Configuration {
 	return &Configuration{
 		Port:                                vLLMDefaultPort,
+		FleetSize:                           1,
+		ConfigReloadInterval:                5000,
+		StreamStallTime:                     60000,
+		HangTime:                            600000,
+		TTFTSpikeMultiplier:                 10,
+		StutterMaxGap:                       5000,
+		SlowDripInterTokenLatency:           1000,
+		RateLimitBy:                         RateLimitByAPIKey,
+		ScenarioStart:                       ScenarioStartStartup,
 		MaxLoras:                            1,
 		MaxNumSeqs:                          5,
+		SchedulingPolicy:                    SchedulingPolicyFCFS,
 		MaxModelLen:                         1024,
 		Mode:                                ModeRandom,
 		Seed:                                time.Now().UnixNano(),
@@ -249,6 +788,9 @@ func newConfig() *Configuration {
 		TokenBlockSize: 16,
 		ZMQEndpoint:    "tcp://localhost:5557",
 		EventBatchSize: 16,
+		ImageTokens:    576,
+		AudioTokens:    200,
+		VideoTokens:    1024,
 	}
 }
 
@@ -265,6 +807,15 @@ func (c *Configuration) load(configFile string) error {
 	if err := c.unmarshalLoras(); err != nil {
 		return err
 	}
+	if err := c.unmarshalModelProfiles(); err != nil {
+		return err
+	}
+	if err := c.unmarshalAdditionalModels(); err != nil {
+		return err
+	}
+	if err := c.unmarshalFleetProfiles(); err != nil {
+		return err
+	}
 	if err := c.unmarshalLoraFakeMetrics(); err != nil {
 		return err
 	}
@@ -272,7 +823,8 @@ func (c *Configuration) load(configFile string) error {
 	return nil
 }
 
-func (c *Configuration) validate() error {
+// Validate validates the configuration and fills in the values derived from other parameters
+func (c *Configuration) Validate() error {
 	if c.Model == "" {
 		return errors.New("model parameter is empty")
 	}
@@ -286,9 +838,26 @@ func (c *Configuration) validate() error {
 	if c.Mode != ModeEcho && c.Mode != ModeRandom {
 		return fmt.Errorf("invalid mode '%s', valid values are 'random' and 'echo'", c.Mode)
 	}
-	if c.Port <= 0 {
+	if c.Port < 0 {
 		return fmt.Errorf("invalid port '%d'", c.Port)
 	}
+	if c.FleetSize < 1 {
+		return errors.New("fleet size cannot be less than 1")
+	}
+	if c.FleetSize > 1 && c.Port == 0 {
+		return errors.New("port must be set when fleet size is more than 1")
+	}
+	if c.FleetSize > 1 && c.FleetStatusPort == 0 {
+		c.FleetStatusPort = c.Port + c.FleetSize
+	}
+	if c.FleetStatusPort < 0 || (c.FleetSize > 1 && c.FleetStatusPort >= c.Port && c.FleetStatusPort < c.Port+c.FleetSize) {
+		return fmt.Errorf("invalid fleet status port '%d'", c.FleetStatusPort)
+	}
+	for i, profile := range c.FleetProfiles {
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("invalid fleet profile %d: %w", i, err)
+		}
+	}
 	if c.InterTokenLatency < 0 {
 		return errors.New("inter token latency cannot be negative")
 	}
@@ -307,6 +876,9 @@ func (c *Configuration) validate() error {
 	if float32(c.TimeToFirstTokenStdDev) > 0.3*float32(c.TimeToFirstToken) {
 		return errors.New("time to first token standard deviation cannot be more than 30% of time to first token")
 	}
+	if c.TranscriptionLatencyPerSecond < 0 {
+		return errors.New("transcription latency per second cannot be negative")
+	}
 	if c.KVCacheTransferLatency < 0 {
 		return errors.New("kv-cache tranfer time cannot be negative")
 	}
@@ -326,17 +898,74 @@ func (c *Configuration) validate() error {
 	if c.MaxCPULoras < c.MaxLoras {
 		return errors.New("max CPU LoRAs cannot be less than max LoRAs")
 	}
+	if c.LoraResolverDir != "" {
+		if info, err := os.Stat(c.LoraResolverDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("LoRA resolver directory '%s' does not exist", c.LoraResolverDir)
+		}
+	}
+	if c.LoraLoadLatency < 0 {
+		return errors.New("LoRA load latency cannot be negative")
+	}
+	if c.ConfigReloadInterval < 0 {
+		return errors.New("config reload interval cannot be negative")
+	}
+	if c.LoraDiskLoadLatency < 0 {
+		return errors.New("LoRA disk load latency cannot be negative")
+	}
+	if c.SchedulingPolicy != SchedulingPolicyFCFS && c.SchedulingPolicy != SchedulingPolicyPriority {
+		return fmt.Errorf("invalid scheduling policy '%s', valid values are '%s' and '%s'", c.SchedulingPolicy,
+			SchedulingPolicyFCFS, SchedulingPolicyPriority)
+	}
+	if c.PriorityPreemption && c.SchedulingPolicy != SchedulingPolicyPriority {
+		return errors.New("priority preemption requires priority scheduling policy")
+	}
 	if c.MaxModelLen < 1 {
 		return errors.New("max model len cannot be less than 1")
 	}
 
+	servedModelNames := slices.Clone(c.ServedModelNames)
+	baseModels := []string{c.Model}
+	for i := range c.AdditionalModels {
+		model := &c.AdditionalModels[i]
+		if model.Name == "" {
+			return errors.New("empty additional model name")
+		}
+		if slices.Contains(baseModels, model.Name) {
+			return fmt.Errorf("duplicate base model '%s'", model.Name)
+		}
+		if len(model.ServedModelNames) == 0 {
+			model.ServedModelNames = []string{model.Name}
+		}
+		for _, name := range model.ServedModelNames {
+			if slices.Contains(servedModelNames, name) {
+				return fmt.Errorf("served model name '%s' of model '%s' is already in use", name, model.Name)
+			}
+			servedModelNames = append(servedModelNames, name)
+		}
+		if err := model.validate(); err != nil {
+			return fmt.Errorf("invalid settings for model '%s': %w", model.Name, err)
+		}
+		baseModels = append(baseModels, model.Name)
+	}
+
 	for _, lora := range c.LoraModules {
 		if lora.Name == "" {
 			return errors.New("empty LoRA name")
 		}
-		if lora.BaseModelName != "" && lora.BaseModelName != c.Model {
+		if lora.BaseModelName != "" && !slices.Contains(baseModels, lora.BaseModelName) {
 			return fmt.Errorf("unknown base model '%s' for LoRA '%s'", lora.BaseModelName, lora.Name)
 		}
+		if err := lora.validate(); err != nil {
+			return fmt.Errorf("invalid settings for LoRA '%s': %w", lora.Name, err)
+		}
+	}
+	for _, profile := range c.ModelProfiles {
+		if !slices.Contains(servedModelNames, profile.Name) {
+			return fmt.Errorf("unknown served model name '%s' in model profiles", profile.Name)
+		}
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("invalid settings for served model name '%s': %w", profile.Name, err)
+		}
 	}
 
 	if c.MaxToolCallIntegerParam < c.MinToolCallIntegerParam {
@@ -370,18 +999,23 @@ func (c *Configuration) validate() error {
 		return errors.New("event batch size cannot less than 1")
 	}
 
+	if c.ImageTokens < 0 {
+		return errors.New("image tokens cannot be negative")
+	}
+	if c.ImageTileSize < 0 {
+		return errors.New("image tile size cannot be negative")
+	}
+	if c.AudioTokens < 0 {
+		return errors.New("audio tokens cannot be negative")
+	}
+	if c.VideoTokens < 0 {
+		return errors.New("video tokens cannot be negative")
+	}
+
 	if c.FailureInjectionRate < 0 || c.FailureInjectionRate > 100 {
 		return errors.New("failure injection rate should be between 0 and 100")
 	}
 
-	validFailureTypes := map[string]bool{
-		FailureTypeRateLimit:      true,
-		FailureTypeInvalidAPIKey:  true,
-		FailureTypeContextLength:  true,
-		FailureTypeServerError:    true,
-		FailureTypeInvalidRequest: true,
-		FailureTypeModelNotFound:  true,
-	}
 	for _, failureType := range c.FailureTypes {
 		if !validFailureTypes[failureType] {
 			return fmt.Errorf("invalid failure type '%s', valid types are: %s, %s, %s, %s, %s, %s", failureType,
@@ -390,6 +1024,103 @@ func (c *Configuration) validate() error {
 		}
 	}
 
+	if c.StreamFailureInjectionRate < 0 || c.StreamFailureInjectionRate > 100 {
+		return errors.New("stream failure injection rate should be between 0 and 100")
+	}
+	for _, failureType := range c.StreamFailureTypes {
+		if !slices.Contains(StreamFailureTypes, failureType) {
+			return fmt.Errorf("invalid stream failure type '%s', valid types are: %s", failureType,
+				strings.Join(StreamFailureTypes, ", "))
+		}
+	}
+	if c.StreamFailureAfterTokens < 0 {
+		return errors.New("stream failure after tokens cannot be negative")
+	}
+	if c.StreamStallTime < 0 {
+		return errors.New("stream stall time cannot be negative")
+	}
+
+	if c.HangInjectionRate < 0 || c.HangInjectionRate > 100 {
+		return errors.New("hang injection rate should be between 0 and 100")
+	}
+	if c.HangTime < 0 {
+		return errors.New("hang time cannot be negative")
+	}
+	if c.TTFTSpikeInjectionRate < 0 || c.TTFTSpikeInjectionRate > 100 {
+		return errors.New("time to first token spike injection rate should be between 0 and 100")
+	}
+	if c.TTFTSpikeMultiplier < 1 {
+		return errors.New("time to first token spike multiplier cannot be less than 1")
+	}
+	if c.StutterInjectionRate < 0 || c.StutterInjectionRate > 100 {
+		return errors.New("stutter injection rate should be between 0 and 100")
+	}
+	if c.StutterMaxGap < 0 {
+		return errors.New("stutter max gap cannot be negative")
+	}
+	if c.SlowDripInjectionRate < 0 || c.SlowDripInjectionRate > 100 {
+		return errors.New("slow drip injection rate should be between 0 and 100")
+	}
+	if c.SlowDripInterTokenLatency < 0 {
+		return errors.New("slow drip inter token latency cannot be negative")
+	}
+
+	if c.RateLimitRequestsPerMinute < 0 {
+		return errors.New("rate limit requests per minute cannot be negative")
+	}
+	if c.RateLimitTokensPerMinute < 0 {
+		return errors.New("rate limit tokens per minute cannot be negative")
+	}
+	if c.RateLimitBy != RateLimitByAPIKey && c.RateLimitBy != RateLimitByModel {
+		return fmt.Errorf("invalid rate limit key '%s', valid values are: %s, %s", c.RateLimitBy,
+			RateLimitByAPIKey, RateLimitByModel)
+	}
+
+	for _, key := range c.APIKeys {
+		if key == "" {
+			return errors.New("api key cannot be empty")
+		}
+	}
+	if c.APIKeyFile != "" {
+		keys, err := readAPIKeys(c.APIKeyFile)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("api key file %s has no keys", c.APIKeyFile)
+		}
+		c.fileAPIKeys = keys
+	}
+
+	if c.ScenarioStart != ScenarioStartStartup && c.ScenarioStart != ScenarioStartAdmin {
+		return fmt.Errorf("invalid scenario start '%s', valid values are: %s, %s", c.ScenarioStart,
+			ScenarioStartStartup, ScenarioStartAdmin)
+	}
+	c.scenario = nil
+	if c.ScenarioFile != "" {
+		scenario, err := LoadScenario(c.ScenarioFile)
+		if err != nil {
+			return err
+		}
+		c.scenario = scenario
+	}
+
+	if c.StartupDelay < 0 {
+		return errors.New("startup delay cannot be negative")
+	}
+	if c.ReadinessQueueThreshold < 0 {
+		return errors.New("readiness queue threshold cannot be negative")
+	}
+	if c.LivenessFailureInjectionRate < 0 || c.LivenessFailureInjectionRate > 100 {
+		return errors.New("liveness failure injection rate should be between 0 and 100")
+	}
+	if c.ShutdownGracePeriod < 0 {
+		return errors.New("shutdown grace period cannot be negative")
+	}
+	if c.WakeUpLatency < 0 {
+		return errors.New("wake up latency cannot be negative")
+	}
+
 	if c.ZMQMaxConnectAttempts > 10 {
 		return errors.New("zmq retries times cannot be more than 10")
 	}
@@ -401,6 +1132,24 @@ func (c *Configuration) validate() error {
 		if c.FakeMetrics.KVCacheUsagePercentage < 0 || c.FakeMetrics.KVCacheUsagePercentage > 1 {
 			return errors.New("fake metrics KV cache usage must be between 0 ans 1")
 		}
+		if c.FakeMetrics.UpdateInterval < 0 {
+			return errors.New("fake metrics update interval cannot be negative")
+		}
+		if series := c.FakeMetrics.RunningRequestsSeries; series != nil {
+			if err := series.validate(0, math.MaxInt64); err != nil {
+				return fmt.Errorf("invalid fake running requests series: %w", err)
+			}
+		}
+		if series := c.FakeMetrics.WaitingRequestsSeries; series != nil {
+			if err := series.validate(0, math.MaxInt64); err != nil {
+				return fmt.Errorf("invalid fake waiting requests series: %w", err)
+			}
+		}
+		if series := c.FakeMetrics.KVCacheUsageSeries; series != nil {
+			if err := series.validate(0, 1); err != nil {
+				return fmt.Errorf("invalid fake KV cache usage series: %w", err)
+			}
+		}
 	}
 	return nil
 }
@@ -408,26 +1157,38 @@ func (c *Configuration) validate() error {
 // ParseCommandParamsAndLoadConfig loads configuration, parses command line parameters, merges the values
 // (command line values overwrite the config file ones), and validates the configuration
 func ParseCommandParamsAndLoadConfig() (*Configuration, error) {
-	config := newConfig()
+	config := NewDefaultConfig()
 
 	configFileValues := getParamValueFromArgs("config")
 	if len(configFileValues) == 1 {
 		if err := config.load(configFileValues[0]); err != nil {
 			return nil, err
 		}
+		config.ConfigFile = configFileValues[0]
 	}
 
 	servedModelNames := getParamValueFromArgs("served-model-name")
 	loraModuleNames := getParamValueFromArgs("lora-modules")
+	modelProfiles := getParamValueFromArgs("model-profiles")
+	additionalModels := getParamValueFromArgs("additional-models")
+	fleetProfiles := getParamValueFromArgs("fleet-profiles")
 	fakeMetrics := getParamValueFromArgs("fake-metrics")
 
 	f := pflag.NewFlagSet("llm-d-inference-sim flags", pflag.ContinueOnError)
 
 	f.IntVar(&config.Port, "port", config.Port, "Port")
+	f.IntVar(&config.FleetSize, "fleet-size", config.FleetSize, "Number of simulators to run in one process, each simulates a different pod on its own port, consecutive ports starting from port are used")
+	f.IntVar(&config.FleetStatusPort, "fleet-status-port", config.FleetStatusPort, "Port of the fleet's aggregated status page, defaults to the port after the fleet's ports")
 	f.StringVar(&config.Model, "model", config.Model, "Currently 'loaded' model")
 	f.IntVar(&config.MaxNumSeqs, "max-num-seqs", config.MaxNumSeqs, "Maximum number of inference requests that could be processed at the same time (parameter to simulate requests waiting queue)")
+	f.StringVar(&config.SchedulingPolicy, "scheduling-policy", config.SchedulingPolicy, "The scheduling policy of waiting requests: fcfs - first come first served; priority - by the requests' priority (lower values first) and then by their arrival time")
+	f.BoolVar(&config.PriorityPreemption, "priority-preemption", config.PriorityPreemption, "Preempt running requests in favor of higher priority requests, requires priority scheduling policy")
 	f.IntVar(&config.MaxLoras, "max-loras", config.MaxLoras, "Maximum number of LoRAs in a single batch")
 	f.IntVar(&config.MaxCPULoras, "max-cpu-loras", config.MaxCPULoras, "Maximum number of LoRAs to store in CPU memory")
+	f.IntVar(&config.LoraLoadLatency, "lora-load-latency", config.LoraLoadLatency, "Time to load a LoRA adapter from CPU memory to GPU (in milliseconds)")
+	f.IntVar(&config.LoraDiskLoadLatency, "lora-disk-load-latency", config.LoraDiskLoadLatency, "Additional time to load a LoRA adapter that is not in CPU memory from disk (in milliseconds)")
+	f.StringVar(&config.LoraResolverDir, "lora-resolver-dir", config.LoraResolverDir, "Local directory to resolve unknown LoRA adapters from, an adapter is loaded on its first request if <dir>/<adapter name>/adapter_config.json exists")
+	f.BoolVar(&config.ValidateLoraPath, "validate-lora-path", config.ValidateLoraPath, "Require the path of a dynamically loaded LoRA adapter to be a local directory containing adapter_config.json")
 	f.IntVar(&config.MaxModelLen, "max-model-len", config.MaxModelLen, "Model's context window, maximum number of tokens in a single request including input and output")
 
 	f.StringVar(&config.Mode, "mode", config.Mode, "Simulator mode: echo - returns the same text that was sent in the request, for chat completion returns the last message; random - returns random sentence from a bank of pre-defined sentences")
@@ -437,6 +1198,7 @@ func ParseCommandParamsAndLoadConfig() (*Configuration, error) {
 	f.IntVar(&config.InterTokenLatencyStdDev, "inter-token-latency-std-dev", config.InterTokenLatencyStdDev, "Standard deviation for time between generated tokens (in milliseconds)")
 	f.IntVar(&config.TimeToFirstTokenStdDev, "time-to-first-token-std-dev", config.TimeToFirstTokenStdDev, "Standard deviation for time before the first token will be returned (in milliseconds)")
 	f.IntVar(&config.KVCacheTransferLatencyStdDev, "kv-cache-transfer-latency-std-dev", config.KVCacheTransferLatencyStdDev, "Standard deviation for time for KV-cache transfer from a remote vLLM (in milliseconds)")
+	f.IntVar(&config.TranscriptionLatencyPerSecond, "transcription-latency-per-second", config.TranscriptionLatencyPerSecond, "Time to transcribe one second of audio (in milliseconds)")
 	f.Int64Var(&config.Seed, "seed", config.Seed, "Random seed for operations (if not set, current Unix time in nanoseconds is used)")
 
 	f.IntVar(&config.MaxToolCallIntegerParam, "max-tool-call-integer-param", config.MaxToolCallIntegerParam, "Maximum possible value of integer parameters in a tool call")
@@ -457,23 +1219,70 @@ func ParseCommandParamsAndLoadConfig() (*Configuration, error) {
 	f.UintVar(&config.ZMQMaxConnectAttempts, "zmq-max-connect-attempts", config.ZMQMaxConnectAttempts, "Maximum number of times to try ZMQ connect")
 	f.IntVar(&config.EventBatchSize, "event-batch-size", config.EventBatchSize, "Maximum number of kv-cache events to be sent together")
 
+	f.IntVar(&config.ImageTokens, "image-tokens", config.ImageTokens, "Number of prompt tokens of an image, or of an image tile if image-tile-size is set")
+	f.IntVar(&config.ImageTileSize, "image-tile-size", config.ImageTileSize, "Size in pixels of a square image tile, the number of tokens of an image sent as a data URL is calculated per tile (0 means no tiling)")
+	f.IntVar(&config.AudioTokens, "audio-tokens", config.AudioTokens, "Number of prompt tokens of an audio input")
+	f.IntVar(&config.VideoTokens, "video-tokens", config.VideoTokens, "Number of prompt tokens of a video")
+
 	f.IntVar(&config.FailureInjectionRate, "failure-injection-rate", config.FailureInjectionRate, "Probability (0-100) of injecting failures")
+	f.IntVar(&config.ConfigReloadInterval, "config-reload-interval", config.ConfigReloadInterval, "Interval in milliseconds of checking whether the configuration file has changed, the changes that can be applied at runtime are applied (0 disables the check)")
+	f.IntVar(&config.RateLimitRequestsPerMinute, "rate-limit-requests-per-minute", config.RateLimitRequestsPerMinute, "Number of requests allowed per minute for each rate limit key, 0 means no limit")
+	f.IntVar(&config.RateLimitTokensPerMinute, "rate-limit-tokens-per-minute", config.RateLimitTokensPerMinute, "Number of tokens (prompt tokens and max completion tokens) allowed per minute for each rate limit key, 0 means no limit")
+	f.StringVar(&config.RateLimitBy, "rate-limit-by", config.RateLimitBy, "Key of the rate limits, api-key (the bearer token of the request) or model")
+	apiKeys := getParamValueFromArgs("api-key")
+	var dummyAPIKeys multiString
+	f.Var(&dummyAPIKeys, "api-key", "List of keys that authorize requests to the /v1 API, requests are not authorized if not set")
+	f.Lookup("api-key").NoOptDefVal = dummy
+	f.StringVar(&config.APIKeyFile, "api-key-file", config.APIKeyFile, "Path of a file with keys that authorize requests to the /v1 API, one key per line")
+	f.StringVar(&config.ScenarioFile, "scenario-file", config.ScenarioFile, "Path of a YAML file with a scenario of timed phases that the simulator plays back")
+	f.StringVar(&config.ScenarioStart, "scenario-start", config.ScenarioStart, "When the scenario is played, startup (from the simulator's start) or admin (when started by the admin API)")
+	f.IntVar(&config.StartupDelay, "startup-delay", config.StartupDelay, "Time in milliseconds after the start before the simulator is ready, simulates the loading of the model")
+	f.IntVar(&config.ReadinessQueueThreshold, "readiness-queue-threshold", config.ReadinessQueueThreshold, "Number of waiting requests above which the simulator is not ready, 0 means no threshold")
+	f.IntVar(&config.LivenessFailureInjectionRate, "liveness-failure-injection-rate", config.LivenessFailureInjectionRate, "Probability (0-100) of failing liveness checks")
+	f.IntVar(&config.ShutdownGracePeriod, "shutdown-grace-period", config.ShutdownGracePeriod, "Time in milliseconds to wait on shutdown for the running and waiting requests to finish before aborting them")
+	f.BoolVar(&config.EnableSleepMode, "enable-sleep-mode", config.EnableSleepMode, "Enables the /sleep, /wake_up, /is_sleeping and /reset_prefix_cache endpoints")
+	f.IntVar(&config.WakeUpLatency, "wake-up-latency", config.WakeUpLatency, "Time in milliseconds that waking up from sleep mode takes")
+	f.StringVar(&config.AdminAPIKey, "admin-api-key", config.AdminAPIKey, "Key of the admin API, which changes the configuration at runtime, the API is disabled if not set")
 
 	failureTypes := getParamValueFromArgs("failure-types")
 	var dummyFailureTypes multiString
 	f.Var(&dummyFailureTypes, "failure-types", "List of specific failure types to inject (rate_limit, invalid_api_key, context_length, server_error, invalid_request, model_not_found)")
 	f.Lookup("failure-types").NoOptDefVal = dummy
 
+	f.IntVar(&config.StreamFailureInjectionRate, "stream-failure-injection-rate", config.StreamFailureInjectionRate, "Probability (0-100) of injecting failures in the middle of streamed responses")
+	streamFailureTypes := getParamValueFromArgs("stream-failure-types")
+	var dummyStreamFailureTypes multiString
+	f.Var(&dummyStreamFailureTypes, "stream-failure-types", "List of specific streaming failure types to inject (connection_close, error_event, stall, malformed_chunk)")
+	f.Lookup("stream-failure-types").NoOptDefVal = dummy
+	f.IntVar(&config.StreamFailureAfterTokens, "stream-failure-after-tokens", config.StreamFailureAfterTokens, "Number of tokens sent before a streaming failure (0 means a random number of the response's tokens)")
+	f.IntVar(&config.StreamStallTime, "stream-stall-time", config.StreamStallTime, "Time in milliseconds a stalled stream is kept open before the connection is closed")
+
+	f.BoolVar(&config.EnableRequestInjection, "enable-request-injection", config.EnableRequestInjection, "Enable forcing failures and latencies on a single request by its x-sim-* headers")
+	f.IntVar(&config.HangInjectionRate, "hang-injection-rate", config.HangInjectionRate, "Probability (0-100) of requests that hang without a response")
+	f.IntVar(&config.HangTime, "hang-time", config.HangTime, "Time in milliseconds a hanging request is kept before its connection is closed")
+	f.IntVar(&config.TTFTSpikeInjectionRate, "ttft-spike-injection-rate", config.TTFTSpikeInjectionRate, "Probability (0-100) of requests with a spike in their time to first token")
+	f.Float64Var(&config.TTFTSpikeMultiplier, "ttft-spike-multiplier", config.TTFTSpikeMultiplier, "Multiplier of the time to first token of requests with a spike")
+	f.IntVar(&config.StutterInjectionRate, "stutter-injection-rate", config.StutterInjectionRate, "Probability (0-100) of responses with long random gaps between tokens")
+	f.IntVar(&config.StutterMaxGap, "stutter-max-gap", config.StutterMaxGap, "Maximal time in milliseconds of a gap between tokens in a stuttering response")
+	f.IntVar(&config.SlowDripInjectionRate, "slow-drip-injection-rate", config.SlowDripInjectionRate, "Probability (0-100) of responses whose tokens are sent slowly")
+	f.IntVar(&config.SlowDripInterTokenLatency, "slow-drip-inter-token-latency", config.SlowDripInterTokenLatency, "Time in milliseconds between tokens in a slow drip response")
+
 	// These values were manually parsed above in getParamValueFromArgs, we leave this in order to get these flags in --help
 	var dummyString string
 	f.StringVar(&dummyString, "config", "", "The path to a yaml configuration file. The command line values overwrite the configuration file values")
 	var dummyMultiString multiString
 	f.Var(&dummyMultiString, "served-model-name", "Model names exposed by the API (a list of space-separated strings)")
 	f.Var(&dummyMultiString, "lora-modules", "List of LoRA adapters (a list of space-separated JSON strings)")
+	f.Var(&dummyMultiString, "fleet-profiles", "List of settings that override the global settings of the fleet's simulators, used in a round robin order (a list of space-separated JSON strings)")
+	f.Var(&dummyMultiString, "additional-models", "List of base models to serve in addition to the main model, with their served model names and settings (a list of space-separated JSON strings)")
+	f.Var(&dummyMultiString, "model-profiles", "List of served model names' settings that override the global settings (a list of space-separated JSON strings)")
 	f.Var(&dummyMultiString, "fake-metrics", "A set of metrics to report to Prometheus instead of the real metrics")
 	// In order to allow empty arguments, we set a dummy NoOptDefVal for these flags
 	f.Lookup("served-model-name").NoOptDefVal = dummy
 	f.Lookup("lora-modules").NoOptDefVal = dummy
+	f.Lookup("model-profiles").NoOptDefVal = dummy
+	f.Lookup("additional-models").NoOptDefVal = dummy
+	f.Lookup("fleet-profiles").NoOptDefVal = dummy
 	f.Lookup("fake-metrics").NoOptDefVal = dummy
 
 	flagSet := flag.NewFlagSet("simFlagSet", flag.ExitOnError)
@@ -495,6 +1304,24 @@ func ParseCommandParamsAndLoadConfig() (*Configuration, error) {
 			return nil, err
 		}
 	}
+	if fleetProfiles != nil {
+		config.FleetProfilesString = fleetProfiles
+		if err := config.unmarshalFleetProfiles(); err != nil {
+			return nil, err
+		}
+	}
+	if additionalModels != nil {
+		config.AdditionalModelsString = additionalModels
+		if err := config.unmarshalAdditionalModels(); err != nil {
+			return nil, err
+		}
+	}
+	if modelProfiles != nil {
+		config.ModelProfilesString = modelProfiles
+		if err := config.unmarshalModelProfiles(); err != nil {
+			return nil, err
+		}
+	}
 	if fakeMetrics != nil {
 		if err := config.unmarshalFakeMetrics(fakeMetrics[0]); err != nil {
 			return nil, err
@@ -506,6 +1333,12 @@ func ParseCommandParamsAndLoadConfig() (*Configuration, error) {
 	if failureTypes != nil {
 		config.FailureTypes = failureTypes
 	}
+	if streamFailureTypes != nil {
+		config.StreamFailureTypes = streamFailureTypes
+	}
+	if apiKeys != nil {
+		config.APIKeys = apiKeys
+	}
 
 	if config.HashSeed == "" {
 		hashSeed := os.Getenv("PYTHONHASHSEED")
@@ -514,7 +1347,7 @@ func ParseCommandParamsAndLoadConfig() (*Configuration, error) {
 		}
 	}
 
-	if err := config.validate(); err != nil {
+	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
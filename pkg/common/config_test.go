@@ -39,7 +39,7 @@ func createSimConfig(args []string) (*Configuration, error) {
 }
 
 func createDefaultConfig(model string) *Configuration {
-	c := newConfig()
+	c := NewDefaultConfig()
 
 	c.Model = model
 	c.ServedModelNames = []string{c.Model}
@@ -51,9 +51,16 @@ func createDefaultConfig(model string) *Configuration {
 	c.KVCacheTransferLatency = 100
 	c.Seed = 100100100
 	c.LoraModules = []LoraModule{}
+	c.ModelProfiles = []ModelProfile{}
+	c.AdditionalModels = []BaseModel{}
+	c.FleetProfiles = []ModelOverrides{}
 	return c
 }
 
+func intPtr(value int) *int {
+	return &value
+}
+
 type testCase struct {
 	name           string
 	args           []string
@@ -64,7 +71,7 @@ var _ = Describe("Simulator configuration", func() {
 	tests := make([]testCase, 0)
 
 	// Simple config with a few parameters
-	c := newConfig()
+	c := NewDefaultConfig()
 	c.Model = model
 	c.ServedModelNames = []string{c.Model}
 	c.MaxCPULoras = 1
@@ -81,6 +88,7 @@ var _ = Describe("Simulator configuration", func() {
 	c.Port = 8001
 	c.ServedModelNames = []string{"model1", "model2"}
 	c.LoraModules = []LoraModule{{Name: "lora1", Path: "/path/to/lora1"}, {Name: "lora2", Path: "/path/to/lora2"}}
+	c.ConfigFile = "../../manifests/config.yaml"
 	test = testCase{
 		name:           "config file",
 		args:           []string{"cmd", "--config", "../../manifests/config.yaml"},
@@ -104,6 +112,7 @@ var _ = Describe("Simulator configuration", func() {
 	}
 	c.EventBatchSize = 5
 	c.ZMQMaxConnectAttempts = 1
+	c.ConfigFile = "../../manifests/config.yaml"
 	test = testCase{
 		name: "config file with command line args",
 		args: []string{"cmd", "--model", model, "--config", "../../manifests/config.yaml", "--port", "8002",
@@ -124,6 +133,7 @@ var _ = Describe("Simulator configuration", func() {
 		"{\"name\":\"lora3\",\"path\":\"/path/to/lora3\"}",
 	}
 	c.ZMQMaxConnectAttempts = 0
+	c.ConfigFile = "../../manifests/config.yaml"
 	test = testCase{
 		name: "config file with command line args with different format",
 		args: []string{"cmd", "--model", model, "--config", "../../manifests/config.yaml", "--port", "8002",
@@ -134,6 +144,57 @@ var _ = Describe("Simulator configuration", func() {
 	}
 	tests = append(tests, test)
 
+	// Config from config.yaml file plus command line args with LoRA and served model name overrides
+	c = createDefaultConfig(qwenModelName)
+	c.Port = 8001
+	c.ServedModelNames = []string{"model1", "model2"}
+	c.LoraModules = []LoraModule{{Name: "lora3", Path: "/path/to/lora3",
+		ModelOverrides: ModelOverrides{TimeToFirstToken: intPtr(500), FailureInjectionRate: intPtr(10)}}}
+	c.LoraModulesString = []string{
+		"{\"name\":\"lora3\",\"path\":\"/path/to/lora3\",\"time-to-first-token\":500,\"failure-injection-rate\":10}",
+	}
+	c.ModelProfiles = []ModelProfile{{Name: "model2",
+		ModelOverrides: ModelOverrides{InterTokenLatency: intPtr(20), Mode: ModeEcho, MaxModelLen: intPtr(2048)}}}
+	c.ModelProfilesString = []string{
+		"{\"name\":\"model2\",\"inter-token-latency\":20,\"mode\":\"echo\",\"max-model-len\":2048}",
+	}
+	c.ConfigFile = "../../manifests/config.yaml"
+	test = testCase{
+		name: "config file with command line args with model overrides",
+		args: []string{"cmd", "--config", "../../manifests/config.yaml",
+			"--lora-modules", "{\"name\":\"lora3\",\"path\":\"/path/to/lora3\",\"time-to-first-token\":500,\"failure-injection-rate\":10}",
+			"--model-profiles", "{\"name\":\"model2\",\"inter-token-latency\":20,\"mode\":\"echo\",\"max-model-len\":2048}",
+		},
+		expectedConfig: c,
+	}
+	tests = append(tests, test)
+
+	// Config from config.yaml file plus command line args with additional models
+	c = createDefaultConfig(qwenModelName)
+	c.Port = 8001
+	c.ServedModelNames = []string{"model1", "model2"}
+	c.AdditionalModels = []BaseModel{
+		{Name: "model3", ServedModelNames: []string{"model3-alias"}, ModelOverrides: ModelOverrides{MaxModelLen: intPtr(4096)}},
+		{Name: "model4", ServedModelNames: []string{"model4"}},
+	}
+	c.AdditionalModelsString = []string{
+		"{\"name\":\"model3\",\"served-model-name\":[\"model3-alias\"],\"max-model-len\":4096}",
+		"{\"name\":\"model4\"}",
+	}
+	c.LoraModules = []LoraModule{{Name: "lora3", BaseModelName: "model3"}}
+	c.LoraModulesString = []string{"{\"name\":\"lora3\",\"base_model_name\":\"model3\"}"}
+	c.ConfigFile = "../../manifests/config.yaml"
+	test = testCase{
+		name: "config file with command line args with additional models",
+		args: []string{"cmd", "--config", "../../manifests/config.yaml",
+			"--additional-models", "{\"name\":\"model3\",\"served-model-name\":[\"model3-alias\"],\"max-model-len\":4096}",
+			"{\"name\":\"model4\"}",
+			"--lora-modules", "{\"name\":\"lora3\",\"base_model_name\":\"model3\"}",
+		},
+		expectedConfig: c,
+	}
+	tests = append(tests, test)
+
 	// Config from config.yaml file plus command line args with empty string
 	c = createDefaultConfig(model)
 	c.Port = 8002
@@ -141,6 +202,7 @@ var _ = Describe("Simulator configuration", func() {
 	c.LoraModulesString = []string{
 		"{\"name\":\"lora3\",\"path\":\"/path/to/lora3\"}",
 	}
+	c.ConfigFile = "../../manifests/config.yaml"
 	test = testCase{
 		name: "config file with command line args with empty string",
 		args: []string{"cmd", "--model", model, "--config", "../../manifests/config.yaml", "--port", "8002",
@@ -156,6 +218,7 @@ var _ = Describe("Simulator configuration", func() {
 	c.Port = 8001
 	c.ServedModelNames = []string{"model1", "model2"}
 	c.LoraModulesString = []string{}
+	c.ConfigFile = "../../manifests/config.yaml"
 	test = testCase{
 		name:           "config file with command line args with empty string for loras",
 		args:           []string{"cmd", "--config", "../../manifests/config.yaml", "--lora-modules", ""},
@@ -168,6 +231,7 @@ var _ = Describe("Simulator configuration", func() {
 	c.Port = 8001
 	c.ServedModelNames = []string{"model1", "model2"}
 	c.LoraModulesString = []string{}
+	c.ConfigFile = "../../manifests/config.yaml"
 	test = testCase{
 		name:           "config file with command line args with empty parameter for loras",
 		args:           []string{"cmd", "--config", "../../manifests/config.yaml", "--lora-modules"},
@@ -182,6 +246,7 @@ var _ = Describe("Simulator configuration", func() {
 	c.MaxLoras = 1
 	c.MaxCPULoras = 1
 	c.KVCacheTransferLatency = 50
+	c.ConfigFile = "../../manifests/basic-config.yaml"
 	test = testCase{
 		name:           "basic config file with command line args with time to transfer kv-cache",
 		args:           []string{"cmd", "--config", "../../manifests/basic-config.yaml", "--kv-cache-transfer-latency", "50"},
@@ -204,6 +269,7 @@ var _ = Describe("Simulator configuration", func() {
 			"{\"running\":\"lora1,lora3\",\"waiting\":\"\",\"timestamp\":1257894569}",
 		},
 	}
+	c.ConfigFile = "../../manifests/config_with_fake.yaml"
 	test = testCase{
 		name:           "config with fake metrics file",
 		args:           []string{"cmd", "--config", "../../manifests/config_with_fake.yaml"},
@@ -212,7 +278,7 @@ var _ = Describe("Simulator configuration", func() {
 	tests = append(tests, test)
 
 	// Fake metrics from command line
-	c = newConfig()
+	c = NewDefaultConfig()
 	c.Model = model
 	c.ServedModelNames = []string{c.Model}
 	c.MaxCPULoras = 1
@@ -249,6 +315,7 @@ var _ = Describe("Simulator configuration", func() {
 		},
 		LorasString: nil,
 	}
+	c.ConfigFile = "../../manifests/config_with_fake.yaml"
 	test = testCase{
 		name: "metrics from config file and command line",
 		args: []string{"cmd", "--config", "../../manifests/config_with_fake.yaml",
@@ -370,6 +437,101 @@ var _ = Describe("Simulator configuration", func() {
 			args: []string{"cmd", "--event-batch-size", "-35",
 				"--config", "../../manifests/config.yaml"},
 		},
+		{
+			name: "invalid scheduling-policy",
+			args: []string{"cmd", "--scheduling-policy", "lifo",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "priority-preemption without priority scheduling-policy",
+			args: []string{"cmd", "--priority-preemption",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid (negative) lora-load-latency",
+			args: []string{"cmd", "--lora-load-latency", "-1",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid (negative) lora-disk-load-latency",
+			args: []string{"cmd", "--lora-disk-load-latency", "-1",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid LoRA time-to-first-token override",
+			args: []string{"cmd", "--lora-modules", "{\"name\":\"lora1\",\"time-to-first-token\":-1}",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid LoRA mode override",
+			args: []string{"cmd", "--lora-modules", "{\"name\":\"lora1\",\"mode\":\"fast\"}",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid served model name failure-injection-rate override",
+			args: []string{"cmd", "--model-profiles", "{\"name\":\"model1\",\"failure-injection-rate\":101}",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid fleet-size",
+			args: []string{"cmd", "--fleet-size", "0",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "fleet-status-port in the fleet's ports",
+			args: []string{"cmd", "--fleet-size", "3", "--fleet-status-port", "8002",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "fleet with a random port",
+			args: []string{"cmd", "--fleet-size", "3", "--port", "0",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid fleet profile",
+			args: []string{"cmd", "--fleet-size", "3", "--fleet-profiles", "{\"inter-token-latency\":-5}",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "additional model with a served model name in use",
+			args: []string{"cmd", "--additional-models", "{\"name\":\"model3\",\"served-model-name\":[\"model1\"]}",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "duplicate additional model",
+			args: []string{"cmd", "--additional-models", "{\"name\":\"model3\"}", "{\"name\":\"model3\"}",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "additional model with invalid settings",
+			args: []string{"cmd", "--additional-models", "{\"name\":\"model3\",\"max-model-len\":0}",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "model profile of unknown served model name",
+			args: []string{"cmd", "--model-profiles", "{\"name\":\"model3\",\"max-model-len\":10}",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "non-existing lora-resolver-dir",
+			args: []string{"cmd", "--lora-resolver-dir", "/non/existing/dir",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid (negative) transcription-latency-per-second",
+			args: []string{"cmd", "--transcription-latency-per-second", "-1",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid (negative) image-tokens",
+			args: []string{"cmd", "--image-tokens", "-1",
+				"--config", "../../manifests/config.yaml"},
+		},
+		{
+			name: "invalid (negative) image-tile-size",
+			args: []string{"cmd", "--image-tile-size", "-32",
+				"--config", "../../manifests/config.yaml"},
+		},
 		{
 			name: "invalid failure injection rate > 100",
 			args: []string{"cmd", "--model", "test-model", "--failure-injection-rate", "150"},
@@ -383,6 +545,80 @@ var _ = Describe("Simulator configuration", func() {
 			args: []string{"cmd", "--model", "test-model", "--failure-injection-rate", "50",
 				"--failure-types", "invalid_type"},
 		},
+		{
+			name: "invalid stream failure injection rate > 100",
+			args: []string{"cmd", "--model", "test-model", "--stream-failure-injection-rate", "101"},
+		},
+		{
+			name: "invalid stream failure type",
+			args: []string{"cmd", "--model", "test-model", "--stream-failure-injection-rate", "50",
+				"--stream-failure-types", "invalid_type"},
+		},
+		{
+			name: "invalid stream stall time",
+			args: []string{"cmd", "--model", "test-model", "--stream-stall-time", "-1"},
+		},
+		{
+			name: "invalid hang injection rate",
+			args: []string{"cmd", "--model", "test-model", "--hang-injection-rate", "-1"},
+		},
+		{
+			name: "invalid time to first token spike multiplier",
+			args: []string{"cmd", "--model", "test-model", "--ttft-spike-multiplier", "0.5"},
+		},
+		{
+			name: "invalid stutter injection rate",
+			args: []string{"cmd", "--model", "test-model", "--stutter-injection-rate", "101"},
+		},
+		{
+			name: "invalid slow drip inter token latency",
+			args: []string{"cmd", "--model", "test-model", "--slow-drip-inter-token-latency", "-100"},
+		},
+		{
+			name: "invalid rate limit requests per minute",
+			args: []string{"cmd", "--model", "test-model", "--rate-limit-requests-per-minute", "-1"},
+		},
+		{
+			name: "invalid rate limit key",
+			args: []string{"cmd", "--model", "test-model", "--rate-limit-by", "user"},
+		},
+		{
+			name: "missing api key file",
+			args: []string{"cmd", "--model", "test-model", "--api-key-file", "/nonexistent/keys"},
+		},
+		{
+			name: "api key file without keys",
+			args: []string{"cmd", "--model", "test-model", "--api-key-file", "/dev/null"},
+		},
+		{
+			name: "missing scenario file",
+			args: []string{"cmd", "--model", "test-model", "--scenario-file", "/nonexistent/scenario.yaml"},
+		},
+		{
+			name: "invalid startup delay",
+			args: []string{"cmd", "--model", "test-model", "--startup-delay", "-1"},
+		},
+		{
+			name: "invalid readiness queue threshold",
+			args: []string{"cmd", "--model", "test-model", "--readiness-queue-threshold", "-1"},
+		},
+		{
+			name: "invalid liveness failure injection rate",
+			args: []string{"cmd", "--model", "test-model", "--liveness-failure-injection-rate", "101"},
+		},
+		{
+			name: "invalid shutdown grace period",
+			args: []string{"cmd", "--model", "test-model", "--shutdown-grace-period", "-1"},
+		},
+		{
+			name: "invalid wake up latency",
+			args: []string{"cmd", "--model", "test-model", "--wake-up-latency", "-1"},
+		},
+		{
+			name: "invalid scenario start",
+			args: []string{"cmd", "--model", "test-model", "--scenario-file", "../../manifests/scenario.yaml",
+				"--scenario-start", "never"},
+		},
 		{
 			name: "invalid fake metrics: negative running requests",
 			args: []string{"cmd", "--fake-metrics", "{\"running-requests\":-10,\"waiting-requests\":30,\"kv-cache-usage\":0.4}",
@@ -393,6 +629,12 @@ var _ = Describe("Simulator configuration", func() {
 			args: []string{"cmd", "--fake-metrics", "{\"running-requests\":10,\"waiting-requests\":30,\"kv-cache-usage\":40}",
 				"--config", "../../manifests/config.yaml"},
 		},
+		{
+			name: "invalid fake metrics: KV cache usage series",
+			args: []string{"cmd", "--fake-metrics",
+				"{\"kv-cache-usage-series\":{\"type\":\"ramp\",\"points\":[{\"time\":0,\"value\":0},{\"time\":100,\"value\":5}]}}",
+				"--config", "../../manifests/config.yaml"},
+		},
 		{
 			name: "invalid (negative) zmq-max-connect-attempts for argument",
 			args: []string{"cmd", "zmq-max-connect-attempts", "-1", "--config", "../../manifests/config.yaml"},
@@ -411,4 +653,31 @@ var _ = Describe("Simulator configuration", func() {
 			})
 		})
 	}
+
+	It("should separate the reloaded changes that can be applied at runtime", func() {
+		config, err := createSimConfig([]string{"cmd", "--config", "../../manifests/config.yaml"})
+		Expect(err).NotTo(HaveOccurred())
+		reloaded, err := createSimConfig([]string{"cmd", "--config", "../../manifests/config.yaml",
+			"--time-to-first-token", "10", "--port", "9000", "--max-num-seqs", "7", "--max-model-len", "2048",
+			"--lora-modules", "{\"name\":\"lora3\",\"path\":\"/path/to/lora3\"}",
+			"--api-key", "new-key", "--admin-api-key", "new-admin-key"})
+		Expect(err).NotTo(HaveOccurred())
+
+		changes, restartParams, err := config.ReloadChanges(reloaded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restartParams).To(Equal([]string{"admin-api-key", "api-key", "max-model-len", "port"}))
+		Expect(string(changes)).To(Equal(`{"lora-modules":[{"name":"lora3","path":"/path/to/lora3","base_model_name":""}],` +
+			`"max-num-seqs":7,"time-to-first-token":10}`))
+
+		changed, err := config.WithRuntimeChanges(changes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed.TimeToFirstToken).To(Equal(10))
+		Expect(changed.LoraModules).To(Equal([]LoraModule{{Name: "lora3", Path: "/path/to/lora3"}}))
+		Expect(changed.Port).To(Equal(config.Port))
+
+		changes, restartParams, err = config.ReloadChanges(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeNil())
+		Expect(restartParams).To(BeEmpty())
+	})
 })
@@ -0,0 +1,181 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SeriesTypeStep is a series of values that change at the points' times
+	SeriesTypeStep = "step"
+	// SeriesTypeRamp is a series of values that change linearly between the points
+	SeriesTypeRamp = "ramp"
+	// SeriesTypeSine is a sine wave between a minimum and a maximum value
+	SeriesTypeSine = "sine"
+	// SeriesTypeCSV is a step series with the points read from a CSV file
+	SeriesTypeCSV = "csv"
+
+	defaultSeriesUpdateInterval = 1000
+)
+
+// SeriesPoint is a value of a metric series from a time
+type SeriesPoint struct {
+	// Time is the time in milliseconds since the series started
+	Time int `yaml:"time" json:"time"`
+	// Value is the metric's value
+	Value float64 `yaml:"value" json:"value"`
+}
+
+// MetricSeries is a fake metric's value that changes over time
+type MetricSeries struct {
+	// Type is the type of the series: step, ramp, sine or csv
+	Type string `yaml:"type" json:"type"`
+	// Points are the series' points, sorted by their times, for step and ramp series,
+	// for csv series they are read from the file
+	Points []SeriesPoint `yaml:"points" json:"points,omitempty"`
+	// File is the path of a CSV file of a csv series, each line is a time in milliseconds and a value,
+	// an optional first line can contain the columns' names
+	File string `yaml:"file" json:"file,omitempty"`
+	// Min is the minimum value of a sine series
+	Min float64 `yaml:"min" json:"min,omitempty"`
+	// Max is the maximum value of a sine series
+	Max float64 `yaml:"max" json:"max,omitempty"`
+	// Period is the period in milliseconds of a sine series
+	Period int `yaml:"period" json:"period,omitempty"`
+	// Loop restarts the series at the time of its last point, sine series always loop
+	Loop bool `yaml:"loop" json:"loop,omitempty"`
+}
+
+// ValueAt returns the series' value at the given time since the series started
+func (s *MetricSeries) ValueAt(elapsed time.Duration) float64 {
+	if s.Type == SeriesTypeSine {
+		phase := 2 * math.Pi * float64(elapsed.Milliseconds()) / float64(s.Period)
+		return s.Min + (s.Max-s.Min)*(1+math.Sin(phase))/2
+	}
+
+	t := int(elapsed.Milliseconds())
+	last := s.Points[len(s.Points)-1]
+	if s.Loop && last.Time > 0 {
+		t %= last.Time
+	}
+	if t < s.Points[0].Time {
+		return s.Points[0].Value
+	}
+	for i := 1; i < len(s.Points); i++ {
+		next := s.Points[i]
+		if t >= next.Time {
+			continue
+		}
+		prev := s.Points[i-1]
+		if s.Type == SeriesTypeRamp && next.Time > prev.Time {
+			return prev.Value + (next.Value-prev.Value)*float64(t-prev.Time)/float64(next.Time-prev.Time)
+		}
+		return prev.Value
+	}
+	return last.Value
+}
+
+// validate validates the series, and reads the points of a csv series from its file,
+// the series' values must be between min and max
+func (s *MetricSeries) validate(minValue float64, maxValue float64) error {
+	switch s.Type {
+	case SeriesTypeSine:
+		if s.Period <= 0 {
+			return errors.New("sine series period must be positive")
+		}
+		if s.Min > s.Max {
+			return errors.New("sine series minimum cannot be more than its maximum")
+		}
+		return checkSeriesValue(s.Min, minValue, maxValue, s.Max)
+	case SeriesTypeCSV:
+		points, err := readSeriesCSV(s.File)
+		if err != nil {
+			return err
+		}
+		s.Points = points
+	case SeriesTypeStep, SeriesTypeRamp:
+	default:
+		return fmt.Errorf("invalid series type '%s', valid values are '%s', '%s', '%s' and '%s'", s.Type,
+			SeriesTypeStep, SeriesTypeRamp, SeriesTypeSine, SeriesTypeCSV)
+	}
+
+	if len(s.Points) == 0 {
+		return errors.New("series points cannot be empty")
+	}
+	for i, point := range s.Points {
+		if point.Time < 0 || (i > 0 && point.Time < s.Points[i-1].Time) {
+			return errors.New("series points' times must be non-negative and sorted")
+		}
+		if err := checkSeriesValue(point.Value, minValue, maxValue, point.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSeriesValue checks that the series' values from low to high are between minValue and maxValue
+func checkSeriesValue(low float64, minValue float64, maxValue float64, high float64) error {
+	if low < minValue || high > maxValue {
+		return fmt.Errorf("series values must be between %g and %g", minValue, maxValue)
+	}
+	return nil
+}
+
+// readSeriesCSV reads the points of a series from a CSV file of times in milliseconds and values
+func readSeriesCSV(path string) ([]SeriesPoint, error) {
+	if path == "" {
+		return nil, errors.New("csv series file is not set")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv series file %s: %w", path, err)
+	}
+
+	points := make([]SeriesPoint, 0, len(records))
+	for i, record := range records {
+		t, timeErr := strconv.Atoi(strings.TrimSpace(record[0]))
+		value, valueErr := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if timeErr != nil || valueErr != nil {
+			if i == 0 {
+				// the columns' names
+				continue
+			}
+			return nil, fmt.Errorf("invalid line %d in csv series file %s", i+1, path)
+		}
+		points = append(points, SeriesPoint{Time: t, Value: value})
+	}
+	return points, nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metric series", func() {
+	points := []SeriesPoint{{Time: 1000, Value: 10}, {Time: 2000, Value: 20}, {Time: 4000, Value: 0}}
+
+	DescribeTable("should return the series' value at the given time",
+		func(series MetricSeries, elapsed int, expected float64) {
+			Expect(series.ValueAt(time.Duration(elapsed) * time.Millisecond)).To(BeNumerically("~", expected, 0.001))
+		},
+		Entry("step before the first point", MetricSeries{Type: SeriesTypeStep, Points: points}, 500, 10.0),
+		Entry("step between points", MetricSeries{Type: SeriesTypeStep, Points: points}, 3000, 20.0),
+		Entry("step after the last point", MetricSeries{Type: SeriesTypeStep, Points: points}, 9000, 0.0),
+		Entry("ramp between points", MetricSeries{Type: SeriesTypeRamp, Points: points}, 3000, 10.0),
+		Entry("ramp at a point", MetricSeries{Type: SeriesTypeRamp, Points: points}, 2000, 20.0),
+		Entry("looping ramp", MetricSeries{Type: SeriesTypeRamp, Points: points, Loop: true}, 5500, 15.0),
+		Entry("sine start", MetricSeries{Type: SeriesTypeSine, Min: 0, Max: 10, Period: 4000}, 0, 5.0),
+		Entry("sine peak", MetricSeries{Type: SeriesTypeSine, Min: 0, Max: 10, Period: 4000}, 1000, 10.0),
+		Entry("sine next period", MetricSeries{Type: SeriesTypeSine, Min: 0, Max: 10, Period: 4000}, 7000, 0.0),
+	)
+
+	It("should read a csv series with an optional header", func() {
+		file := filepath.Join(GinkgoT().TempDir(), "series.csv")
+		Expect(os.WriteFile(file, []byte("time,value\n0, 0.1\n1000, 0.5\n"), 0o644)).To(Succeed())
+		series := MetricSeries{Type: SeriesTypeCSV, File: file}
+		Expect(series.validate(0, 1)).To(Succeed())
+		Expect(series.Points).To(Equal([]SeriesPoint{{Time: 0, Value: 0.1}, {Time: 1000, Value: 0.5}}))
+		Expect(series.ValueAt(1500 * time.Millisecond)).To(Equal(0.5))
+
+		Expect(os.WriteFile(file, []byte("0,0.1\nnow,0.5\n"), 0o644)).To(Succeed())
+		Expect(series.validate(0, 1)).NotTo(Succeed())
+	})
+
+	DescribeTable("should reject invalid series",
+		func(series MetricSeries) {
+			Expect(series.validate(0, 1)).NotTo(Succeed())
+		},
+		Entry("invalid type", MetricSeries{Type: "square", Points: []SeriesPoint{{Time: 0, Value: 0}}}),
+		Entry("no points", MetricSeries{Type: SeriesTypeStep}),
+		Entry("unsorted points", MetricSeries{Type: SeriesTypeRamp, Points: []SeriesPoint{{Time: 10}, {Time: 5}}}),
+		Entry("value out of range", MetricSeries{Type: SeriesTypeStep, Points: []SeriesPoint{{Time: 0, Value: 2}}}),
+		Entry("sine without period", MetricSeries{Type: SeriesTypeSine, Max: 1}),
+		Entry("sine out of range", MetricSeries{Type: SeriesTypeSine, Max: 2, Period: 100}),
+		Entry("missing csv file", MetricSeries{Type: SeriesTypeCSV, File: "/no/such/file.csv"}),
+	)
+})
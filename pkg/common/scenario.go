@@ -0,0 +1,170 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// PhaseTypeHealthy is a phase without failures, the simulator behaves according to its configuration
+	PhaseTypeHealthy = "healthy"
+	// PhaseTypeDegraded is a phase with higher latencies
+	PhaseTypeDegraded = "degraded"
+	// PhaseTypeErrorBurst is a phase in which requests fail at a rate
+	PhaseTypeErrorBurst = "error_burst"
+	// PhaseTypeOutage is a phase in which all the requests fail with 503 and the simulator is not ready
+	PhaseTypeOutage = "outage"
+	// PhaseTypeReadinessFlapping is a phase in which the simulator's readiness changes periodically
+	PhaseTypeReadinessFlapping = "readiness_flapping"
+
+	// ScenarioStartStartup plays the scenario from the simulator's start
+	ScenarioStartStartup = "startup"
+	// ScenarioStartAdmin plays the scenario when it is started by the admin API
+	ScenarioStartAdmin = "admin"
+
+	defaultFlapInterval = 1000
+)
+
+var validPhaseTypes = []string{PhaseTypeHealthy, PhaseTypeDegraded, PhaseTypeErrorBurst, PhaseTypeOutage,
+	PhaseTypeReadinessFlapping}
+
+// ScenarioPhase is a timed phase of a scenario
+type ScenarioPhase struct {
+	// Name is the phase's name, used in the logs
+	Name string `yaml:"name" json:"name"`
+	// Type is the phase's type: healthy, degraded, error_burst, outage or readiness_flapping
+	Type string `yaml:"type" json:"type"`
+	// Duration is the phase's duration in milliseconds
+	Duration int `yaml:"duration" json:"duration"`
+	// TimeToFirstToken is the time to first token in milliseconds in a degraded phase
+	TimeToFirstToken *int `yaml:"time-to-first-token" json:"time-to-first-token,omitempty"`
+	// InterTokenLatency is the inter token latency in milliseconds in a degraded phase
+	InterTokenLatency *int `yaml:"inter-token-latency" json:"inter-token-latency,omitempty"`
+	// FailureRate is the probability (0-100) of failing a request in an error burst phase
+	FailureRate int `yaml:"failure-rate" json:"failure-rate,omitempty"`
+	// FailureTypes are the types of the failures in an error burst phase (empty means all types)
+	FailureTypes []string `yaml:"failure-types" json:"failure-types,omitempty"`
+	// FlapInterval is the time in milliseconds between readiness changes in a readiness flapping phase
+	FlapInterval int `yaml:"flap-interval" json:"flap-interval,omitempty"`
+}
+
+// Scenario is a timeline of phases that the simulator plays back
+type Scenario struct {
+	// Phases are the scenario's phases, played one after another
+	Phases []ScenarioPhase `yaml:"phases" json:"phases"`
+	// Loop restarts the scenario after its last phase, otherwise the simulator is healthy after the last phase
+	Loop bool `yaml:"loop" json:"loop"`
+}
+
+// LoadScenario reads and validates the scenario in the given YAML file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file: %w", err)
+	}
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario file %s: %w", path, err)
+	}
+	if err := scenario.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario file %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+func (s *Scenario) validate() error {
+	if len(s.Phases) == 0 {
+		return errors.New("scenario has no phases")
+	}
+	for i := range s.Phases {
+		phase := &s.Phases[i]
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase-%d", i+1)
+		}
+		if !slices.Contains(validPhaseTypes, phase.Type) {
+			return fmt.Errorf("invalid type '%s' of phase %s, valid types are: %s", phase.Type, phase.Name,
+				strings.Join(validPhaseTypes, ", "))
+		}
+		if phase.Duration <= 0 {
+			return fmt.Errorf("duration of phase %s should be positive", phase.Name)
+		}
+		if phase.TimeToFirstToken != nil && *phase.TimeToFirstToken < 0 {
+			return fmt.Errorf("time to first token of phase %s cannot be negative", phase.Name)
+		}
+		if phase.InterTokenLatency != nil && *phase.InterTokenLatency < 0 {
+			return fmt.Errorf("inter token latency of phase %s cannot be negative", phase.Name)
+		}
+		if phase.FailureRate < 0 || phase.FailureRate > 100 {
+			return fmt.Errorf("failure rate of phase %s should be between 0 and 100", phase.Name)
+		}
+		for _, failureType := range phase.FailureTypes {
+			if !validFailureTypes[failureType] {
+				return fmt.Errorf("invalid failure type '%s' of phase %s", failureType, phase.Name)
+			}
+		}
+		if phase.FlapInterval < 0 {
+			return fmt.Errorf("flap interval of phase %s cannot be negative", phase.Name)
+		}
+		if phase.FlapInterval == 0 {
+			phase.FlapInterval = defaultFlapInterval
+		}
+	}
+	return nil
+}
+
+// PhaseAt returns the index of the phase at the given time since the scenario started and the time
+// since the phase started, returns -1 if the scenario has ended
+func (s *Scenario) PhaseAt(elapsed time.Duration) (int, time.Duration) {
+	t := int(elapsed.Milliseconds())
+	total := 0
+	for _, phase := range s.Phases {
+		total += phase.Duration
+	}
+	if t >= total {
+		if !s.Loop {
+			return -1, 0
+		}
+		t %= total
+	}
+	for i, phase := range s.Phases {
+		if t < phase.Duration {
+			return i, time.Duration(t) * time.Millisecond
+		}
+		t -= phase.Duration
+	}
+	return -1, 0
+}
+
+// IsReady returns false if the simulator is not ready at the given time since the phase started
+func (p *ScenarioPhase) IsReady(elapsed time.Duration) bool {
+	switch p.Type {
+	case PhaseTypeOutage:
+		return false
+	case PhaseTypeReadinessFlapping:
+		// the simulator is not ready in the first interval, and then ready in the second one
+		return (elapsed.Milliseconds()/int64(p.FlapInterval))%2 == 1
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scenario", func() {
+	phases := []ScenarioPhase{
+		{Name: "healthy", Type: PhaseTypeHealthy, Duration: 1000},
+		{Name: "outage", Type: PhaseTypeOutage, Duration: 2000},
+		{Name: "flapping", Type: PhaseTypeReadinessFlapping, Duration: 3000, FlapInterval: 500},
+	}
+
+	DescribeTable("should return the phase at the given time",
+		func(loop bool, elapsed int, expectedPhase int, expectedElapsed int) {
+			scenario := Scenario{Phases: phases, Loop: loop}
+			phase, phaseElapsed := scenario.PhaseAt(time.Duration(elapsed) * time.Millisecond)
+			Expect(phase).To(Equal(expectedPhase))
+			Expect(phaseElapsed).To(Equal(time.Duration(expectedElapsed) * time.Millisecond))
+		},
+		Entry("first phase", false, 500, 0, 500),
+		Entry("start of a phase", false, 1000, 1, 0),
+		Entry("last phase", false, 5999, 2, 2999),
+		Entry("ended scenario", false, 6000, -1, 0),
+		Entry("looping scenario", true, 7500, 1, 500),
+	)
+
+	It("should change the readiness of a flapping phase", func() {
+		Expect(phases[0].IsReady(0)).To(BeTrue())
+		Expect(phases[1].IsReady(0)).To(BeFalse())
+		Expect(phases[2].IsReady(100 * time.Millisecond)).To(BeFalse())
+		Expect(phases[2].IsReady(600 * time.Millisecond)).To(BeTrue())
+		Expect(phases[2].IsReady(1100 * time.Millisecond)).To(BeFalse())
+	})
+
+	It("should load the example scenario", func() {
+		scenario, err := LoadScenario("../../manifests/scenario.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scenario.Phases).To(HaveLen(5))
+		Expect(scenario.Phases[1].Type).To(Equal(PhaseTypeDegraded))
+		Expect(*scenario.Phases[1].TimeToFirstToken).To(Equal(2000))
+		Expect(scenario.Phases[2].FailureTypes).To(Equal([]string{FailureTypeServerError}))
+		Expect(scenario.Phases[3].FlapInterval).To(Equal(defaultFlapInterval))
+	})
+
+	DescribeTable("should reject invalid scenarios",
+		func(content string) {
+			path := filepath.Join(GinkgoT().TempDir(), "scenario.yaml")
+			Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+			_, err := LoadScenario(path)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("no phases", "loop: true\n"),
+		Entry("invalid type", "phases:\n  - type: broken\n    duration: 1000\n"),
+		Entry("no duration", "phases:\n  - type: outage\n"),
+		Entry("invalid failure rate", "phases:\n  - type: error_burst\n    duration: 1000\n    failure-rate: 200\n"),
+		Entry("invalid failure type", "phases:\n  - type: error_burst\n    duration: 1000\n    failure-types: [oops]\n"),
+	)
+})
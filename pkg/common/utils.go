@@ -58,6 +58,9 @@ var chatCompletionFakeResponses = []string{
 	`Give a man a fish and you feed him for a day; teach a man to fish and you feed him for a lifetime`,
 }
 
+// tokensPool contains all the different tokens of the pre-defined sentences
+var tokensPool []string
+
 func init() {
 	cumulativeBucketsProbabilities = make([]float64, len(respLenBucketsProbabilities))
 	sum := 0.0
@@ -248,6 +251,22 @@ func GetResponseText(maxCompletionTokens *int64, text string) (string, string) {
 	return strings.Join(tokens[0:*maxCompletionTokens], " "), LengthFinishReason
 }
 
+// GetRandomTokens returns the given number of different tokens, randomly chosen from the tokens
+// of the pre-defined sentences, the excluded token is never returned
+func GetRandomTokens(numOfTokens int, excluded string) []string {
+	numOfTokens = min(numOfTokens, len(tokensPool)-1)
+	tokens := make([]string, 0, numOfTokens)
+	used := map[string]bool{excluded: true}
+	for len(tokens) < numOfTokens {
+		token := tokensPool[RandomInt(0, len(tokensPool)-1)]
+		if !used[token] {
+			used[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
 func RandomNumericString(length int) string {
 	digits := "0123456789"
 	result := make([]byte, length)
@@ -323,6 +342,16 @@ var re *regexp.Regexp
 
 func init() {
 	re = regexp.MustCompile(`(\{|\}|:|,|-|\.|\?|\!|;|@|#|\$|%|\^|&|\*|\(|\)|\+|\-|_|~|/|\\|>|<|\[|\]|=|"|\w+)(\s*)`)
+
+	existingTokens := make(map[string]bool)
+	for _, sentence := range chatCompletionFakeResponses {
+		for _, token := range Tokenize(sentence) {
+			if !existingTokens[token] {
+				existingTokens[token] = true
+				tokensPool = append(tokensPool, token)
+			}
+		}
+	}
 }
 
 func Tokenize(text string) []string {
@@ -28,6 +28,7 @@ import (
 
 const (
 	capacityError = "the kv cache does not have sufficient capacity to store this request"
+	resetError    = "the kv cache cannot be reset while there are running requests"
 	delay         = time.Second
 )
 
@@ -38,6 +39,7 @@ type blockCache struct {
 	usedBlocks      map[uint64]int       // block hash -> reference count
 	unusedBlocks    map[uint64]time.Time // block hash -> last usage timestamp
 	maxBlocks       int                  // maximum number of blocks in the cache
+	blockSize       int                  // number of tokens in a block
 	eventSender     *KVEventSender       // emmits kv events
 	eventChan       chan EventData       // channel for asynchronous event processing
 	logger          logr.Logger
@@ -58,6 +60,7 @@ func newBlockCache(config *common.Configuration, logger logr.Logger) (*blockCach
 		usedBlocks:      make(map[uint64]int),
 		unusedBlocks:    make(map[uint64]time.Time),
 		maxBlocks:       config.KVCacheSize,
+		blockSize:       config.TokenBlockSize,
 		eventChan:       eChan,
 		eventSender:     NewKVEventSender(publisher, createTopic(config), eChan, config.EventBatchSize, delay, logger),
 		logger:          logger,
@@ -71,8 +74,10 @@ func (b *blockCache) start(ctx context.Context) {
 	}
 }
 
-// startRequest adds a request with its associated block hashes to the cache
-func (bc *blockCache) startRequest(requestID string, blocks []uint64) error {
+// startRequest adds a request with its associated block hashes to the cache, blockTokens are the tokens
+// of each block and loraID is the id of the request's LoRA adapter (nil for the base model), both are
+// sent in the BlockStored events of the new blocks
+func (bc *blockCache) startRequest(requestID string, blocks []uint64, blockTokens [][]uint32, loraID *int) error {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
@@ -84,19 +89,19 @@ func (bc *blockCache) startRequest(requestID string, blocks []uint64) error {
 	// divide list of blocks to three lists:
 	// blockAreadyInUse - blocks, which are already used by currently running request
 	// blockToMoveToUsed - blocks, which were used in past
-	// blocksToAdd - new blocks
-	blocksToAdd := make([]uint64, 0)
+	// blocksToAdd - new blocks, by their index in the request
+	blocksToAdd := make([]int, 0)
 	blockToMoveToUsed := make([]uint64, 0)
 	blockAreadyInUse := make([]uint64, 0)
 
 	// first step - ensure that there is enough space for all blocks
 	// count number of new blocks + number of blocks that are in the unused blocks
 	// don't update the data until we are sure that it's ok
-	for _, blockHash := range blocks {
+	for i, blockHash := range blocks {
 		if _, exists := bc.unusedBlocks[blockHash]; exists {
 			blockToMoveToUsed = append(blockToMoveToUsed, blockHash)
 		} else if _, exists := bc.usedBlocks[blockHash]; !exists {
-			blocksToAdd = append(blocksToAdd, blockHash)
+			blocksToAdd = append(blocksToAdd, i)
 		} else {
 			blockAreadyInUse = append(blockAreadyInUse, blockHash)
 		}
@@ -118,7 +123,8 @@ func (bc *blockCache) startRequest(requestID string, blocks []uint64) error {
 	}
 
 	// for new block - add them, if there is no empty slots - evict the oldest block
-	for _, block := range blocksToAdd {
+	for _, i := range blocksToAdd {
+		block := blocks[i]
 		if len(bc.usedBlocks)+len(bc.unusedBlocks) == bc.maxBlocks {
 			// cache is full but contains unused blocks - evict the oldest
 			var oldestUnusedHash uint64
@@ -137,7 +143,17 @@ func (bc *blockCache) startRequest(requestID string, blocks []uint64) error {
 
 		// Add the new block
 		bc.usedBlocks[block] = 1
-		bc.eventChan <- EventData{action: eventActionStore, hashValues: []uint64{block}}
+		event := EventData{action: eventActionStore, hashValues: []uint64{block}, blockSize: bc.blockSize,
+			loraID: loraID}
+		// the parent of a block is the preceding block in the request's chain
+		if i > 0 {
+			parent := blocks[i-1]
+			event.parentHash = &parent
+		}
+		if i < len(blockTokens) {
+			event.tokens = blockTokens[i]
+		}
+		bc.eventChan <- event
 	}
 
 	// store the request mapping
@@ -191,6 +207,22 @@ func (bc *blockCache) finishRequest(requestID string) error {
 	return nil
 }
 
+// reset removes all the blocks from the cache and emits an AllBlocksCleared event, fails if there
+// are running requests that use blocks
+func (bc *blockCache) reset() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if len(bc.requestToBlocks) > 0 {
+		return errors.New(resetError)
+	}
+
+	bc.usedBlocks = make(map[uint64]int)
+	bc.unusedBlocks = make(map[uint64]time.Time)
+	bc.eventChan <- EventData{action: eventActionClear}
+	return nil
+}
+
 // GetStats returns current cache statistics (for testing/debugging)
 func (bc *blockCache) getStats() (int, int, int) {
 	bc.mu.RLock()
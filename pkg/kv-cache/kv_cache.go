@@ -29,7 +29,9 @@ import (
 
 type KVCacheHelper struct {
 	tokenizer       tokenization.Tokenizer
-	tokensProcessor kvblock.TokenProcessor // turns tokens to kv block keys
+	tokensProcessor kvblock.TokenProcessor // turns tokens to kv block keys of the base model
+	hashSeed        string                 // the hash seed of the base model's blocks
+	blockSize       int                    // number of tokens in a block
 	logger          logr.Logger
 	blockCache      *blockCache
 }
@@ -57,6 +59,8 @@ func NewKVCacheHelper(config *common.Configuration, logger logr.Logger) (*KVCach
 	return &KVCacheHelper{
 		tokenizer:       tokenizer,
 		tokensProcessor: tokensProcessor,
+		hashSeed:        tokenProcConfig.HashSeed,
+		blockSize:       config.TokenBlockSize,
 		blockCache:      blockCache,
 		logger:          logger,
 	}, nil
@@ -67,30 +71,71 @@ func (h *KVCacheHelper) Run(ctx context.Context) {
 	h.blockCache.start(ctx)
 }
 
-func (h *KVCacheHelper) OnRequestStart(vllmReq openaiserverapi.CompletionRequest) error {
+// OnRequestStart adds the blocks of the request's prompt to the cache, loraID is the id of the request's
+// LoRA adapter, nil for the base model
+func (h *KVCacheHelper) OnRequestStart(vllmReq openaiserverapi.CompletionRequest, loraID *int) error {
 	h.logger.Info("KV cache - process request")
 
-	prompt := vllmReq.GetPrompt()
 	modelName := vllmReq.GetModel()
 	requestID := vllmReq.GetRequestID()
 
 	// tokenize the input
-	tokens, _, err := h.tokenizer.Encode(prompt, modelName)
+	tokens, err := h.tokenize(vllmReq.GetPromptSegments(), modelName)
 	if err != nil {
 		h.logger.Info("Prompt tokenization failed", "error", err.Error())
-		return h.blockCache.startRequest(requestID, make([]uint64, 0))
+		return h.blockCache.startRequest(requestID, make([]uint64, 0), nil, loraID)
 	}
 
 	// get block keys
-	blockKeys := h.tokensProcessor.TokensToKVBlockKeys(tokens, modelName)
+	blockKeys := h.getTokensProcessor(loraID).TokensToKVBlockKeys(tokens, modelName)
 	h.logger.Info("found tokens", "tokens", tokens, "block-keys", blockKeys)
 
 	blockHashes := make([]uint64, len(blockKeys))
+	blockTokens := make([][]uint32, len(blockKeys))
 	for i, key := range blockKeys {
 		blockHashes[i] = key.ChunkHash
+		// the blocks are full chunks of the tokens
+		blockTokens[i] = tokens[i*h.blockSize : (i+1)*h.blockSize]
 	}
 
-	return h.blockCache.startRequest(requestID, blockHashes)
+	return h.blockCache.startRequest(requestID, blockHashes, blockTokens, loraID)
+}
+
+// getTokensProcessor returns the tokens processor of the given LoRA adapter (nil for the base model), the
+// LoRA's id is added to the hash seed, so the same tokens are hashed to different blocks in each adapter
+func (h *KVCacheHelper) getTokensProcessor(loraID *int) kvblock.TokenProcessor {
+	if loraID == nil {
+		return h.tokensProcessor
+	}
+	config := kvblock.DefaultTokenProcessorConfig()
+	config.BlockSize = h.blockSize
+	config.HashSeed = fmt.Sprintf("%s-lora-%d", h.hashSeed, *loraID)
+	return kvblock.NewChunkedTokenDatabase(config)
+}
+
+// tokenize returns the tokens of the given prompt segments, the text is tokenized by the model's tokenizer and
+// every token of an image, audio or video is represented by one placeholder token
+func (h *KVCacheHelper) tokenize(segments []openaiserverapi.PromptSegment, modelName string) ([]uint32, error) {
+	tokens := make([]uint32, 0)
+	for _, segment := range segments {
+		if segment.NumOfPlaceholders > 0 {
+			for range segment.NumOfPlaceholders {
+				tokens = append(tokens, segment.Placeholder)
+			}
+			continue
+		}
+		textTokens, _, err := h.tokenizer.Encode(segment.Text, modelName)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, textTokens...)
+	}
+	return tokens, nil
+}
+
+// ResetPrefixCache removes all the blocks from the cache, fails if there are running requests
+func (h *KVCacheHelper) ResetPrefixCache() error {
+	return h.blockCache.reset()
 }
 
 func (h *KVCacheHelper) OnRequestEnd(vllmReq openaiserverapi.CompletionRequest) error {
@@ -31,16 +31,26 @@ type EventAction int
 const (
 	eventActionStore EventAction = iota
 	eventActionRemove
+	eventActionClear
 )
 
 const (
-	BlockStored  = "BlockStored"
-	BlockRemoved = "BlockRemoved"
+	BlockStored      = "BlockStored"
+	BlockRemoved     = "BlockRemoved"
+	AllBlocksCleared = "AllBlocksCleared"
 )
 
 type EventData struct {
 	action     EventAction
 	hashValues []uint64
+	// parentHash is the hash of the parent block of a stored block, nil if the block has no parent
+	parentHash *uint64
+	// tokens are the tokens of a stored block
+	tokens []uint32
+	// blockSize is the number of tokens in a block
+	blockSize int
+	// loraID is the id of the LoRA adapter of a stored block, nil for the base model
+	loraID *int
 }
 
 type KVEventSender struct {
@@ -73,39 +83,22 @@ func (s *KVEventSender) Run(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
-			// Exiting, discard remaining events if any
-			if len(s.batch) > 0 {
-				s.logger.Info("Existing, discard remaining events", "num of events", len(s.batch))
+			// Exiting, publish the remaining events
+			if err := s.flush(); err != nil {
+				return err
 			}
 			return ctx.Err()
 
 		case eventData, ok := <-s.eventChan:
 			if !ok {
-				// Channel closed, discard remaining events and exit
-				if len(s.batch) > 0 {
-					s.logger.Info("Channel closed, discard remaining events", "num of events", len(s.batch))
-				}
-				return nil
+				// Channel closed, publish the remaining events and exit
+				return s.publishPending()
 			}
 
-			// Encode eventData's hash value to msgpack.RawMessage
-			var payload []byte
-			var err error
-
-			switch eventData.action {
-			case eventActionStore:
-				payload, err = msgpack.Marshal(storedToTaggedUnion(kvevents.BlockStored{BlockHashes: eventData.hashValues}))
-			case eventActionRemove:
-				payload, err = msgpack.Marshal(removedToTaggedUnion(kvevents.BlockRemoved{BlockHashes: eventData.hashValues}))
-			default:
-				return fmt.Errorf("invalid event action %d", eventData.action)
-			}
-			if err != nil {
-				return fmt.Errorf("failed to marshal value: %w", err)
+			if err := s.addEvent(eventData); err != nil {
+				return err
 			}
 
-			s.batch = append(s.batch, payload)
-
 			// check if batch is big enough to be sent
 			if len(s.batch) >= s.maxBatchSize {
 				if err := s.publishHelper(ctx); err != nil {
@@ -128,6 +121,75 @@ func (s *KVEventSender) Run(ctx context.Context) error {
 	}
 }
 
+// addEvent encodes the event and adds it to the batch
+func (s *KVEventSender) addEvent(eventData EventData) error {
+	// Encode eventData's hash value to msgpack.RawMessage
+	var payload []byte
+	var err error
+
+	switch eventData.action {
+	case eventActionStore:
+		payload, err = msgpack.Marshal(storedToTaggedUnion(kvevents.BlockStored{
+			BlockHashes:     eventData.hashValues,
+			ParentBlockHash: eventData.parentHash,
+			TokenIds:        eventData.tokens,
+			BlockSize:       eventData.blockSize,
+			LoraID:          eventData.loraID,
+		}))
+	case eventActionRemove:
+		payload, err = msgpack.Marshal(removedToTaggedUnion(kvevents.BlockRemoved{BlockHashes: eventData.hashValues}))
+	case eventActionClear:
+		payload, err = msgpack.Marshal(clearedToTaggedUnion(kvevents.AllBlocksCleared{}))
+	default:
+		return fmt.Errorf("invalid event action %d", eventData.action)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	s.batch = append(s.batch, payload)
+	return nil
+}
+
+// flush publishes the events in the batch and the events that are waiting in the channel,
+// called when the sender stops
+func (s *KVEventSender) flush() error {
+	for {
+		select {
+		case eventData, ok := <-s.eventChan:
+			if !ok {
+				return s.publishPending()
+			}
+			if err := s.addEvent(eventData); err != nil {
+				return err
+			}
+		default:
+			return s.publishPending()
+		}
+	}
+}
+
+// publishPending publishes the pending events in batches of the maximal size
+func (s *KVEventSender) publishPending() error {
+	if len(s.batch) > 0 {
+		s.logger.Info("Publishing pending events", "num of events", len(s.batch))
+	}
+	pending := s.batch
+	for len(pending) > 0 {
+		size := len(pending)
+		if s.maxBatchSize > 0 {
+			size = min(size, s.maxBatchSize)
+		}
+		s.batch = pending[:size]
+		pending = pending[size:]
+		// the context of the sender is cancelled, the events are published without it
+		if err := s.publishHelper(context.Background()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func storedToTaggedUnion(bs kvevents.BlockStored) []any {
 	return []any{
 		BlockStored,
@@ -146,6 +208,12 @@ func removedToTaggedUnion(br kvevents.BlockRemoved) []any {
 	}
 }
 
+func clearedToTaggedUnion(_ kvevents.AllBlocksCleared) []any {
+	return []any{
+		AllBlocksCleared,
+	}
+}
+
 // helper to publish collected batch if not empty
 func (s *KVEventSender) publishHelper(ctx context.Context) error {
 	if len(s.batch) == 0 {
@@ -19,14 +19,19 @@ package kvcache
 import (
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"sync"
+	"strings"
 	"time"
 
+	"github.com/daulet/tokenizers"
 	zmq "github.com/pebbe/zmq4"
 	"github.com/vmihailenco/msgpack/v5"
 
 	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+	"github.com/llm-d/llm-d-kv-cache-manager/pkg/kvcache/kvblock"
 	"github.com/llm-d/llm-d-kv-cache-manager/pkg/kvcache/kvevents"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -40,6 +45,18 @@ const (
 	pubEndpoint = "tcp://localhost:5557"
 )
 
+// testTokenizer splits the input to words, the id of a word is its length
+type testTokenizer struct{}
+
+func (t *testTokenizer) Encode(input, _ string) ([]uint32, []tokenizers.Offset, error) {
+	words := strings.Fields(input)
+	ids := make([]uint32, len(words))
+	for i, word := range words {
+		ids[i] = uint32(len(word))
+	}
+	return ids, nil, nil
+}
+
 type ActionType int
 
 const (
@@ -239,7 +256,7 @@ var _ = Describe("KV cache", Ordered, func() {
 						var err error
 						switch action.action {
 						case actionStartRequest:
-							err = blockCache.startRequest(action.request.id, action.request.blocks)
+							err = blockCache.startRequest(action.request.id, action.request.blocks, nil, nil)
 						case actionFinishRequest:
 							err = blockCache.finishRequest(action.request.id)
 						}
@@ -347,16 +364,16 @@ var _ = Describe("KV cache", Ordered, func() {
 				req4 := testRequest{"req4", []uint64{5, 6}}
 
 				// blocks 1 and 2 stored
-				err = blockCache.startRequest(req1.id, req1.blocks)
+				err = blockCache.startRequest(req1.id, req1.blocks, nil, nil)
 				Expect(err).NotTo(HaveOccurred())
 				// blocks 3 and 4 stored
-				err = blockCache.startRequest(req2.id, req2.blocks)
+				err = blockCache.startRequest(req2.id, req2.blocks, nil, nil)
 				Expect(err).NotTo(HaveOccurred())
 				// no new blocks stored, reuse of 1 and 3
-				err = blockCache.startRequest(req3.id, req3.blocks)
+				err = blockCache.startRequest(req3.id, req3.blocks, nil, nil)
 				Expect(err).NotTo(HaveOccurred())
 				// no space left - should fail
-				err = blockCache.startRequest(req4.id, req4.blocks)
+				err = blockCache.startRequest(req4.id, req4.blocks, nil, nil)
 				Expect(err).To(HaveOccurred())
 
 				err = blockCache.finishRequest(req1.id)
@@ -366,7 +383,7 @@ var _ = Describe("KV cache", Ordered, func() {
 				// now 2 and 4 are not in use
 
 				// blocks 2 and 4 should be removed, and 5 and 6 stored
-				err = blockCache.startRequest(req4.id, req4.blocks)
+				err = blockCache.startRequest(req4.id, req4.blocks, nil, nil)
 				Expect(err).NotTo(HaveOccurred())
 			}()
 
@@ -389,6 +406,265 @@ var _ = Describe("KV cache", Ordered, func() {
 			Expect(storedBlocks).To(Equal(expectedStoredBlocks))
 		})
 
+		It("should publish the pending events when stopped", func() {
+			time.Sleep(300 * time.Millisecond)
+
+			config := &common.Configuration{
+				Port:                  1234,
+				Model:                 "model",
+				ZMQEndpoint:           pubEndpoint,
+				ZMQMaxConnectAttempts: 3,
+			}
+
+			sub, topic := createSub(config)
+			//nolint
+			defer sub.Close()
+
+			publisher, err := common.NewPublisher(config.ZMQEndpoint, config.ZMQMaxConnectAttempts)
+			Expect(err).NotTo(HaveOccurred())
+			eventChan := make(chan EventData, 10)
+			// the events are not published by the batch size or by the delay
+			sender := NewKVEventSender(publisher, topic, eventChan, 100, time.Hour, GinkgoLogr)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- sender.Run(ctx)
+			}()
+
+			// Make sure that the subscriber listens before the events are published
+			time.Sleep(time.Second)
+			eventChan <- EventData{action: eventActionStore, hashValues: []uint64{1}}
+			eventChan <- EventData{action: eventActionStore, hashValues: []uint64{2}}
+			eventChan <- EventData{action: eventActionRemove, hashValues: []uint64{1}}
+			cancel()
+			Expect(<-stopped).To(MatchError(context.Canceled))
+
+			parts, err := sub.RecvMessageBytes(0)
+			Expect(err).NotTo(HaveOccurred())
+			stored, removed := parseEvent(parts, topic, 1)
+			Expect(stored).To(Equal([]uint64{1, 2}))
+			Expect(removed).To(Equal([]uint64{1}))
+		})
+
+		It("should send the fields of the stored blocks", func() {
+			time.Sleep(300 * time.Millisecond)
+
+			config := &common.Configuration{
+				Port:                  1234,
+				Model:                 "model",
+				KVCacheSize:           4,
+				ZMQEndpoint:           pubEndpoint,
+				ZMQMaxConnectAttempts: 3,
+				EventBatchSize:        1,
+				TokenBlockSize:        2,
+			}
+
+			sub, topic := createSub(config)
+			//nolint
+			defer sub.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			wg := sync.WaitGroup{}
+			wg.Add(1)
+
+			blockCache, err := newBlockCache(config, GinkgoLogr)
+			Expect(err).NotTo(HaveOccurred())
+
+			go func() {
+				blockCache.start(ctx)
+				wg.Done()
+			}()
+
+			defer func() {
+				cancel()
+				wg.Wait() // wait for goroutine to exit
+			}()
+
+			// Make sure that the subscriber listens before the events are published
+			time.Sleep(time.Second)
+
+			loraID := 3
+			Expect(blockCache.startRequest(req1ID, []uint64{10, 11}, [][]uint32{{1, 2}, {3, 4}}, &loraID)).To(Succeed())
+			// block 11 is already stored, only block 12 is sent
+			Expect(blockCache.startRequest(req2ID, []uint64{11, 12}, [][]uint32{{3, 4}, {5, 6}}, nil)).To(Succeed())
+
+			events := make([]kvevents.BlockStored, 0)
+			for i := range 3 {
+				parts, err := sub.RecvMessageBytes(0)
+				Expect(err).NotTo(HaveOccurred())
+				events = append(events, parseStoredEvents(parts, topic, uint64(i+1))...)
+			}
+			Expect(events).To(HaveLen(3))
+
+			Expect(events[0].BlockHashes).To(Equal([]uint64{10}))
+			Expect(events[0].ParentBlockHash).To(BeNil())
+			Expect(events[0].TokenIds).To(Equal([]uint32{1, 2}))
+			Expect(events[0].BlockSize).To(Equal(2))
+			Expect(events[0].LoraID).To(HaveValue(Equal(loraID)))
+
+			Expect(events[1].BlockHashes).To(Equal([]uint64{11}))
+			Expect(events[1].ParentBlockHash).To(HaveValue(Equal(uint64(10))))
+			Expect(events[1].TokenIds).To(Equal([]uint32{3, 4}))
+
+			Expect(events[2].BlockHashes).To(Equal([]uint64{12}))
+			Expect(events[2].ParentBlockHash).To(HaveValue(Equal(uint64(11))))
+			Expect(events[2].TokenIds).To(Equal([]uint32{5, 6}))
+			Expect(events[2].LoraID).To(BeNil())
+		})
+
+		It("should clear all the blocks on reset", func() {
+			time.Sleep(300 * time.Millisecond)
+
+			config := &common.Configuration{
+				Port:                  1234,
+				Model:                 "model",
+				KVCacheSize:           4,
+				ZMQEndpoint:           pubEndpoint,
+				ZMQMaxConnectAttempts: 3,
+				EventBatchSize:        1,
+			}
+
+			sub, topic := createSub(config)
+			//nolint
+			defer sub.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			wg := sync.WaitGroup{}
+			wg.Add(1)
+
+			blockCache, err := newBlockCache(config, GinkgoLogr)
+			Expect(err).NotTo(HaveOccurred())
+
+			go func() {
+				blockCache.start(ctx)
+				wg.Done()
+			}()
+
+			defer func() {
+				cancel()
+				wg.Wait() // wait for goroutine to exit
+			}()
+
+			// Make sure that the subscriber listens before the events are published
+			time.Sleep(time.Second)
+
+			Expect(blockCache.startRequest(req1ID, []uint64{1, 2}, nil, nil)).To(Succeed())
+			err = blockCache.reset()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(resetError))
+
+			Expect(blockCache.finishRequest(req1ID)).To(Succeed())
+			Expect(blockCache.reset()).To(Succeed())
+			activeRequests, totalBlocks, _ := blockCache.getStats()
+			Expect(activeRequests).To(Equal(0))
+			Expect(totalBlocks).To(Equal(0))
+
+			tags := make([]string, 0)
+			for i := range 3 {
+				parts, err := sub.RecvMessageBytes(0)
+				Expect(err).NotTo(HaveOccurred())
+				tags = append(tags, parseEventTags(parts, topic, uint64(i+1))...)
+			}
+			Expect(tags).To(Equal([]string{BlockStored, BlockStored, AllBlocksCleared}))
+		})
+	})
+
+	Context("kv cache helper", func() {
+		newChatRequest := func(requestID string, imageURL string, config *common.Configuration) *openaiserverapi.ChatCompletionRequest {
+			body := fmt.Sprintf(`{"model": "model", "messages": [{"role": "user", "content": [
+				{"type": "text", "text": "This is"}, {"type": "image_url", "image_url": {"url": "%s"}}]}]}`, imageURL)
+			var req openaiserverapi.ChatCompletionRequest
+			Expect(json.Unmarshal([]byte(body), &req)).To(Succeed())
+			Expect(req.CountMultimodalTokens(config)).To(Succeed())
+			req.RequestID = requestID
+			return &req
+		}
+
+		config := &common.Configuration{
+			Port:                  1234,
+			Model:                 "model",
+			KVCacheSize:           10,
+			ZMQEndpoint:           pubEndpoint,
+			ZMQMaxConnectAttempts: 3,
+			TokenBlockSize:        2,
+			ImageTokens:           4,
+		}
+
+		newHelper := func() *KVCacheHelper {
+			blockCache, err := newBlockCache(config, GinkgoLogr)
+			Expect(err).NotTo(HaveOccurred())
+			tokenProcConfig := kvblock.DefaultTokenProcessorConfig()
+			tokenProcConfig.BlockSize = config.TokenBlockSize
+			return &KVCacheHelper{
+				tokenizer:       &testTokenizer{},
+				tokensProcessor: kvblock.NewChunkedTokenDatabase(tokenProcConfig),
+				blockSize:       config.TokenBlockSize,
+				blockCache:      blockCache,
+				logger:          GinkgoLogr,
+			}
+		}
+
+		It("should hash the images of chat requests as placeholder tokens", func() {
+			helper := newHelper()
+			blockCache := helper.blockCache
+
+			req1 := newChatRequest(req1ID, "https://example.com/image1.png", config)
+			tokens, err := helper.tokenize(req1.GetPromptSegments(), config.Model)
+			Expect(err).NotTo(HaveOccurred())
+			// two text tokens and one placeholder token for each of the image's tokens
+			Expect(tokens).To(HaveLen(6))
+			Expect(tokens[2:]).To(HaveEach(tokens[2]))
+			Expect(tokens[2]).NotTo(BeElementOf(tokens[:2]))
+
+			Expect(helper.OnRequestStart(req1, nil)).To(Succeed())
+			_, totalBlocks, _ := blockCache.getStats()
+			Expect(totalBlocks).To(Equal(3))
+
+			// the same text with a different image shares only the text's block
+			req2 := newChatRequest(req2ID, "https://example.com/image2.png", config)
+			Expect(helper.OnRequestStart(req2, nil)).To(Succeed())
+			_, totalBlocks, _ = blockCache.getStats()
+			Expect(totalBlocks).To(Equal(5))
+
+			// the same image is hashed to the same blocks
+			req3 := newChatRequest(req3ID, "https://example.com/image1.png", config)
+			Expect(helper.OnRequestStart(req3, nil)).To(Succeed())
+			_, totalBlocks, _ = blockCache.getStats()
+			Expect(totalBlocks).To(Equal(5))
+		})
+
+		It("should hash the blocks of LoRA adapters separately", func() {
+			helper := newHelper()
+			newTextRequest := func(requestID string) *openaiserverapi.TextCompletionRequest {
+				var req openaiserverapi.TextCompletionRequest
+				Expect(json.Unmarshal([]byte(`{"model": "model", "prompt": "This is a test"}`), &req)).To(Succeed())
+				req.RequestID = requestID
+				return &req
+			}
+			lora1 := 1
+			lora2 := 2
+
+			Expect(helper.OnRequestStart(newTextRequest(req1ID), nil)).To(Succeed())
+			_, totalBlocks, _ := helper.blockCache.getStats()
+			Expect(totalBlocks).To(Equal(2))
+
+			// the same prompt in a LoRA adapter is stored in new blocks
+			Expect(helper.OnRequestStart(newTextRequest(req2ID), &lora1)).To(Succeed())
+			_, totalBlocks, _ = helper.blockCache.getStats()
+			Expect(totalBlocks).To(Equal(4))
+
+			Expect(helper.OnRequestStart(newTextRequest(req3ID), &lora2)).To(Succeed())
+			_, totalBlocks, _ = helper.blockCache.getStats()
+			Expect(totalBlocks).To(Equal(6))
+
+			// the blocks of the same adapter are reused
+			Expect(helper.OnRequestStart(newTextRequest("req4"), &lora1)).To(Succeed())
+			_, totalBlocks, _ = helper.blockCache.getStats()
+			Expect(totalBlocks).To(Equal(6))
+		})
 	})
 
 	Context("thread safety", func() {
@@ -435,7 +711,7 @@ var _ = Describe("KV cache", Ordered, func() {
 							reqID := fmt.Sprintf("req_%d_%d", id, j)
 							blocks := createRandomArray(testCase.minBlockLen, testCase.maxBlockLen, testCase.maxHashValue)
 
-							err := blockCache.startRequest(reqID, blocks)
+							err := blockCache.startRequest(reqID, blocks, nil, nil)
 							if err != nil {
 								// some operations may fail due to cache being full, which is expected
 								Expect(err.Error()).To(Equal(capacityError))
@@ -483,6 +759,50 @@ func createRandomArray(minArrLen, maxArrLen int, maxValue uint64) []uint64 {
 	return arr
 }
 
+// parseStoredEvents returns the BlockStored events in the message
+func parseStoredEvents(parts [][]byte, expectedTopic string, expectedSeq uint64) []kvevents.BlockStored {
+	Expect(parts).To(HaveLen(3))
+	Expect(string(parts[0])).To(Equal(expectedTopic))
+	Expect(binary.BigEndian.Uint64(parts[1])).To(Equal(expectedSeq))
+
+	var eventBatch kvevents.EventBatch
+	Expect(msgpack.Unmarshal(parts[2], &eventBatch)).To(Succeed())
+	events := make([]kvevents.BlockStored, 0, len(eventBatch.Events))
+	for _, rawEvent := range eventBatch.Events {
+		var taggedUnion []msgpack.RawMessage
+		Expect(msgpack.Unmarshal(rawEvent, &taggedUnion)).To(Succeed())
+		var tag string
+		Expect(msgpack.Unmarshal(taggedUnion[0], &tag)).To(Succeed())
+		Expect(tag).To(Equal(BlockStored))
+		payloadBytes, err := msgpack.Marshal(taggedUnion[1:])
+		Expect(err).NotTo(HaveOccurred())
+		var event kvevents.BlockStored
+		Expect(msgpack.Unmarshal(payloadBytes, &event)).To(Succeed())
+		events = append(events, event)
+	}
+	return events
+}
+
+// parseEventTags returns the tags of the events in the message
+func parseEventTags(parts [][]byte, expectedTopic string, expectedSeq uint64) []string {
+	Expect(parts).To(HaveLen(3))
+	Expect(string(parts[0])).To(Equal(expectedTopic))
+	Expect(binary.BigEndian.Uint64(parts[1])).To(Equal(expectedSeq))
+
+	var eventBatch kvevents.EventBatch
+	Expect(msgpack.Unmarshal(parts[2], &eventBatch)).To(Succeed())
+	tags := make([]string, 0, len(eventBatch.Events))
+	for _, rawEvent := range eventBatch.Events {
+		var taggedUnion []msgpack.RawMessage
+		Expect(msgpack.Unmarshal(rawEvent, &taggedUnion)).To(Succeed())
+		Expect(taggedUnion).NotTo(BeEmpty())
+		var tag string
+		Expect(msgpack.Unmarshal(taggedUnion[0], &tag)).To(Succeed())
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
 func parseEvent(parts [][]byte, expectedTopic string, expectedSeq uint64) ([]uint64, []uint64) {
 	// The message should be [topic, seq, payload]
 	Expect(parts).To(HaveLen(3))
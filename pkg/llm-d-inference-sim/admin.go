@@ -0,0 +1,100 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains functions related to the admin API, which changes the configuration at runtime
+
+package llmdinferencesim
+
+import (
+	"crypto/subtle"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+// HandleGetAdminConfig http handler for GET /admin/config, returns the current configuration
+func (s *VllmSimulator) HandleGetAdminConfig(ctx *fasthttp.RequestCtx) {
+	if !s.isAdminAuthorized(ctx) {
+		return
+	}
+	s.sendConfig(ctx, s.getConfig())
+}
+
+// HandlePatchAdminConfig http handler for PATCH /admin/config, changes the parameters in the
+// request's body, a JSON object of parameters and their new values, and returns the new configuration
+func (s *VllmSimulator) HandlePatchAdminConfig(ctx *fasthttp.RequestCtx) {
+	if !s.isAdminAuthorized(ctx) {
+		return
+	}
+	s.logger.Info("admin configuration change received", "changes", string(ctx.Request.Body()))
+
+	// changes are applied one at a time, so concurrent changes are not lost
+	s.adminMutex.Lock()
+	defer s.adminMutex.Unlock()
+
+	config, err := s.getConfig().WithRuntimeChanges(ctx.Request.Body())
+	if err != nil {
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(err.Error(), fasthttp.StatusBadRequest, nil), false)
+		return
+	}
+	s.updateConfig(config)
+	s.sendConfig(ctx, config)
+}
+
+// isAdminAuthorized checks that the request has the admin API key as a bearer token,
+// sends an authentication error if not
+func (s *VllmSimulator) isAdminAuthorized(ctx *fasthttp.RequestCtx) bool {
+	key, found := getBearerToken(ctx)
+	if !found || subtle.ConstantTimeCompare([]byte(key), []byte(s.getConfig().AdminAPIKey)) != 1 {
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError("Invalid admin API key",
+			fasthttp.StatusUnauthorized, nil), false)
+		return false
+	}
+	return true
+}
+
+func (s *VllmSimulator) sendConfig(ctx *fasthttp.RequestCtx, config *common.Configuration) {
+	data, err := configToJSON(config)
+	if err != nil {
+		s.logger.Error(err, "Failed to marshal configuration")
+		ctx.Error("Failed to marshal configuration, "+err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+	ctx.Response.SetBody(data)
+}
+
+// updateConfig replaces the simulator's configuration, and updates the LoRA adapters, the queue's
+// limits, the number of workers and the fake metrics accordingly
+func (s *VllmSimulator) updateConfig(config *common.Configuration) {
+	s.configMutex.Lock()
+	previous := s.config
+	s.config = config
+	s.modelConfigs = config.ModelConfigs()
+	s.baseModelNames = config.BaseModelNames()
+	s.configMutex.Unlock()
+
+	s.updateLoras(previous.LoraModules, config.LoraModules)
+	s.queue.setLimits(config)
+	s.addWorkers(config.MaxNumSeqs)
+	// the real metrics are reported again on their next change
+	if s.runningRequests != nil && (config.FakeMetrics != nil || previous.FakeMetrics != nil) {
+		s.setInitialPrometheusMetrics()
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+const adminKey = "admin-secret"
+
+func sendAdminRequest(client *http.Client, method string, key string, body string) (int, string) {
+	req, err := http.NewRequest(method, "http://localhost/admin/config", strings.NewReader(body))
+	Expect(err).NotTo(HaveOccurred())
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := client.Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	return resp.StatusCode, readBody(resp)
+}
+
+func sendAdminTestCompletion(client *http.Client) (int, string) {
+	reqBody := `{"prompt": "` + userMessage + `", "model": "` + model + `", "max_tokens": 10}`
+	resp, err := client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+	Expect(err).NotTo(HaveOccurred())
+	return resp.StatusCode, readBody(resp)
+}
+
+var _ = Describe("Admin API", func() {
+	It("Should be disabled when the admin API key is not set", func() {
+		client, err := startServer(context.TODO(), common.ModeRandom)
+		Expect(err).NotTo(HaveOccurred())
+
+		status, _ := sendAdminRequest(client, http.MethodGet, adminKey, "")
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should require the admin API key", func() {
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--admin-api-key", adminKey}
+		client, err := startServerWithArgs(context.TODO(), common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		status, body := sendAdminRequest(client, http.MethodGet, "", "")
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		Expect(body).To(ContainSubstring("AuthenticationError"))
+		status, _ = sendAdminRequest(client, http.MethodPatch, "wrong", `{"mode": "echo"}`)
+		Expect(status).To(Equal(http.StatusUnauthorized))
+
+		status, body = sendAdminRequest(client, http.MethodGet, adminKey, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring(`"mode": "random"`))
+		Expect(body).NotTo(ContainSubstring(adminKey))
+	})
+
+	It("Should change the configuration at runtime", func() {
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--admin-api-key", adminKey}
+		_, client, err := startServerWithArgsAndMetrics(context.TODO(), common.ModeRandom, args, nil, true)
+		Expect(err).NotTo(HaveOccurred())
+
+		status, body := sendAdminRequest(client, http.MethodPatch, adminKey, `{"mode": "echo", "time-to-first-token": 10}`)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring(`"mode": "echo"`))
+		Expect(body).To(ContainSubstring(`"time-to-first-token": 10`))
+		status, body = sendAdminTestCompletion(client)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring(userMessage))
+
+		status, _ = sendAdminRequest(client, http.MethodPatch, adminKey,
+			`{"failure-injection-rate": 100, "failure-types": ["rate_limit"]}`)
+		Expect(status).To(Equal(http.StatusOK))
+		status, _ = sendAdminTestCompletion(client)
+		Expect(status).To(Equal(http.StatusTooManyRequests))
+
+		status, _ = sendAdminRequest(client, http.MethodPatch, adminKey,
+			`{"failure-injection-rate": 0, "fake-metrics": {"running-requests": 7, "waiting-requests": 3, "kv-cache-usage": 0.5}}`)
+		Expect(status).To(Equal(http.StatusOK))
+		resp, err := client.Get("http://localhost/metrics")
+		Expect(err).NotTo(HaveOccurred())
+		metrics := readBody(resp)
+		Expect(metrics).To(ContainSubstring("vllm:num_requests_running{model_name=\"my_model\"} 7"))
+		Expect(metrics).To(ContainSubstring("vllm:num_requests_waiting{model_name=\"my_model\"} 3"))
+	})
+
+	It("Should change max-num-seqs at runtime", func() {
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--admin-api-key", adminKey,
+			"--max-num-seqs", "1", "--time-to-first-token", "500"}
+		client, err := startServerWithArgs(context.TODO(), common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		status, _ := sendAdminRequest(client, http.MethodPatch, adminKey, `{"max-num-seqs": 2}`)
+		Expect(status).To(Equal(http.StatusOK))
+
+		// the two requests run in parallel
+		start := time.Now()
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				status, _ := sendAdminTestCompletion(client)
+				Expect(status).To(Equal(http.StatusOK))
+			}()
+		}
+		wg.Wait()
+		Expect(time.Since(start)).To(BeNumerically("<", 900*time.Millisecond))
+	})
+
+	It("Should unload a removed LoRA adapter when its requests finish", func() {
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--admin-api-key", adminKey,
+			"--time-to-first-token", "500", "--lora-modules", `{"name":"lora1","path":"/path/to/lora1"}`}
+		s, client, err := startServerWithArgsAndMetrics(context.TODO(), common.ModeRandom, args, nil, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer GinkgoRecover()
+			resp, err := client.Post("http://localhost/v1/completions", "application/json",
+				strings.NewReader(`{"prompt": "This is a test.", "model": "lora1", "max_tokens": 2}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			_ = readBody(resp)
+		}()
+
+		time.Sleep(200 * time.Millisecond)
+		status, _ := sendAdminRequest(client, http.MethodPatch, adminKey, `{"lora-modules": []}`)
+		Expect(status).To(Equal(http.StatusOK))
+		// the adapter is in use
+		Expect(s.isLora("lora1")).To(BeTrue())
+
+		<-done
+		Expect(s.isLora("lora1")).To(BeFalse())
+	})
+
+	It("Should reject invalid changes", func() {
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--admin-api-key", adminKey}
+		client, err := startServerWithArgs(context.TODO(), common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		status, body := sendAdminRequest(client, http.MethodPatch, adminKey, `{"failure-injection-rate": 200}`)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body).To(ContainSubstring("failure injection rate should be between 0 and 100"))
+
+		status, body = sendAdminRequest(client, http.MethodPatch, adminKey, `{"port": 9000}`)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body).To(ContainSubstring("parameter 'port' cannot be changed at runtime"))
+
+		status, _ = sendAdminRequest(client, http.MethodPatch, adminKey, `{"mode": `)
+		Expect(status).To(Equal(http.StatusBadRequest))
+
+		// the configuration is not changed
+		status, body = sendAdminRequest(client, http.MethodGet, adminKey, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring(`"failure-injection-rate": 0`))
+	})
+})
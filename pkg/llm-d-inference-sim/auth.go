@@ -0,0 +1,78 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains functions related to the authentication of requests by their bearer tokens
+
+package llmdinferencesim
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+const (
+	bearerPrefix = "Bearer "
+	// apiPathPrefix is the path prefix of the API that requires an API key
+	apiPathPrefix        = "/v1/"
+	missingAPIKeyMessage = "You didn't provide an API key. You need to provide your API key in an Authorization header using Bearer auth (i.e. Authorization: Bearer YOUR_KEY)."
+)
+
+// authenticate returns a handler that checks that requests to the /v1 API have one of the API keys
+// as a bearer token, if API keys are configured, before calling the given handler
+func (s *VllmSimulator) authenticate(handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if strings.HasPrefix(string(ctx.Path()), apiPathPrefix) && !s.isAPIAuthorized(ctx) {
+			return
+		}
+		handler(ctx)
+	}
+}
+
+// isAPIAuthorized checks that the request has one of the API keys as a bearer token, if API keys
+// are configured, sends an authentication error if not
+func (s *VllmSimulator) isAPIAuthorized(ctx *fasthttp.RequestCtx) bool {
+	apiKeys := s.getConfig().GetAPIKeys()
+	if len(apiKeys) == 0 {
+		return true
+	}
+	key, found := getBearerToken(ctx)
+	if !found || key == "" {
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(missingAPIKeyMessage,
+			fasthttp.StatusUnauthorized, nil), false)
+		return false
+	}
+	authorized := false
+	for _, apiKey := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			authorized = true
+		}
+	}
+	if !authorized {
+		s.sendCompletionError(ctx, predefinedFailures[common.FailureTypeInvalidAPIKey], false)
+	}
+	return authorized
+}
+
+// getBearerToken returns the bearer token of the request's authorization header, and false if there is none
+func getBearerToken(ctx *fasthttp.RequestCtx) (string, bool) {
+	auth := string(ctx.Request.Header.Peek(fasthttp.HeaderAuthorization))
+	return strings.CutPrefix(auth, bearerPrefix)
+}
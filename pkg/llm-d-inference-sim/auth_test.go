@@ -0,0 +1,99 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+var _ = Describe("API key authentication", func() {
+	sendChat := func(client *http.Client, apiKey string) error {
+		opts := []option.RequestOption{option.WithBaseURL(baseURL), option.WithHTTPClient(client)}
+		if apiKey != "" {
+			opts = append(opts, option.WithAPIKey(apiKey))
+		}
+		openaiClient := openai.NewClient(opts...)
+		_, err := openaiClient.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+			Model:    model,
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(userMessage)},
+		})
+		return err
+	}
+
+	expectUnauthorized := func(err error, message string) {
+		Expect(err).To(HaveOccurred())
+		var openaiError *openai.Error
+		Expect(errors.As(err, &openaiError)).To(BeTrue())
+		Expect(openaiError.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(openaiError.Message).To(ContainSubstring(message))
+	}
+
+	It("should authorize requests with one of the API keys", func() {
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--api-key", "key1", "key2"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		expectUnauthorized(sendChat(client, ""), "You didn't provide an API key")
+		expectUnauthorized(sendChat(client, "key3"), "Incorrect API key provided")
+		Expect(sendChat(client, "key1")).To(Succeed())
+		Expect(sendChat(client, "key2")).To(Succeed())
+
+		resp, err := client.Get("http://localhost/v1/models")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		_ = readBody(resp)
+
+		// health, readiness and metrics are not authenticated
+		for _, path := range []string{"/health", "/ready", "/metrics"} {
+			resp, err := client.Get("http://localhost" + path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			_ = readBody(resp)
+		}
+	})
+
+	It("should authorize requests with the keys of the API key file", func() {
+		keyFile := filepath.Join(GinkgoT().TempDir(), "keys")
+		Expect(os.WriteFile(keyFile, []byte("# test keys\nfile-key1\n\n  file-key2  \n"), 0o600)).To(Succeed())
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--api-key-file", keyFile}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		expectUnauthorized(sendChat(client, "# test keys"), "Incorrect API key provided")
+		Expect(sendChat(client, "file-key1")).To(Succeed())
+		Expect(sendChat(client, "file-key2")).To(Succeed())
+	})
+
+	It("should not authenticate requests without API keys", func() {
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sendChat(client, "")).To(Succeed())
+		Expect(sendChat(client, "any")).To(Succeed())
+	})
+})
@@ -0,0 +1,96 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains the draining of requests on shutdown
+
+package llmdinferencesim
+
+import (
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+const (
+	// drainPollInterval is the interval of checking whether the requests were drained
+	drainPollInterval = 50 * time.Millisecond
+	// shutdownTimeout is the time to wait for the connections to close after the requests were drained
+	shutdownTimeout     = 5 * time.Second
+	shuttingDownMessage = "The server is shutting down"
+)
+
+// drainHandler returns a handler that rejects requests to the /v1 API with 503 while the simulator
+// is draining, before calling the given handler
+func (s *VllmSimulator) drainHandler(handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if s.draining.Load() && strings.HasPrefix(string(ctx.Path()), apiPathPrefix) {
+			s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(shuttingDownMessage,
+				fasthttp.StatusServiceUnavailable, nil), false)
+			return
+		}
+		handler(ctx)
+	}
+}
+
+// drain stops accepting new requests and waits for the running and waiting requests to finish
+// within the shutdown grace period, the remaining requests are aborted
+func (s *VllmSimulator) drain() {
+	s.draining.Store(true)
+	gracePeriod := time.Duration(s.getConfig().ShutdownGracePeriod) * time.Millisecond
+	s.logger.Info("Draining requests", "grace period", gracePeriod)
+	deadline := time.Now().Add(gracePeriod)
+	for {
+		running, waiting, _, _ := s.queue.status()
+		if running+waiting == 0 {
+			s.logger.Info("All the requests were drained")
+			return
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		time.Sleep(min(remaining, drainPollInterval))
+	}
+	s.abortRequests()
+}
+
+// abortRequests fails the waiting requests with 503, and signals the scheduled requests to stop waiting
+// and closes their connections, so their processing finishes without waiting for their latencies
+func (s *VllmSimulator) abortRequests() {
+	aborted := s.queue.abortWaiting()
+	for _, request := range aborted {
+		model := request.model()
+		s.waitingReqChan <- requestsUpdate{s.getBaseModelName(model), -1}
+		if request.lora != "" {
+			s.lorasChan <- loraUsage{model, abortedUsageState}
+		}
+		s.sendCompletionError(request.reqCtx.HTTPReqCtx, openaiserverapi.NewCompletionError(shuttingDownMessage,
+			fasthttp.StatusServiceUnavailable, nil), false)
+		request.reqCtx.Wg.Done()
+	}
+
+	scheduled := s.queue.scheduledRequests()
+	for _, request := range scheduled {
+		request.abort()
+		if err := request.reqCtx.HTTPReqCtx.Conn().Close(); err != nil {
+			s.logger.Error(err, "failed to close connection of aborted request")
+		}
+	}
+	s.logger.Info("Aborted requests", "waiting", len(aborted), "running", len(scheduled))
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+var _ = Describe("Drain", func() {
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+
+	sendCompletion := func(client *http.Client) result {
+		resp, err := client.Post("http://localhost/v1/completions", "application/json",
+			strings.NewReader(`{"prompt": "This is a test", "model": "my_model", "max_tokens": 2}`))
+		if err != nil {
+			return result{err: err}
+		}
+		return result{status: resp.StatusCode, body: readBody(resp)}
+	}
+
+	// startRequests sends the given number of requests in the background, and returns a channel
+	// of their results
+	startRequests := func(client *http.Client, n int) chan result {
+		results := make(chan result, n)
+		for range n {
+			go func() {
+				results <- sendCompletion(client)
+			}()
+		}
+		// let the requests arrive
+		time.Sleep(100 * time.Millisecond)
+		return results
+	}
+
+	It("should finish the requests within the grace period", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		client, err := startServerWithArgs(ctx, common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--max-num-seqs", "1",
+				"--time-to-first-token", "500", "--shutdown-grace-period", "5000"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		results := startRequests(client, 2)
+		cancel()
+		time.Sleep(100 * time.Millisecond)
+
+		// new requests are rejected and the simulator is not ready while draining
+		rejected := sendCompletion(client)
+		Expect(rejected.err).NotTo(HaveOccurred())
+		Expect(rejected.status).To(Equal(http.StatusServiceUnavailable))
+		Expect(rejected.body).To(ContainSubstring(shuttingDownMessage))
+		resp, err := client.Get("http://localhost/ready")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		_ = readBody(resp)
+
+		for range 2 {
+			res := <-results
+			Expect(res.err).NotTo(HaveOccurred())
+			Expect(res.status).To(Equal(http.StatusOK))
+		}
+	})
+
+	It("should abort the requests after the grace period", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		client, err := startServerWithArgs(ctx, common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--max-num-seqs", "1",
+				"--time-to-first-token", "2000", "--shutdown-grace-period", "200"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		results := startRequests(client, 2)
+		cancel()
+
+		// the waiting request fails with 503 and the connection of the running request is closed
+		var aborted, closed int
+		for range 2 {
+			res := <-results
+			if res.err != nil {
+				closed++
+			} else {
+				Expect(res.status).To(Equal(http.StatusServiceUnavailable))
+				Expect(res.body).To(ContainSubstring(shuttingDownMessage))
+				aborted++
+			}
+		}
+		Expect(aborted).To(Equal(1))
+		Expect(closed).To(Equal(1))
+	})
+
+	It("should stop the processing of the aborted requests", func() {
+		config := common.NewDefaultConfig()
+		config.Model = model
+		config.Port = 0
+		config.TimeToFirstToken = 10000
+		config.ShutdownGracePeriod = 200
+		sim, err := NewWithConfig(config)
+		Expect(err).NotTo(HaveOccurred())
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- sim.Start(context.Background())
+		}()
+
+		go func() {
+			resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d/v1/completions", sim.Addr().(*net.TCPAddr).Port),
+				"application/json", strings.NewReader(`{"prompt": "This is a test", "model": "my_model", "max_tokens": 2}`))
+			if err == nil {
+				_ = readBody(resp)
+			}
+		}()
+		time.Sleep(200 * time.Millisecond)
+
+		// the running request does not wait for its time to first token
+		start := time.Now()
+		sim.Stop()
+		Expect(time.Since(start)).To(BeNumerically("<", shutdownTimeout))
+		Expect(<-stopped).NotTo(HaveOccurred())
+	})
+})
@@ -54,14 +54,19 @@ func shouldInjectFailure(config *common.Configuration) bool {
 
 // getRandomFailure returns a random failure from configured types or all types if none specified
 func getRandomFailure(config *common.Configuration) openaiserverapi.CompletionError {
+	return getRandomFailureOfTypes(config, config.FailureTypes)
+}
+
+// getRandomFailureOfTypes returns a random failure from the given types or all types if none specified
+func getRandomFailureOfTypes(config *common.Configuration, failureTypes []string) openaiserverapi.CompletionError {
 	var availableFailures []string
-	if len(config.FailureTypes) == 0 {
+	if len(failureTypes) == 0 {
 		// Use all failure types if none specified
 		for failureType := range predefinedFailures {
 			availableFailures = append(availableFailures, failureType)
 		}
 	} else {
-		availableFailures = config.FailureTypes
+		availableFailures = failureTypes
 	}
 
 	if len(availableFailures) == 0 {
@@ -70,19 +75,126 @@ func getRandomFailure(config *common.Configuration) openaiserverapi.CompletionEr
 	}
 
 	randomIndex := common.RandomInt(0, len(availableFailures)-1)
-	randomType := availableFailures[randomIndex]
+	return getFailure(config, availableFailures[randomIndex])
+}
 
+// getFailure returns the failure of the given type
+func getFailure(config *common.Configuration, failureType string) openaiserverapi.CompletionError {
 	// Customize message with current model name
-	failure := predefinedFailures[randomType]
-	if randomType == common.FailureTypeRateLimit && config.Model != "" {
+	failure := predefinedFailures[failureType]
+	if failureType == common.FailureTypeRateLimit && config.Model != "" {
 		failure.Message = fmt.Sprintf(rateLimitMessageTemplate, config.Model)
-	} else if randomType == common.FailureTypeModelNotFound && config.Model != "" {
+	} else if failureType == common.FailureTypeModelNotFound && config.Model != "" {
 		failure.Message = fmt.Sprintf(modelNotFoundMessageTemplate, config.Model)
 	}
 
 	return failure
 }
 
+// streamFailure is a failure injected in the middle of a streamed response
+type streamFailure struct {
+	// failureType is the type of the failure, one of the streaming failure types
+	failureType string
+	// afterTokens is the number of tokens sent before the failure is injected
+	afterTokens int
+	// stallTime is the time in milliseconds a stalled stream is kept open
+	stallTime int
+}
+
+// streamFailureError is the error sent in an error event in the middle of a streamed response
+var streamFailureError = openaiserverapi.NewCompletionError("The server had an error while processing your request.",
+	500, nil)
+
+// getStreamFailure returns the failure to inject in a streamed response with the given number of tokens,
+// or nil if no failure should be injected
+func getStreamFailure(config *common.Configuration, numOfTokens int) *streamFailure {
+	if config.StreamFailureInjectionRate == 0 || common.RandomInt(1, 100) > config.StreamFailureInjectionRate {
+		return nil
+	}
+
+	availableFailures := config.StreamFailureTypes
+	if len(availableFailures) == 0 {
+		availableFailures = common.StreamFailureTypes
+	}
+	return newStreamFailure(config, availableFailures[common.RandomInt(0, len(availableFailures)-1)], numOfTokens)
+}
+
+// newStreamFailure creates a streaming failure of the given type in a streamed response with the given
+// number of tokens
+func newStreamFailure(config *common.Configuration, failureType string, numOfTokens int) *streamFailure {
+	failure := streamFailure{
+		failureType: failureType,
+		afterTokens: config.StreamFailureAfterTokens,
+		stallTime:   config.StreamStallTime,
+	}
+	if failure.afterTokens == 0 && numOfTokens > 0 {
+		failure.afterTokens = common.RandomInt(1, numOfTokens)
+	}
+	return &failure
+}
+
+const (
+	// Latency fault types
+	latencyFaultHang      = "hang"
+	latencyFaultTTFTSpike = "ttft_spike"
+	latencyFaultStutter   = "stutter"
+	latencyFaultSlowDrip  = "slow_drip"
+	// stutterTokenProbability is the probability (0-100) of a gap before a token in a stuttering response
+	stutterTokenProbability = 20
+)
+
+// latencyFaultTypes are all the types of latency faults
+var latencyFaultTypes = []string{latencyFaultHang, latencyFaultTTFTSpike, latencyFaultStutter, latencyFaultSlowDrip}
+
+// latencyFault is a latency fault injected in a request
+type latencyFault struct {
+	// faultType is the type of the fault, one of the latency fault types
+	faultType string
+	// config is the configuration of the request's model
+	config *common.Configuration
+}
+
+// getLatencyFault returns the latency fault to inject in a request, or nil if no fault should be injected,
+// at most one fault is injected in a request
+func getLatencyFault(config *common.Configuration) *latencyFault {
+	faults := []struct {
+		faultType string
+		rate      int
+	}{
+		{latencyFaultHang, config.HangInjectionRate},
+		{latencyFaultTTFTSpike, config.TTFTSpikeInjectionRate},
+		{latencyFaultStutter, config.StutterInjectionRate},
+		{latencyFaultSlowDrip, config.SlowDripInjectionRate},
+	}
+	for _, fault := range faults {
+		if fault.rate > 0 && common.RandomInt(1, 100) <= fault.rate {
+			return &latencyFault{faultType: fault.faultType, config: config}
+		}
+	}
+	return nil
+}
+
+// timeToFirstToken returns the time to first token of a request with the fault
+func (f *latencyFault) timeToFirstToken(ttft int) int {
+	if f.faultType == latencyFaultTTFTSpike {
+		return int(float64(ttft) * f.config.TTFTSpikeMultiplier)
+	}
+	return ttft
+}
+
+// interTokenLatency returns the latency of a token of a request with the fault
+func (f *latencyFault) interTokenLatency(itl int) int {
+	switch f.faultType {
+	case latencyFaultStutter:
+		if common.RandomInt(1, 100) <= stutterTokenProbability {
+			return itl + common.RandomInt(0, f.config.StutterMaxGap)
+		}
+	case latencyFaultSlowDrip:
+		return max(itl, f.config.SlowDripInterTokenLatency)
+	}
+	return itl
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
@@ -18,7 +18,9 @@ package llmdinferencesim
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -111,6 +113,188 @@ var _ = Describe("Failures", func() {
 			Expect(failure.Type).ToNot(BeEmpty())
 		})
 	})
+	Describe("getStreamFailure", Ordered, func() {
+		BeforeAll(func() {
+			common.InitRandom(time.Now().UnixNano())
+		})
+
+		It("should not return a failure when the rate is 0", func() {
+			config := &common.Configuration{StreamFailureInjectionRate: 0}
+			Expect(getStreamFailure(config, 10)).To(BeNil())
+		})
+
+		It("should return a failure of the configured type after the configured tokens", func() {
+			config := &common.Configuration{
+				StreamFailureInjectionRate: 100,
+				StreamFailureTypes:         []string{common.StreamFailureTypeStall},
+				StreamFailureAfterTokens:   3,
+				StreamStallTime:            500,
+			}
+			failure := getStreamFailure(config, 10)
+			Expect(failure).NotTo(BeNil())
+			Expect(failure.failureType).To(Equal(common.StreamFailureTypeStall))
+			Expect(failure.afterTokens).To(Equal(3))
+			Expect(failure.stallTime).To(Equal(500))
+		})
+
+		It("should return a failure within the response tokens", func() {
+			config := &common.Configuration{StreamFailureInjectionRate: 100}
+			for range 20 {
+				failure := getStreamFailure(config, 5)
+				Expect(failure).NotTo(BeNil())
+				Expect(common.StreamFailureTypes).To(ContainElement(failure.failureType))
+				Expect(failure.afterTokens).To(BeNumerically(">=", 1))
+				Expect(failure.afterTokens).To(BeNumerically("<=", 5))
+			}
+		})
+	})
+
+	Describe("Simulator with stream failure injection", func() {
+		const streamRequest = `{"prompt": "This is a test of stream failures", "model": "my_model", "stream": true}`
+
+		sendStreamRequest := func(failureType string) (string, error, time.Duration) {
+			ctx := context.Background()
+			client, err := startServerWithArgs(ctx, common.ModeEcho, []string{
+				"cmd", "--model", model, "--mode", common.ModeEcho,
+				"--stream-failure-injection-rate", "100",
+				"--stream-failure-types", failureType,
+				"--stream-failure-after-tokens", "2",
+				"--stream-stall-time", "500",
+			}, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			start := time.Now()
+			resp, err := client.Post("http://localhost/v1/completions", "application/json",
+				strings.NewReader(streamRequest))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			body, err := io.ReadAll(resp.Body)
+			return string(body), err, time.Since(start)
+		}
+
+		It("should close the connection after the configured tokens", func() {
+			body, err, _ := sendStreamRequest(common.StreamFailureTypeConnectionClose)
+			Expect(err).To(HaveOccurred())
+			Expect(strings.Count(body, `"text"`)).To(Equal(2))
+			Expect(body).NotTo(ContainSubstring("[DONE]"))
+		})
+
+		It("should send an error event after the configured tokens", func() {
+			body, err, _ := sendStreamRequest(common.StreamFailureTypeErrorEvent)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Count(body, `"text"`)).To(Equal(2))
+			Expect(body).To(ContainSubstring(`data: {"error":`))
+			Expect(body).To(HaveSuffix("data: [DONE]\n\n"))
+		})
+
+		It("should stall the stream without sending done", func() {
+			body, err, duration := sendStreamRequest(common.StreamFailureTypeStall)
+			Expect(err).To(HaveOccurred())
+			Expect(duration).To(BeNumerically(">=", 500*time.Millisecond))
+			Expect(strings.Count(body, `"text"`)).To(Equal(2))
+			Expect(body).NotTo(ContainSubstring("[DONE]"))
+		})
+
+		It("should send a malformed chunk and continue the stream", func() {
+			body, err, _ := sendStreamRequest(common.StreamFailureTypeMalformedChunk)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(body).To(HaveSuffix("data: [DONE]\n\n"))
+			malformed := 0
+			for _, line := range strings.Split(body, "\n") {
+				data, found := strings.CutPrefix(line, "data: ")
+				if !found || data == "[DONE]" {
+					continue
+				}
+				var chunk map[string]any
+				if json.Unmarshal([]byte(data), &chunk) != nil {
+					malformed++
+				}
+			}
+			Expect(malformed).To(Equal(1))
+		})
+	})
+
+	Describe("getLatencyFault", Ordered, func() {
+		BeforeAll(func() {
+			common.InitRandom(time.Now().UnixNano())
+		})
+
+		It("should not return a fault when all the rates are 0", func() {
+			Expect(getLatencyFault(&common.Configuration{})).To(BeNil())
+		})
+
+		It("should multiply the time to first token of a spike", func() {
+			config := &common.Configuration{TTFTSpikeInjectionRate: 100, TTFTSpikeMultiplier: 3}
+			fault := getLatencyFault(config)
+			Expect(fault).NotTo(BeNil())
+			Expect(fault.faultType).To(Equal(latencyFaultTTFTSpike))
+			Expect(fault.timeToFirstToken(100)).To(Equal(300))
+			Expect(fault.interTokenLatency(10)).To(Equal(10))
+		})
+
+		It("should add gaps between the tokens of a stuttering response", func() {
+			config := &common.Configuration{StutterInjectionRate: 100, StutterMaxGap: 1000}
+			fault := getLatencyFault(config)
+			Expect(fault).NotTo(BeNil())
+			Expect(fault.faultType).To(Equal(latencyFaultStutter))
+			Expect(fault.timeToFirstToken(100)).To(Equal(100))
+			for range 100 {
+				Expect(fault.interTokenLatency(10)).To(And(BeNumerically(">=", 10), BeNumerically("<=", 1010)))
+			}
+		})
+
+		It("should slow down the tokens of a slow drip response", func() {
+			config := &common.Configuration{SlowDripInjectionRate: 100, SlowDripInterTokenLatency: 500}
+			fault := getLatencyFault(config)
+			Expect(fault).NotTo(BeNil())
+			Expect(fault.faultType).To(Equal(latencyFaultSlowDrip))
+			Expect(fault.interTokenLatency(10)).To(Equal(500))
+			Expect(fault.interTokenLatency(600)).To(Equal(600))
+		})
+	})
+
+	Describe("Simulator with latency fault injection", func() {
+		const echoRequest = `{"prompt": "This is a test of latency faults", "model": "my_model"}`
+
+		sendRequest := func(args ...string) (*http.Response, error, time.Duration) {
+			ctx := context.Background()
+			client, err := startServerWithArgs(ctx, common.ModeEcho,
+				append([]string{"cmd", "--model", model, "--mode", common.ModeEcho}, args...), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			start := time.Now()
+			resp, err := client.Post("http://localhost/v1/completions", "application/json",
+				strings.NewReader(echoRequest))
+			return resp, err, time.Since(start)
+		}
+
+		It("should hang and close the connection", func() {
+			_, err, duration := sendRequest("--hang-injection-rate", "100", "--hang-time", "500")
+			Expect(err).To(HaveOccurred())
+			Expect(duration).To(BeNumerically(">=", 500*time.Millisecond))
+		})
+
+		It("should spike the time to first token", func() {
+			resp, err, duration := sendRequest("--time-to-first-token", "100",
+				"--ttft-spike-injection-rate", "100", "--ttft-spike-multiplier", "5")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(duration).To(BeNumerically(">=", 500*time.Millisecond))
+		})
+
+		It("should send a slow drip response", func() {
+			resp, err, duration := sendRequest("--slow-drip-injection-rate", "100",
+				"--slow-drip-inter-token-latency", "100")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			// the prompt has 7 tokens
+			Expect(duration).To(BeNumerically(">=", 600*time.Millisecond))
+		})
+	})
+
 	Describe("Simulator with failure injection", func() {
 		var (
 			client *http.Client
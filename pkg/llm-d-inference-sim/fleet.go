@@ -0,0 +1,191 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains the fleet mode, in which several simulators run in one process
+package llmdinferencesim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/buaazp/fasthttprouter"
+	"github.com/go-logr/logr"
+	"github.com/valyala/fasthttp"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+// the pod name prefix of the fleet's simulators when POD_NAME is not set
+const defaultFleetPodName = "vllm-sim"
+
+// fleetMemberStatus is the status of a simulator in the fleet
+type fleetMemberStatus struct {
+	Pod             string   `json:"pod"`
+	Namespace       string   `json:"namespace,omitempty"`
+	Port            int      `json:"port"`
+	RunningRequests int      `json:"running-requests"`
+	WaitingRequests int      `json:"waiting-requests"`
+	RunningLoras    []string `json:"running-loras"`
+	WaitingLoras    []string `json:"waiting-loras"`
+}
+
+// fleetStatus is the aggregated status of the fleet's simulators
+type fleetStatus struct {
+	RunningRequests int                 `json:"running-requests"`
+	WaitingRequests int                 `json:"waiting-requests"`
+	Pods            []fleetMemberStatus `json:"pods"`
+}
+
+// fleet is a set of simulators that run in one process, each simulator simulates a different pod,
+// with its own port, state, metrics and KV cache
+type fleet struct {
+	logger     logr.Logger
+	config     *common.Configuration
+	simulators []*VllmSimulator
+}
+
+// newFleet creates and initializes the fleet's simulators, the simulators' pod names are
+// the value of POD_NAME (or vllm-sim) followed by the simulator's index
+func newFleet(logger logr.Logger, config *common.Configuration) (*fleet, error) {
+	podPrefix := os.Getenv(podNameEnv)
+	if podPrefix == "" {
+		podPrefix = defaultFleetPodName
+	}
+
+	f := &fleet{logger: logger, config: config}
+	for i := range config.FleetSize {
+		pod := fmt.Sprintf("%s-%d", podPrefix, i)
+		simulator, err := New(logger.WithValues("pod", pod))
+		if err != nil {
+			return nil, err
+		}
+		simulator.pod = pod
+		if err := simulator.init(config.FleetMemberConfig(i)); err != nil {
+			return nil, fmt.Errorf("failed to initialize simulator %s: %w", pod, err)
+		}
+		f.simulators = append(f.simulators, simulator)
+	}
+	return f, nil
+}
+
+// startFleet starts a fleet of simulators, and the fleet's status page, the configuration
+// is reloaded when the reload channel is signaled or when the configuration file changes
+func startFleet(ctx context.Context, logger logr.Logger, config *common.Configuration, reload chan struct{}) error {
+	f, err := newFleet(logger, config)
+	if err != nil {
+		return err
+	}
+	go watchConfig(ctx, logger, config, reload, f.applyReloadedConfig)
+	return f.run(ctx)
+}
+
+// applyReloadedConfig applies the reloaded configuration to the fleet's simulators,
+// the fleet's size and ports cannot be changed at runtime
+func (f *fleet) applyReloadedConfig(reloaded *common.Configuration) {
+	if reloaded.FleetSize != f.config.FleetSize || reloaded.FleetStatusPort != f.config.FleetStatusPort {
+		f.logger.Info("Changed fleet parameters require a restart and are ignored")
+	}
+	for i, simulator := range f.simulators {
+		simulator.applyReloadedConfig(reloaded.FleetMemberConfig(i))
+	}
+}
+
+// run runs the fleet's simulators and the status page until the context is cancelled,
+// or until one of them fails
+func (f *fleet) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(f.simulators)+1)
+	for _, simulator := range f.simulators {
+		go func() {
+			errs <- simulator.run(ctx)
+		}()
+	}
+	go func() {
+		errs <- f.runStatusServer(ctx)
+	}()
+
+	// stop the whole fleet when one of its parts stops
+	err := <-errs
+	cancel()
+	for range len(f.simulators) {
+		if runErr := <-errs; err == nil {
+			err = runErr
+		}
+	}
+	return err
+}
+
+// runStatusServer runs the http server of the fleet's status page
+func (f *fleet) runStatusServer(ctx context.Context) error {
+	r := fasthttprouter.New()
+	r.GET("/status", f.HandleStatus)
+
+	f.logger.Info("Fleet status server starting", "port", f.config.FleetStatusPort)
+	listener, err := net.Listen("tcp4", fmt.Sprintf(":%d", f.config.FleetStatusPort))
+	if err != nil {
+		return err
+	}
+	server := fasthttp.Server{Handler: r.Handler}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return server.Shutdown()
+	case err := <-serverErr:
+		return err
+	}
+}
+
+// HandleStatus http handler for the fleet's /status
+func (f *fleet) HandleStatus(ctx *fasthttp.RequestCtx) {
+	data, err := json.Marshal(f.status())
+	if err != nil {
+		ctx.Error("Failed to marshal fleet status, "+err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+	ctx.Response.SetBody(data)
+}
+
+// status returns the status of the fleet's simulators
+func (f *fleet) status() *fleetStatus {
+	status := fleetStatus{Pods: make([]fleetMemberStatus, 0, len(f.simulators))}
+	for _, simulator := range f.simulators {
+		running, waiting, runningLoras, waitingLoras := simulator.queue.status()
+		status.Pods = append(status.Pods, fleetMemberStatus{
+			Pod:             simulator.pod,
+			Namespace:       simulator.namespace,
+			Port:            simulator.getConfig().Port,
+			RunningRequests: running,
+			WaitingRequests: waiting,
+			RunningLoras:    runningLoras,
+			WaitingLoras:    waitingLoras,
+		})
+		status.RunningRequests += running
+		status.WaitingRequests += waiting
+	}
+	return &status
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/klog/v2"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+const fleetPort = 18100
+
+func getFleetStatus() (*fleetStatus, error) {
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/status", fleetPort+3))
+	if err != nil {
+		return nil, err
+	}
+	var status fleetStatus
+	err = json.Unmarshal([]byte(readBody(resp)), &status)
+	return &status, err
+}
+
+var _ = Describe("Fleet", func() {
+	It("Should run simulators with different identities and profiles on consecutive ports", func() {
+		oldArgs := os.Args
+		defer func() {
+			os.Args = oldArgs
+		}()
+		os.Args = []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--port", fmt.Sprint(fleetPort),
+			"--fleet-size", "3", "--fleet-profiles", `{"time-to-first-token":0}`, `{"time-to-first-token":1000}`}
+		config, err := common.ParseCommandParamsAndLoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.FleetStatusPort).To(Equal(fleetPort + 3))
+
+		f, err := newFleet(klog.Background(), config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.simulators).To(HaveLen(3))
+		Expect(f.simulators[1].config.TimeToFirstToken).To(Equal(1000))
+		Expect(f.simulators[2].config.TimeToFirstToken).To(Equal(0))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- f.run(ctx)
+		}()
+		defer func() {
+			cancel()
+			Expect(<-runErr).NotTo(HaveOccurred())
+		}()
+		Eventually(func() error {
+			_, err := getFleetStatus()
+			return err
+		}, 5*time.Second, 50*time.Millisecond).Should(Succeed())
+
+		// a slow request to the second simulator
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer GinkgoRecover()
+			resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/completions", fleetPort+1), "application/json",
+				strings.NewReader(`{"prompt": "This is a test.", "model": "my_model", "max_tokens": 2}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get(podHeader)).To(Equal("vllm-sim-1"))
+			_ = readBody(resp)
+		}()
+
+		time.Sleep(300 * time.Millisecond)
+		status, err := getFleetStatus()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.RunningRequests).To(Equal(1))
+		Expect(status.Pods).To(HaveLen(3))
+		for i, pod := range status.Pods {
+			Expect(pod.Pod).To(Equal(fmt.Sprintf("vllm-sim-%d", i)))
+			Expect(pod.Port).To(Equal(fleetPort + i))
+		}
+		Expect(status.Pods[1].RunningRequests).To(Equal(1))
+
+		// each simulator has its own metrics
+		for i, expected := range []string{"0", "1", "0"} {
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", fleetPort+i))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readBody(resp)).To(ContainSubstring("vllm:num_requests_running{model_name=\"my_model\"} " + expected))
+		}
+		<-done
+	})
+})
@@ -0,0 +1,73 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains the health and readiness checks
+
+package llmdinferencesim
+
+import (
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+// HandleHealth http handler for /health
+func (s *VllmSimulator) HandleHealth(ctx *fasthttp.RequestCtx) {
+	s.logger.V(4).Info("health request received")
+	status := fasthttp.StatusOK
+	if rate := s.getConfig().LivenessFailureInjectionRate; rate > 0 && common.RandomInt(1, 100) <= rate {
+		s.logger.V(4).Info("Injecting liveness failure")
+		status = fasthttp.StatusServiceUnavailable
+	}
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.Response.Header.SetStatusCode(status)
+	ctx.Response.SetBody([]byte("{}"))
+}
+
+// HandleReady http handler for /ready
+func (s *VllmSimulator) HandleReady(ctx *fasthttp.RequestCtx) {
+	s.logger.V(4).Info("readiness request received")
+	ctx.Response.Header.SetContentType("application/json")
+	if reason := s.notReadyReason(); reason != "" {
+		s.logger.V(4).Info("Simulator is not ready", "reason", reason)
+		ctx.Response.Header.SetStatusCode(fasthttp.StatusServiceUnavailable)
+	} else {
+		ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+	}
+	ctx.Response.SetBody([]byte("{}"))
+}
+
+// notReadyReason returns the reason the simulator is not ready, empty if it is ready
+func (s *VllmSimulator) notReadyReason() string {
+	config := s.getConfig()
+	if time.Since(s.startTime) < time.Duration(config.StartupDelay)*time.Millisecond {
+		return "starting"
+	}
+	if s.draining.Load() {
+		return "draining"
+	}
+	if config.ReadinessQueueThreshold > 0 {
+		if _, waiting, _, _ := s.queue.status(); waiting > config.ReadinessQueueThreshold {
+			return "queue threshold exceeded"
+		}
+	}
+	if !s.isScenarioReady() {
+		return "scenario"
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+var _ = Describe("Health and readiness", func() {
+	getStatus := func(client *http.Client, path string) int {
+		resp, err := client.Get("http://localhost" + path)
+		Expect(err).NotTo(HaveOccurred())
+		_ = readBody(resp)
+		return resp.StatusCode
+	}
+
+	It("should be ready after the startup delay", func() {
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--startup-delay", "500"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(getStatus(client, "/ready")).To(Equal(http.StatusServiceUnavailable))
+		Expect(getStatus(client, "/health")).To(Equal(http.StatusOK))
+		Eventually(func() int { return getStatus(client, "/ready") }).
+			WithTimeout(time.Second).WithPolling(50 * time.Millisecond).Should(Equal(http.StatusOK))
+	})
+
+	It("should not be ready when the queue exceeds the threshold", func() {
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--max-num-seqs", "1",
+				"--time-to-first-token", "1000", "--readiness-queue-threshold", "1"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getStatus(client, "/ready")).To(Equal(http.StatusOK))
+
+		for range 3 {
+			go func() {
+				defer GinkgoRecover()
+				resp, err := client.Post("http://localhost/v1/completions", "application/json",
+					strings.NewReader(`{"prompt": "This is a test", "model": "my_model", "max_tokens": 2}`))
+				Expect(err).NotTo(HaveOccurred())
+				_ = readBody(resp)
+			}()
+		}
+
+		// one request is running and two are waiting
+		Eventually(func() int { return getStatus(client, "/ready") }).
+			WithTimeout(500 * time.Millisecond).WithPolling(20 * time.Millisecond).
+			Should(Equal(http.StatusServiceUnavailable))
+		// the queue is empty after the requests are processed
+		Eventually(func() int { return getStatus(client, "/ready") }).
+			WithTimeout(4 * time.Second).WithPolling(100 * time.Millisecond).Should(Equal(http.StatusOK))
+	})
+
+	It("should inject liveness failures", func() {
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom,
+				"--liveness-failure-injection-rate", "100"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getStatus(client, "/health")).To(Equal(http.StatusServiceUnavailable))
+		Expect(getStatus(client, "/ready")).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,117 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains functions related to the injection of failures and latencies to a single request by its headers
+
+package llmdinferencesim
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+const (
+	// failHeader forces a failure of the given type, a request failure, a streaming failure or a latency fault
+	failHeader = "x-sim-fail"
+	// ttftHeader forces the time to first token in milliseconds
+	ttftHeader = "x-sim-ttft-ms"
+	// outputTokensHeader forces the number of generated tokens
+	outputTokensHeader = "x-sim-output-tokens"
+)
+
+// requestInjection is the behavior forced on a single request by its headers
+type requestInjection struct {
+	// failure is the type of the forced failure, empty if none
+	failure string
+	// timeToFirstToken is the forced time to first token in milliseconds, nil if not forced
+	timeToFirstToken *int
+	// outputTokens is the forced number of generated tokens, nil if not forced
+	outputTokens *int
+}
+
+// getRequestInjection returns the behavior forced by the request's headers, nil if request injection is
+// disabled or the request has no injection headers
+func (s *VllmSimulator) getRequestInjection(ctx *fasthttp.RequestCtx) (*requestInjection, error) {
+	if !s.getConfig().EnableRequestInjection {
+		return nil, nil
+	}
+
+	var injection requestInjection
+	found := false
+	if value := ctx.Request.Header.Peek(failHeader); value != nil {
+		failure := string(value)
+		if _, ok := predefinedFailures[failure]; !ok && !slices.Contains(common.StreamFailureTypes, failure) &&
+			!slices.Contains(latencyFaultTypes, failure) {
+			return nil, fmt.Errorf("invalid %s header '%s'", failHeader, failure)
+		}
+		injection.failure = failure
+		found = true
+	}
+
+	var err error
+	if injection.timeToFirstToken, err = getNonNegativeIntHeader(ctx, ttftHeader); err != nil {
+		return nil, err
+	}
+	if injection.outputTokens, err = getNonNegativeIntHeader(ctx, outputTokensHeader); err != nil {
+		return nil, err
+	}
+	if !found && injection.timeToFirstToken == nil && injection.outputTokens == nil {
+		return nil, nil
+	}
+	return &injection, nil
+}
+
+// getNonNegativeIntHeader returns the value of the given header, nil if the header is not set
+func getNonNegativeIntHeader(ctx *fasthttp.RequestCtx, header string) (*int, error) {
+	value := ctx.Request.Header.Peek(header)
+	if value == nil {
+		return nil, nil
+	}
+	intValue, err := strconv.Atoi(string(value))
+	if err != nil || intValue < 0 {
+		return nil, fmt.Errorf("invalid %s header '%s', should be a non-negative integer", header, value)
+	}
+	return &intValue, nil
+}
+
+// isRequestFailure returns true if the forced failure is a failure response to the request
+func (injection *requestInjection) isRequestFailure() bool {
+	_, ok := predefinedFailures[injection.failure]
+	return ok
+}
+
+// isStreamFailure returns true if the forced failure is a streaming failure
+func (injection *requestInjection) isStreamFailure() bool {
+	return slices.Contains(common.StreamFailureTypes, injection.failure)
+}
+
+// isLatencyFault returns true if the forced failure is a latency fault
+func (injection *requestInjection) isLatencyFault() bool {
+	return slices.Contains(latencyFaultTypes, injection.failure)
+}
+
+// forcedResponseTokens returns random response tokens of the given number
+func forcedResponseTokens(numOfTokens int) []string {
+	if numOfTokens == 0 {
+		return []string{}
+	}
+	return common.Tokenize(common.GetRandomText(numOfTokens))
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+var _ = Describe("Request injection", func() {
+	const completionRequest = `{"prompt": "This is a test of request injection", "model": "my_model", "max_tokens": 10}`
+
+	sendRequest := func(client *http.Client, body string, headers map[string]string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost/v1/completions", strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/json")
+		for header, value := range headers {
+			req.Header.Set(header, value)
+		}
+		return client.Do(req)
+	}
+
+	startInjectionServer := func(enabled bool) *http.Client {
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom}
+		if enabled {
+			args = append(args, "--enable-request-injection")
+		}
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return client
+	}
+
+	It("should ignore the headers when request injection is disabled", func() {
+		client := startInjectionServer(false)
+		resp, err := sendRequest(client, completionRequest, map[string]string{failHeader: common.FailureTypeRateLimit})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		_ = readBody(resp)
+	})
+
+	It("should return the failure of the header", func() {
+		client := startInjectionServer(true)
+		resp, err := sendRequest(client, completionRequest, map[string]string{failHeader: common.FailureTypeRateLimit})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(readBody(resp)).To(ContainSubstring(model))
+	})
+
+	It("should reject invalid headers", func() {
+		client := startInjectionServer(true)
+		for header, value := range map[string]string{failHeader: "invalid", ttftHeader: "-1", outputTokensHeader: "many"} {
+			resp, err := sendRequest(client, completionRequest, map[string]string{header: value})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(readBody(resp)).To(ContainSubstring(header))
+		}
+	})
+
+	It("should generate the number of tokens of the header", func() {
+		client := startInjectionServer(true)
+		for _, numOfTokens := range []int{0, 1, 37} {
+			resp, err := sendRequest(client, completionRequest,
+				map[string]string{outputTokensHeader: strconv.Itoa(numOfTokens)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var completion openaiserverapi.TextCompletionResponse
+			Expect(json.Unmarshal([]byte(readBody(resp)), &completion)).To(Succeed())
+			Expect(completion.Usage.CompletionTokens).To(Equal(numOfTokens))
+			Expect(common.Tokenize(completion.Choices[0].Text)).To(HaveLen(numOfTokens))
+		}
+	})
+
+	It("should wait the time to first token of the header", func() {
+		client := startInjectionServer(true)
+		start := time.Now()
+		resp, err := sendRequest(client, completionRequest, map[string]string{ttftHeader: "500"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		_ = readBody(resp)
+		Expect(time.Since(start)).To(BeNumerically(">=", 500*time.Millisecond))
+	})
+
+	It("should inject the streaming failure of the header", func() {
+		client := startInjectionServer(true)
+		resp, err := sendRequest(client,
+			`{"prompt": "This is a test of request injection", "model": "my_model", "stream": true}`,
+			map[string]string{failHeader: common.StreamFailureTypeErrorEvent, outputTokensHeader: "5"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring(`data: {"error":`))
+	})
+})
@@ -0,0 +1,114 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains functions related to log probabilities of tokens
+package llmdinferencesim
+
+import (
+	"unicode/utf8"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+const (
+	// the range of log probability of a chosen token
+	minTokenLogprob = -3.0
+	maxTokenLogprob = 0.0
+	// the range of the difference between log probabilities of two consecutive alternative tokens
+	minLogprobStep = 0.1
+	maxLogprobStep = 2.0
+	// maxLogprobs is the maximum number of log probabilities that can be requested
+	maxLogprobs = 20
+)
+
+// appendLogprobs appends synthetic log probabilities of the given tokens to logprobs
+// topLogprobs - the number of the most likely alternative tokens to report for each position,
+// in addition to the token itself
+// offset - the offset of the first token in the choice's text
+// isPrompt - true if the tokens are the prompt's tokens, the first token of the prompt has no log probability
+// returns the offset after the last token
+func appendLogprobs(logprobs *openaiserverapi.TextLogprobs, tokens []string, topLogprobs int, offset int, isPrompt bool) int {
+	for i, token := range tokens {
+		logprobs.Tokens = append(logprobs.Tokens, token)
+		logprobs.TextOffset = append(logprobs.TextOffset, offset)
+		offset += utf8.RuneCountInString(token)
+
+		if isPrompt && i == 0 {
+			logprobs.TokenLogprobs = append(logprobs.TokenLogprobs, nil)
+			logprobs.TopLogprobs = append(logprobs.TopLogprobs, nil)
+			continue
+		}
+
+		logprob := common.RandomFloat(minTokenLogprob, maxTokenLogprob)
+		logprobs.TokenLogprobs = append(logprobs.TokenLogprobs, &logprob)
+
+		top := map[string]float64{token: logprob}
+		alternativeLogprob := logprob
+		for _, alternative := range common.GetRandomTokens(topLogprobs, token) {
+			alternativeLogprob -= common.RandomFloat(minLogprobStep, maxLogprobStep)
+			top[alternative] = alternativeLogprob
+		}
+		logprobs.TopLogprobs = append(logprobs.TopLogprobs, top)
+	}
+	return offset
+}
+
+// averageLogprob returns the average log probability of the last numOfTokens tokens in logprobs,
+// used to choose the best completion
+func averageLogprob(logprobs *openaiserverapi.TextLogprobs, numOfTokens int) float64 {
+	if numOfTokens == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, logprob := range logprobs.TokenLogprobs[len(logprobs.TokenLogprobs)-numOfTokens:] {
+		if logprob != nil {
+			sum += *logprob
+		}
+	}
+	return sum / float64(numOfTokens)
+}
+
+// sliceLogprobs returns the log probabilities of tokens in the range [from, to)
+func sliceLogprobs(logprobs *openaiserverapi.TextLogprobs, from int, to int) *openaiserverapi.TextLogprobs {
+	if logprobs == nil {
+		return nil
+	}
+	from = min(from, len(logprobs.Tokens))
+	to = min(to, len(logprobs.Tokens))
+	return &openaiserverapi.TextLogprobs{
+		Tokens:        logprobs.Tokens[from:to],
+		TokenLogprobs: logprobs.TokenLogprobs[from:to],
+		TopLogprobs:   logprobs.TopLogprobs[from:to],
+		TextOffset:    logprobs.TextOffset[from:to],
+	}
+}
+
+// createChoiceLogprobs creates the log probabilities of a choice, including the prompt's tokens
+// if the prompt is echoed
+func createChoiceLogprobs(prefix string, tokens []string, topLogprobs int) *openaiserverapi.TextLogprobs {
+	logprobs := &openaiserverapi.TextLogprobs{
+		Tokens:        []string{},
+		TokenLogprobs: []*float64{},
+		TopLogprobs:   []map[string]float64{},
+		TextOffset:    []int{},
+	}
+	if prefix != "" {
+		appendLogprobs(logprobs, common.Tokenize(prefix), topLogprobs, 0, true)
+	}
+	appendLogprobs(logprobs, tokens, topLogprobs, utf8.RuneCountInString(prefix), false)
+	return logprobs
+}
@@ -19,10 +19,20 @@ package llmdinferencesim
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
 
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
 	"github.com/valyala/fasthttp"
 )
 
+// the configuration file of a LoRA adapter, used to validate LoRA adapters' paths
+const loraAdapterConfigFile = "adapter_config.json"
+
 type loadLoraRequest struct {
 	LoraName string `json:"lora_name"`
 	LoraPath string `json:"lora_path"`
@@ -47,6 +57,16 @@ func (s *VllmSimulator) getLoras() []string {
 	return loras
 }
 
+// getLoraID returns the integer id of the given LoRA adapter, the ids are given by the order of the
+// adapters' first use and are kept when adapters are unloaded
+func (s *VllmSimulator) getLoraID(lora string) int {
+	if id, ok := s.loraIDs.Load(lora); ok {
+		return id.(int)
+	}
+	id, _ := s.loraIDs.LoadOrStore(lora, int(s.lastLoraID.Add(1)))
+	return id.(int)
+}
+
 func (s *VllmSimulator) loadLora(ctx *fasthttp.RequestCtx) {
 	var req loadLoraRequest
 	err := json.Unmarshal(ctx.Request.Body(), &req)
@@ -56,7 +76,31 @@ func (s *VllmSimulator) loadLora(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	s.loraAdaptors.Store(req.LoraName, "")
+	if req.LoraName == "" || req.LoraPath == "" {
+		s.sendLoraError(ctx, "Both 'lora_name' and 'lora_path' must be provided.")
+		return
+	}
+	if s.getConfig().ValidateLoraPath {
+		if err := validateLoraPath(req.LoraPath); err != nil {
+			s.sendLoraError(ctx, fmt.Sprintf("Loading lora %s failed: %s", req.LoraName, err.Error()))
+			return
+		}
+	}
+	alreadyLoaded := fmt.Sprintf("The lora adapter '%s' has already been loaded.", req.LoraName)
+	if _, loaded := s.loraAdaptors.Load(req.LoraName); loaded {
+		s.sendLoraError(ctx, alreadyLoaded)
+		return
+	}
+
+	// the adapter is loaded from the disk to the CPU memory, it is registered only when it is loaded
+	time.Sleep(time.Duration(s.getConfig().LoraDiskLoadLatency) * time.Millisecond)
+	if _, loaded := s.loraAdaptors.LoadOrStore(req.LoraName, ""); loaded {
+		s.sendLoraError(ctx, alreadyLoaded)
+		return
+	}
+	s.queue.addLora(req.LoraName)
+
+	s.sendLoraSuccess(ctx, fmt.Sprintf("Success: LoRA adapter '%s' added successfully.", req.LoraName))
 }
 
 func (s *VllmSimulator) unloadLora(ctx *fasthttp.RequestCtx) {
@@ -68,5 +112,130 @@ func (s *VllmSimulator) unloadLora(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	s.loraAdaptors.Delete(req.LoraName)
+	if req.LoraName == "" {
+		s.sendLoraError(ctx, "'lora_name' needs to be provided to unload a LoRA adapter.")
+		return
+	}
+	if _, ok := s.loraAdaptors.Load(req.LoraName); !ok {
+		s.sendLoraError(ctx, fmt.Sprintf("The lora adapter '%s' cannot be found.", req.LoraName))
+		return
+	}
+	if numOfRequests := s.removeLora(req.LoraName); numOfRequests > 0 {
+		s.sendLoraError(ctx, fmt.Sprintf("The lora adapter '%s' cannot be unloaded, it is used by %d requests.",
+			req.LoraName, numOfRequests))
+		return
+	}
+
+	s.sendLoraSuccess(ctx, fmt.Sprintf("Success: LoRA adapter '%s' removed successfully.", req.LoraName))
+}
+
+// validateLoraPath checks that the path is a local directory that contains a LoRA adapter
+func validateLoraPath(path string) error {
+	if _, err := os.Stat(filepath.Join(path, loraAdapterConfigFile)); err != nil {
+		return fmt.Errorf("no adapter found for %s", path)
+	}
+	return nil
+}
+
+// loraAdapterConfig contains the fields of adapter_config.json that are checked by the LoRA resolver
+type loraAdapterConfig struct {
+	BaseModelNameOrPath string `json:"base_model_name_or_path"`
+	PeftType            string `json:"peft_type"`
+}
+
+// resolveLora loads a LoRA adapter that is not registered from the LoRA resolver directory, like vLLM's
+// filesystem resolver, the adapter is resolved if the directory contains a subdirectory with the adapter's
+// name and a LoRA adapter_config.json of one of the base models, returns true if the adapter was resolved.
+// The resolved adapter is registered, but it is not loaded to the CPU memory, so the first request to it
+// waits for its load from the disk.
+func (s *VllmSimulator) resolveLora(lora string) bool {
+	if s.getConfig().LoraResolverDir == "" || lora == "" || filepath.Base(lora) != lora || lora == "." || lora == ".." {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(s.getConfig().LoraResolverDir, lora, loraAdapterConfigFile))
+	if err != nil {
+		return false
+	}
+	var adapterConfig loraAdapterConfig
+	if err := json.Unmarshal(data, &adapterConfig); err != nil {
+		s.logger.Error(err, "failed to parse LoRA adapter config", "lora", lora)
+		return false
+	}
+	baseModelName, ok := s.findBaseModel(adapterConfig.BaseModelNameOrPath)
+	if adapterConfig.PeftType != "LORA" || !ok {
+		s.logger.Info("LoRA adapter does not match the base models", "lora", lora,
+			"base model", adapterConfig.BaseModelNameOrPath, "peft type", adapterConfig.PeftType)
+		return false
+	}
+	if _, loaded := s.loraAdaptors.LoadOrStore(lora, baseModelName); !loaded {
+		s.logger.Info("Resolved LoRA adapter", "lora", lora)
+	}
+	return true
+}
+
+// findBaseModel returns the first served model name of the additional model with the given name, or an
+// empty string for the main model, the second return value is false if there is no such base model
+func (s *VllmSimulator) findBaseModel(name string) (string, bool) {
+	config := s.getConfig()
+	if name == config.Model {
+		return "", true
+	}
+	for _, model := range config.AdditionalModels {
+		if name == model.Name {
+			return model.ServedModelNames[0], true
+		}
+	}
+	return "", false
+}
+
+// updateLoras loads the LoRA adapters that were added to the configuration, and unloads the removed
+// LoRA adapters, adapters that are in use are unloaded when their last request finishes
+func (s *VllmSimulator) updateLoras(previous []common.LoraModule, current []common.LoraModule) {
+	isIn := func(loras []common.LoraModule, name string) bool {
+		return slices.ContainsFunc(loras, func(lora common.LoraModule) bool { return lora.Name == name })
+	}
+	for _, lora := range current {
+		if isIn(previous, lora.Name) {
+			continue
+		}
+		if _, loaded := s.loraAdaptors.LoadOrStore(lora.Name, ""); !loaded {
+			s.queue.addLora(lora.Name)
+			s.logger.Info("LoRA adapter loaded", "lora", lora.Name)
+		} else if s.queue.cancelLoraRemoval(lora.Name) {
+			s.logger.Info("LoRA adapter is kept", "lora", lora.Name)
+		}
+	}
+	for _, lora := range previous {
+		if isIn(current, lora.Name) {
+			continue
+		}
+		name := lora.Name
+		numOfRequests := s.queue.removeLoraWhenUnused(name, func() {
+			s.loraAdaptors.Delete(name)
+			s.logger.Info("LoRA adapter unloaded", "lora", name)
+		})
+		if numOfRequests > 0 {
+			s.logger.Info("LoRA adapter is in use, it will be unloaded when its requests finish", "lora", name,
+				"requests", numOfRequests)
+		}
+	}
+}
+
+// removeLora unloads and unregisters the LoRA adapter if it is not in use, returns the number of requests
+// that use it otherwise
+func (s *VllmSimulator) removeLora(lora string) int {
+	return s.queue.removeLora(lora, func() {
+		s.loraAdaptors.Delete(lora)
+	})
+}
+
+func (s *VllmSimulator) sendLoraError(ctx *fasthttp.RequestCtx, message string) {
+	s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(message, fasthttp.StatusBadRequest, nil), false)
+}
+
+func (s *VllmSimulator) sendLoraSuccess(ctx *fasthttp.RequestCtx, message string) {
+	s.logger.Info(message)
+	ctx.SetContentType("text/plain; charset=utf-8")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBodyString(message)
 }
@@ -20,6 +20,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -27,9 +32,16 @@ import (
 	"github.com/openai/openai-go/option"
 
 	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
 	vllmapi "github.com/llm-d/llm-d-inference-sim/pkg/vllm-api"
 )
 
+func sendLoraRequest(client *http.Client, path string, body string) (int, string) {
+	resp, err := client.Post("http://localhost/v1/"+path, "application/json", strings.NewReader(body))
+	Expect(err).NotTo(HaveOccurred())
+	return resp.StatusCode, readBody(resp)
+}
+
 var _ = Describe("LoRAs", func() {
 	Context("LoRAs config and load", func() {
 		It("Should config, load and load LoRAs correctly", func() {
@@ -126,4 +138,217 @@ var _ = Describe("LoRAs", func() {
 			Expect(modelsResp.Data).To(HaveLen(3))
 		})
 	})
+
+	Context("LoRAs load and unload API", func() {
+		loraArgs := []string{"cmd", "--model", model, "--mode", common.ModeRandom,
+			"--lora-modules", "{\"name\":\"lora1\",\"path\":\"/path/to/lora1\"}"}
+
+		It("Should return a plain text message on success", func() {
+			ctx := context.TODO()
+			client, err := startServerWithArgs(ctx, common.ModeRandom, loraArgs, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			code, body := sendLoraRequest(client, "load_lora_adapter", `{"lora_name": "lora2", "lora_path": "/path/to/lora2"}`)
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("Success: LoRA adapter 'lora2' added successfully."))
+
+			code, body = sendLoraRequest(client, "unload_lora_adapter", `{"lora_name": "lora2"}`)
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("Success: LoRA adapter 'lora2' removed successfully."))
+		})
+
+		DescribeTable("Should reject invalid requests",
+			func(path string, body string, expectedMessage string) {
+				ctx := context.TODO()
+				client, err := startServerWithArgs(ctx, common.ModeRandom, loraArgs, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				code, respBody := sendLoraRequest(client, path, body)
+				Expect(code).To(Equal(http.StatusBadRequest))
+				var errorResp openaiserverapi.ErrorResponse
+				err = json.Unmarshal([]byte(respBody), &errorResp)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(errorResp.Error.Message).To(Equal(expectedMessage))
+			},
+			Entry("load without path", "load_lora_adapter", `{"lora_name": "lora2"}`,
+				"Both 'lora_name' and 'lora_path' must be provided."),
+			Entry("load without name", "load_lora_adapter", `{"lora_path": "/path/to/lora2"}`,
+				"Both 'lora_name' and 'lora_path' must be provided."),
+			Entry("load an existing adapter", "load_lora_adapter", `{"lora_name": "lora1", "lora_path": "/path/to/lora1"}`,
+				"The lora adapter 'lora1' has already been loaded."),
+			Entry("unload without name", "unload_lora_adapter", `{}`,
+				"'lora_name' needs to be provided to unload a LoRA adapter."),
+			Entry("unload an unknown adapter", "unload_lora_adapter", `{"lora_name": "lora2"}`,
+				"The lora adapter 'lora2' cannot be found."),
+		)
+
+		It("Should validate the LoRA adapter's path", func() {
+			ctx := context.TODO()
+			client, err := startServerWithArgs(ctx, common.ModeRandom, append(loraArgs, "--validate-lora-path"), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			dir := GinkgoT().TempDir()
+			code, _ := sendLoraRequest(client, "load_lora_adapter", `{"lora_name": "lora2", "lora_path": "`+dir+`"}`)
+			Expect(code).To(Equal(http.StatusBadRequest))
+
+			err = os.WriteFile(filepath.Join(dir, loraAdapterConfigFile), []byte("{}"), 0o600)
+			Expect(err).NotTo(HaveOccurred())
+			code, _ = sendLoraRequest(client, "load_lora_adapter", `{"lora_name": "lora2", "lora_path": "`+dir+`"}`)
+			Expect(code).To(Equal(http.StatusOK))
+		})
+
+		It("Should delay the load of a LoRA adapter", func() {
+			ctx := context.TODO()
+			client, err := startServerWithArgs(ctx, common.ModeRandom,
+				append(loraArgs, "--lora-disk-load-latency", "300"), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			start := time.Now()
+			loaded := make(chan struct{})
+			go func() {
+				defer close(loaded)
+				defer GinkgoRecover()
+				code, _ := sendLoraRequest(client, "load_lora_adapter", `{"lora_name": "lora2", "lora_path": "/path/to/lora2"}`)
+				Expect(code).To(Equal(http.StatusOK))
+				Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+			}()
+
+			// the adapter is registered only when it is loaded
+			time.Sleep(100 * time.Millisecond)
+			resp, err := client.Post("http://localhost/v1/completions", "application/json",
+				strings.NewReader(`{"prompt": "This is a test.", "model": "lora2", "max_tokens": 2}`))
+			Expect(err).NotTo(HaveOccurred())
+			_ = readBody(resp)
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			<-loaded
+		})
+
+		It("Should refuse to unload a LoRA adapter that is in use", func() {
+			ctx := context.TODO()
+			client, err := startServerWithArgs(ctx, common.ModeRandom,
+				append(loraArgs, "--time-to-first-token", "500"), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				defer GinkgoRecover()
+				resp, err := client.Post("http://localhost/v1/completions", "application/json",
+					strings.NewReader(`{"prompt": "This is a test.", "model": "lora1", "max_tokens": 2}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			}()
+
+			time.Sleep(200 * time.Millisecond)
+			code, body := sendLoraRequest(client, "unload_lora_adapter", `{"lora_name": "lora1"}`)
+			Expect(code).To(Equal(http.StatusBadRequest))
+			Expect(body).To(ContainSubstring("it is used by 1 requests"))
+
+			<-done
+			code, _ = sendLoraRequest(client, "unload_lora_adapter", `{"lora_name": "lora1"}`)
+			Expect(code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("LoRA resolver", func() {
+		createAdapter := func(dir string, lora string, baseModel string) {
+			err := os.Mkdir(filepath.Join(dir, lora), 0o700)
+			Expect(err).NotTo(HaveOccurred())
+			adapterConfig := `{"base_model_name_or_path": "` + baseModel + `", "peft_type": "LORA"}`
+			err = os.WriteFile(filepath.Join(dir, lora, loraAdapterConfigFile), []byte(adapterConfig), 0o600)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		sendRequest := func(client *http.Client, lora string) (int, time.Duration) {
+			start := time.Now()
+			resp, err := client.Post("http://localhost/v1/completions", "application/json",
+				strings.NewReader(`{"prompt": "This is a test.", "model": "`+lora+`", "max_tokens": 2}`))
+			Expect(err).NotTo(HaveOccurred())
+			_ = readBody(resp)
+			return resp.StatusCode, time.Since(start)
+		}
+
+		It("Should resolve LoRA adapters from the resolver directory", func() {
+			dir := GinkgoT().TempDir()
+			createAdapter(dir, "lora1", model)
+			createAdapter(dir, "lora2", model)
+			createAdapter(dir, "other-model-lora", "other-model")
+
+			ctx := context.TODO()
+			client, err := startServerWithArgs(ctx, common.ModeRandom,
+				[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--lora-resolver-dir", dir,
+					"--lora-load-latency", "100", "--lora-disk-load-latency", "200"}, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			// the adapter is loaded from the disk on its first request
+			code, duration := sendRequest(client, "lora1")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(duration).To(BeNumerically(">=", 300*time.Millisecond))
+			code, duration = sendRequest(client, "lora1")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(duration).To(BeNumerically("<", 100*time.Millisecond))
+
+			openaiclient := openai.NewClient(option.WithBaseURL(baseURL), option.WithHTTPClient(client))
+			var modelsResp vllmapi.ModelsResponse
+			err = openaiclient.Get(ctx, "/models", nil, &modelsResp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(modelsResp.Data).To(HaveLen(2))
+
+			// lora2 evicts lora1 from the CPU memory, since max-cpu-loras is 1
+			code, duration = sendRequest(client, "lora2")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(duration).To(BeNumerically(">=", 300*time.Millisecond))
+			code, duration = sendRequest(client, "lora1")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(duration).To(BeNumerically(">=", 300*time.Millisecond))
+
+			code, _ = sendRequest(client, "other-model-lora")
+			Expect(code).To(Equal(http.StatusNotFound))
+			code, _ = sendRequest(client, "lora3")
+			Expect(code).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should resolve LoRA adapters of the additional models", func() {
+			dir := GinkgoT().TempDir()
+			createAdapter(dir, "lora1", model)
+			createAdapter(dir, "lora2", "model2")
+
+			s, err := New(GinkgoLogr)
+			Expect(err).NotTo(HaveOccurred())
+			s.config = &common.Configuration{Model: model, ServedModelNames: []string{model}, LoraResolverDir: dir,
+				AdditionalModels: []common.BaseModel{{Name: "model2", ServedModelNames: []string{"model2-alias"}}}}
+			Expect(s.resolveLora("lora1")).To(BeTrue())
+			Expect(s.resolveLora("lora2")).To(BeTrue())
+			Expect(s.getBaseModelName("lora1")).To(Equal(model))
+			Expect(s.getBaseModelName("lora2")).To(Equal("model2-alias"))
+		})
+
+		It("Should not resolve LoRA names that are not subdirectories of the resolver directory", func() {
+			dir := GinkgoT().TempDir()
+			createAdapter(dir, "loras", model)
+			resolverDir := filepath.Join(dir, "loras")
+			createAdapter(resolverDir, "lora1", model)
+			// the resolver directory and its parent contain adapter configs of the base model
+			adapterConfig := `{"base_model_name_or_path": "` + model + `", "peft_type": "LORA"}`
+			err := os.WriteFile(filepath.Join(dir, loraAdapterConfigFile), []byte(adapterConfig), 0o600)
+			Expect(err).NotTo(HaveOccurred())
+
+			s, err := New(GinkgoLogr)
+			Expect(err).NotTo(HaveOccurred())
+			s.config = &common.Configuration{Model: model, ServedModelNames: []string{model}, LoraResolverDir: resolverDir}
+			Expect(s.resolveLora(".")).To(BeFalse())
+			Expect(s.resolveLora("..")).To(BeFalse())
+			Expect(s.resolveLora("../loras")).To(BeFalse())
+			Expect(s.resolveLora("lora1")).To(BeTrue())
+			Expect(s.getLoras()).To(ConsistOf("lora1"))
+		})
+
+		It("Should give LoRA adapters ids by their first use", func() {
+			s, err := New(GinkgoLogr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.getLoraID("lora2")).To(Equal(1))
+			Expect(s.getLoraID("lora1")).To(Equal(2))
+			Expect(s.getLoraID("lora2")).To(Equal(1))
+		})
+	})
 })
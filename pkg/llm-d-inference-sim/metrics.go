@@ -26,10 +26,23 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
 	vllmapi "github.com/llm-d/llm-d-inference-sim/pkg/vllm-api"
 )
 
+// fakeMetricsCheckInterval is the interval of checking whether the fake metrics change over time
+const fakeMetricsCheckInterval = time.Second
+
+// newPrometheusRegistry creates a prometheus registry with the Go runtime and process metrics,
+// each simulator has its own registry, so several simulators can run in one process
+func newPrometheusRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return registry
+}
+
 // createAndRegisterPrometheus creates and registers prometheus metrics used by vLLM simulator
 // Metrics reported:
 // - lora_requests_info
@@ -43,7 +56,7 @@ func (s *VllmSimulator) createAndRegisterPrometheus() error {
 		[]string{vllmapi.PromLabelMaxLora, vllmapi.PromLabelRunningLoraAdapters, vllmapi.PromLabelWaitingLoraAdapters},
 	)
 
-	if err := prometheus.Register(s.loraInfo); err != nil {
+	if err := s.registry.Register(s.loraInfo); err != nil {
 		s.logger.Error(err, "Prometheus lora info gauge register failed")
 		return err
 	}
@@ -57,7 +70,7 @@ func (s *VllmSimulator) createAndRegisterPrometheus() error {
 		[]string{vllmapi.PromLabelModelName},
 	)
 
-	if err := prometheus.Register(s.runningRequests); err != nil {
+	if err := s.registry.Register(s.runningRequests); err != nil {
 		s.logger.Error(err, "Prometheus number of running requests gauge register failed")
 		return err
 	}
@@ -72,7 +85,7 @@ func (s *VllmSimulator) createAndRegisterPrometheus() error {
 		[]string{vllmapi.PromLabelModelName},
 	)
 
-	if err := prometheus.Register(s.waitingRequests); err != nil {
+	if err := s.registry.Register(s.waitingRequests); err != nil {
 		s.logger.Error(err, "Prometheus number of requests in queue gauge register failed")
 		return err
 	}
@@ -87,7 +100,7 @@ func (s *VllmSimulator) createAndRegisterPrometheus() error {
 		[]string{vllmapi.PromLabelModelName},
 	)
 
-	if err := prometheus.Register(s.kvCacheUsagePercentage); err != nil {
+	if err := s.registry.Register(s.kvCacheUsagePercentage); err != nil {
 		s.logger.Error(err, "Prometheus kv cache usage percentage gauge register failed")
 		return err
 	}
@@ -100,35 +113,74 @@ func (s *VllmSimulator) createAndRegisterPrometheus() error {
 // setInitialPrometheusMetrics sends the default values to prometheus or
 // the fake metrics if set
 func (s *VllmSimulator) setInitialPrometheusMetrics() {
+	config := s.getConfig()
 	var nRunningReqs, nWaitingReqs, kvCacheUsage float64
-	if s.config.FakeMetrics != nil {
-		nRunningReqs = float64(s.config.FakeMetrics.RunningRequests)
-		nWaitingReqs = float64(s.config.FakeMetrics.WaitingRequests)
-		kvCacheUsage = float64(s.config.FakeMetrics.KVCacheUsagePercentage)
+	if config.FakeMetrics != nil {
+		nRunningReqs, nWaitingReqs, kvCacheUsage = config.FakeMetrics.ValuesAt(0)
 	}
-	modelName := s.getDisplayedModelName(s.config.Model)
-	s.runningRequests.WithLabelValues(modelName).Set(nRunningReqs)
-	s.waitingRequests.WithLabelValues(modelName).Set(nWaitingReqs)
-	s.kvCacheUsagePercentage.WithLabelValues(modelName).Set(kvCacheUsage)
+	s.setModelsMetrics(config, nRunningReqs, nWaitingReqs, kvCacheUsage)
 
-	if s.config.FakeMetrics != nil && len(s.config.FakeMetrics.LoraMetrics) != 0 {
-		for _, metrics := range s.config.FakeMetrics.LoraMetrics {
+	if config.FakeMetrics != nil && len(config.FakeMetrics.LoraMetrics) != 0 {
+		for _, metrics := range config.FakeMetrics.LoraMetrics {
 			s.loraInfo.WithLabelValues(
-				strconv.Itoa(s.config.MaxLoras),
+				strconv.Itoa(config.MaxLoras),
 				metrics.RunningLoras,
 				metrics.WaitingLoras).Set(metrics.Timestamp)
 		}
 	} else {
 		s.loraInfo.WithLabelValues(
-			strconv.Itoa(s.config.MaxLoras),
+			strconv.Itoa(config.MaxLoras),
 			"",
 			"").Set(float64(time.Now().Unix()))
 	}
 }
 
+// setModelsMetrics sets the running and waiting requests and the KV cache usage of all the base models
+func (s *VllmSimulator) setModelsMetrics(config *common.Configuration, nRunningReqs float64, nWaitingReqs float64,
+	kvCacheUsage float64) {
+	for _, modelName := range s.baseModelMetricNames(config) {
+		s.runningRequests.WithLabelValues(modelName).Set(nRunningReqs)
+		s.waitingRequests.WithLabelValues(modelName).Set(nWaitingReqs)
+	}
+	s.setKVCacheUsage(config, kvCacheUsage)
+}
+
+// setKVCacheUsage sets the KV cache usage of all the base models, zero while the simulator is sleeping
+func (s *VllmSimulator) setKVCacheUsage(config *common.Configuration, kvCacheUsage float64) {
+	if s.sleeping.Load() {
+		kvCacheUsage = 0
+	}
+	for _, modelName := range s.baseModelMetricNames(config) {
+		s.kvCacheUsagePercentage.WithLabelValues(modelName).Set(kvCacheUsage)
+	}
+}
+
+// baseModelMetricNames returns the names of the base models in the metrics
+func (s *VllmSimulator) baseModelMetricNames(config *common.Configuration) []string {
+	modelNames := []string{s.getDisplayedModelName(config.Model)}
+	for _, model := range config.AdditionalModels {
+		modelNames = append(modelNames, model.ServedModelNames[0])
+	}
+	return modelNames
+}
+
+// reportKVCacheUsage reports the KV cache usage after the simulator falls asleep or wakes up
+func (s *VllmSimulator) reportKVCacheUsage() {
+	if s.kvCacheUsagePercentage == nil {
+		// Happens in the tests
+		return
+	}
+	config := s.getConfig()
+	var kvCacheUsage float64
+	if config.FakeMetrics != nil {
+		_, _, kvCacheUsage = config.FakeMetrics.ValuesAt(0)
+	}
+	s.setKVCacheUsage(config, kvCacheUsage)
+}
+
 // reportLoras sets information about loaded LoRA adapters
 func (s *VllmSimulator) reportLoras() {
-	if s.config.FakeMetrics != nil {
+	if s.getConfig().FakeMetrics != nil {
 		return
 	}
 	if s.loraInfo == nil {
@@ -152,38 +204,36 @@ func (s *VllmSimulator) reportLoras() {
 	})
 
 	s.loraInfo.WithLabelValues(
-		strconv.Itoa(s.config.MaxLoras),
+		strconv.Itoa(s.getConfig().MaxLoras),
 		strings.Join(runningLoras, ","),
 		strings.Join(waitingLoras, ",")).Set(float64(time.Now().Unix()))
 }
 
-// reportRunningRequests sets information about running completion requests
-func (s *VllmSimulator) reportRunningRequests() {
-	if s.config.FakeMetrics != nil {
+// reportRunningRequests sets information about running completion requests of the given base model
+func (s *VllmSimulator) reportRunningRequests(model string) {
+	if s.getConfig().FakeMetrics != nil {
 		return
 	}
 	if s.runningRequests != nil {
-		s.runningRequests.WithLabelValues(
-			s.getDisplayedModelName(s.config.Model)).Set(float64(s.nRunningReqs))
+		s.runningRequests.WithLabelValues(model).Set(float64(s.nRunningReqs[model]))
 	}
 }
 
-// reportWaitingRequests sets information about waiting completion requests
-func (s *VllmSimulator) reportWaitingRequests() {
-	if s.config.FakeMetrics != nil {
+// reportWaitingRequests sets information about waiting completion requests of the given base model
+func (s *VllmSimulator) reportWaitingRequests(model string) {
+	if s.getConfig().FakeMetrics != nil {
 		return
 	}
 	if s.waitingRequests != nil {
-		s.waitingRequests.WithLabelValues(
-			s.getDisplayedModelName(s.config.Model)).Set(float64(s.nWaitingReqs))
+		s.waitingRequests.WithLabelValues(model).Set(float64(s.nWaitingReqs[model]))
 	}
 }
 
 func (s *VllmSimulator) unregisterPrometheus() {
-	prometheus.Unregister(s.loraInfo)
-	prometheus.Unregister(s.runningRequests)
-	prometheus.Unregister(s.waitingRequests)
-	prometheus.Unregister(s.kvCacheUsagePercentage)
+	s.registry.Unregister(s.loraInfo)
+	s.registry.Unregister(s.runningRequests)
+	s.registry.Unregister(s.waitingRequests)
+	s.registry.Unregister(s.kvCacheUsagePercentage)
 }
 
 // startMetricsUpdaters starts the various metrics updaters
@@ -191,6 +241,7 @@ func (s *VllmSimulator) startMetricsUpdaters(ctx context.Context) {
 	go s.waitingRequestsUpdater(ctx)
 	go s.runningRequestsUpdater(ctx)
 	go s.lorasUpdater(ctx)
+	go s.fakeMetricsUpdater(ctx)
 }
 
 // waitingRequestsUpdater updates the waiting requests metric by listening on the relevant channel
@@ -199,9 +250,9 @@ func (s *VllmSimulator) waitingRequestsUpdater(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case inc := <-s.waitingReqChan:
-			s.nWaitingReqs += inc
-			s.reportWaitingRequests()
+		case update := <-s.waitingReqChan:
+			s.nWaitingReqs[update.model] += update.inc
+			s.reportWaitingRequests(update.model)
 		}
 	}
 }
@@ -212,9 +263,40 @@ func (s *VllmSimulator) runningRequestsUpdater(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case inc := <-s.runReqChan:
-			s.nRunningReqs += inc
-			s.reportRunningRequests()
+		case update := <-s.runReqChan:
+			s.nRunningReqs[update.model] += update.inc
+			s.reportRunningRequests(update.model)
+		}
+	}
+}
+
+// fakeMetricsUpdater updates the fake metrics that change over time, their series restart
+// when the fake metrics are changed
+func (s *VllmSimulator) fakeMetricsUpdater(ctx context.Context) {
+	if s.runningRequests == nil {
+		// Happens in the tests
+		return
+	}
+
+	var fakeMetrics *common.Metrics
+	var start time.Time
+	for {
+		config := s.getConfig()
+		if config.FakeMetrics != fakeMetrics {
+			fakeMetrics = config.FakeMetrics
+			start = time.Now()
+		}
+		interval := fakeMetricsCheckInterval
+		if fakeMetrics != nil && fakeMetrics.HasSeries() {
+			nRunningReqs, nWaitingReqs, kvCacheUsage := fakeMetrics.ValuesAt(time.Since(start))
+			s.setModelsMetrics(config, nRunningReqs, nWaitingReqs, kvCacheUsage)
+			interval = fakeMetrics.GetUpdateInterval()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
 		}
 	}
 }
@@ -235,6 +317,11 @@ func (s *VllmSimulator) lorasUpdater(ctx context.Context) {
 				s.incrementLoraRefCount(loraUpdate.name, &s.runningLoras)
 			case doneUsageState:
 				s.decrementLoraRefCount(loraUpdate.name, &s.runningLoras)
+			case preemptedUsageState:
+				s.decrementLoraRefCount(loraUpdate.name, &s.runningLoras)
+				s.incrementLoraRefCount(loraUpdate.name, &s.waitingLoras)
+			case abortedUsageState:
+				s.decrementLoraRefCount(loraUpdate.name, &s.waitingLoras)
 			}
 			s.reportLoras()
 		}
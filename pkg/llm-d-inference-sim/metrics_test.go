@@ -114,6 +114,33 @@ var _ = Describe("Simulator metrics", Ordered, func() {
 		wg.Wait()
 	})
 
+	It("Should send running requests metrics per base model", func() {
+		ctx := context.TODO()
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom,
+			"--time-to-first-token", "3000",
+			"--additional-models", `{"name":"model2","served-model-name":["model2-alias"]}`}
+
+		s, client, err := startServerWithArgsAndMetrics(ctx, common.ModeRandom, args, nil, true)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.unregisterPrometheus()
+
+		go func() {
+			defer GinkgoRecover()
+			resp, err := client.Post("http://localhost/v1/completions", "application/json",
+				strings.NewReader(`{"prompt": "This is a test.", "model": "model2-alias", "max_tokens": 2}`))
+			Expect(err).NotTo(HaveOccurred())
+			_ = readBody(resp)
+		}()
+
+		time.Sleep(300 * time.Millisecond)
+		metricsResp, err := client.Get(metricsUrl)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(metricsResp.StatusCode).To(Equal(http.StatusOK))
+		metrics := readBody(metricsResp)
+		Expect(metrics).To(ContainSubstring("vllm:num_requests_running{model_name=\"model2-alias\"} 1"))
+		Expect(metrics).To(ContainSubstring("vllm:num_requests_running{model_name=\"" + model + "\"} 0"))
+	})
+
 	It("Should send correct lora metrics", func() {
 		ctx := context.TODO()
 		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom,
@@ -171,7 +198,7 @@ var _ = Describe("Simulator metrics", Ordered, func() {
 	It("Should send correct lora metrics for parallel requests with delay", func() {
 		ctx := context.TODO()
 		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom,
-			"--time-to-first-token", "3000",
+			"--time-to-first-token", "3000", "--max-loras", "2",
 			"--lora-modules", "{\"name\":\"lora1\",\"path\":\"/path/to/lora1\"}",
 			"{\"name\":\"lora2\",\"path\":\"/path/to/lora2\"}"}
 
@@ -249,7 +276,7 @@ var _ = Describe("Simulator metrics", Ordered, func() {
 	It("Should send correct lora metrics for parallel requests without delay", func() {
 		ctx := context.TODO()
 		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom,
-			"--time-to-first-token", "3000",
+			"--time-to-first-token", "3000", "--max-loras", "2",
 			"--lora-modules", "{\"name\":\"lora1\",\"path\":\"/path/to/lora1\"}",
 			"{\"name\":\"lora2\",\"path\":\"/path/to/lora2\"}"}
 
@@ -346,6 +373,36 @@ var _ = Describe("Simulator metrics", Ordered, func() {
 			Expect(metrics).To(ContainSubstring("vllm:lora_requests_info{max_lora=\"1\",running_lora_adapters=\"lora4,lora2\",waiting_lora_adapters=\"lora3\"} 1.257894567e+09"))
 			Expect(metrics).To(ContainSubstring("vllm:lora_requests_info{max_lora=\"1\",running_lora_adapters=\"lora4,lora3\",waiting_lora_adapters=\"\"} 1.257894569e+09"))
 		})
+
+		It("Should replay fake metrics that change over time", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			args := []string{"cmd", "--model", model, "--mode", common.ModeRandom,
+				"--fake-metrics",
+				`{"waiting-requests":3,"update-interval":50,` +
+					`"running-requests-series":{"type":"step","points":[{"time":0,"value":1},{"time":400,"value":5},{"time":800,"value":0}],"loop":true},` +
+					`"kv-cache-usage-series":{"type":"ramp","points":[{"time":0,"value":0},{"time":60000,"value":1}]}}`,
+			}
+
+			s, client, err := startServerWithArgsAndMetrics(ctx, common.ModeRandom, args, nil, true)
+			Expect(err).NotTo(HaveOccurred())
+			defer s.unregisterPrometheus()
+
+			getMetrics := func() string {
+				resp, err := client.Get(metricsUrl)
+				Expect(err).NotTo(HaveOccurred())
+				return readBody(resp)
+			}
+			Expect(getMetrics()).To(ContainSubstring("vllm:num_requests_running{model_name=\"my_model\"} 1"))
+			Eventually(getMetrics, 2*time.Second, 20*time.Millisecond).Should(
+				ContainSubstring("vllm:num_requests_running{model_name=\"my_model\"} 5"))
+			// the series loops back to its first value
+			Eventually(getMetrics, 2*time.Second, 20*time.Millisecond).Should(
+				ContainSubstring("vllm:num_requests_running{model_name=\"my_model\"} 1"))
+			metrics := getMetrics()
+			Expect(metrics).To(ContainSubstring("vllm:num_requests_waiting{model_name=\"my_model\"} 3"))
+			Expect(metrics).NotTo(ContainSubstring("vllm:gpu_cache_usage_perc{model_name=\"my_model\"} 0\n"))
+		})
 	})
 })
 
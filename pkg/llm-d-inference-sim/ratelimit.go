@@ -0,0 +1,245 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains functions related to the rate limits of requests and tokens per minute
+
+package llmdinferencesim
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+const (
+	// Rate limit headers, as sent by OpenAI
+	limitRequestsHeader     = "x-ratelimit-limit-requests"
+	limitTokensHeader       = "x-ratelimit-limit-tokens"
+	remainingRequestsHeader = "x-ratelimit-remaining-requests"
+	remainingTokensHeader   = "x-ratelimit-remaining-tokens"
+	resetRequestsHeader     = "x-ratelimit-reset-requests"
+	resetTokensHeader       = "x-ratelimit-reset-tokens"
+	retryAfterHeader        = "Retry-After"
+
+	rateLimitExceededMessageTemplate = "Rate limit reached for %s on %s per min (%s): Limit %d, Used %d, Requested %d. Please try again in %s."
+	requestTooLargeMessageTemplate   = "Request too large for %s on tokens per min (TPM): Limit %d, Requested %d."
+
+	// rateLimitCleanupInterval is the interval of removing the full token buckets, so the buckets of keys
+	// that are no longer used do not accumulate
+	rateLimitCleanupInterval = time.Minute
+)
+
+// tokenBucket is a token bucket that is refilled continuously, a full bucket is refilled in a minute
+type tokenBucket struct {
+	// available is the number of available tokens
+	available float64
+	// lastRefill is the time of the last refill
+	lastRefill time.Time
+}
+
+// newTokenBucket creates a full token bucket of the given limit
+func newTokenBucket(limit int, now time.Time) *tokenBucket {
+	return &tokenBucket{available: float64(limit), lastRefill: now}
+}
+
+// refill adds the tokens since the last refill, up to the given limit
+func (b *tokenBucket) refill(limit int, now time.Time) {
+	b.available = min(float64(limit), b.available+float64(limit)*now.Sub(b.lastRefill).Minutes())
+	b.lastRefill = now
+}
+
+// waitTime returns the time until the given number of tokens are available
+func (b *tokenBucket) waitTime(amount int, limit int) time.Duration {
+	if b.available >= float64(amount) {
+		return 0
+	}
+	return time.Duration((float64(amount) - b.available) / float64(limit) * float64(time.Minute))
+}
+
+// remaining returns the number of available tokens
+func (b *tokenBucket) remaining() int {
+	return int(math.Floor(b.available))
+}
+
+// rateLimit is the state of one of the limits of a key, requests or tokens, after a request
+type rateLimit struct {
+	// limit is the number of requests or tokens allowed per minute
+	limit int
+	// remaining is the number of remaining requests or tokens
+	remaining int
+	// reset is the time until the limit is fully reset
+	reset time.Duration
+	// wait is the time until the request can be retried, 0 if the request is allowed
+	wait time.Duration
+}
+
+// rateLimiter holds the token buckets of the requests and tokens limits of each rate limit key
+type rateLimiter struct {
+	mutex    sync.Mutex
+	requests map[string]*tokenBucket
+	tokens   map[string]*tokenBucket
+	// lastCleanup is the time of the last removal of the full buckets
+	lastCleanup time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		requests:    make(map[string]*tokenBucket),
+		tokens:      make(map[string]*tokenBucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+// take takes a request with the given number of tokens from the buckets of the given key, if both limits allow it,
+// returns the state of the requests and tokens limits, a limit of 0 is not checked and its state is nil
+func (l *rateLimiter) take(key string, numOfTokens int, requestsLimit int,
+	tokensLimit int) (*rateLimit, *rateLimit) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastCleanup) >= rateLimitCleanupInterval {
+		removeFullBuckets(l.requests, requestsLimit, now)
+		removeFullBuckets(l.tokens, tokensLimit, now)
+		l.lastCleanup = now
+	}
+	requests := l.check(l.requests, key, 1, requestsLimit, now)
+	tokens := l.check(l.tokens, key, numOfTokens, tokensLimit, now)
+	if (requests != nil && requests.wait > 0) || (tokens != nil && tokens.wait > 0) {
+		return requests, tokens
+	}
+
+	if requests != nil {
+		l.requests[key].available--
+		*requests = l.state(l.requests[key], requestsLimit)
+	}
+	if tokens != nil {
+		l.tokens[key].available -= float64(numOfTokens)
+		*tokens = l.state(l.tokens[key], tokensLimit)
+	}
+	return requests, tokens
+}
+
+// check refills the bucket of the given key, and returns the state of its limit for the given amount
+func (l *rateLimiter) check(buckets map[string]*tokenBucket, key string, amount int, limit int,
+	now time.Time) *rateLimit {
+	if limit == 0 {
+		return nil
+	}
+	bucket, ok := buckets[key]
+	if !ok {
+		bucket = newTokenBucket(limit, now)
+		buckets[key] = bucket
+	}
+	bucket.refill(limit, now)
+	state := l.state(bucket, limit)
+	state.wait = bucket.waitTime(amount, limit)
+	return &state
+}
+
+// removeFullBuckets refills the buckets and removes the full ones, a full bucket is the same as the new
+// bucket that is created on the next request of its key, all the buckets are removed if the limit is 0
+func removeFullBuckets(buckets map[string]*tokenBucket, limit int, now time.Time) {
+	for key, bucket := range buckets {
+		bucket.refill(limit, now)
+		if bucket.available >= float64(limit) {
+			delete(buckets, key)
+		}
+	}
+}
+
+// state returns the state of the limit of the given bucket
+func (l *rateLimiter) state(bucket *tokenBucket, limit int) rateLimit {
+	return rateLimit{
+		limit:     limit,
+		remaining: bucket.remaining(),
+		reset:     bucket.waitTime(limit, limit),
+	}
+}
+
+// checkRateLimits checks the rate limits of the request, sets the rate limit headers of the response,
+// and sends a rate limit error if the request exceeds the limits, returns true if the request is allowed
+func (s *VllmSimulator) checkRateLimits(ctx *fasthttp.RequestCtx, req openaiserverapi.CompletionRequest) bool {
+	config := s.getConfig()
+	if config.RateLimitRequestsPerMinute == 0 && config.RateLimitTokensPerMinute == 0 {
+		return true
+	}
+
+	key := req.GetModel()
+	if config.RateLimitBy == common.RateLimitByAPIKey {
+		key, _ = getBearerToken(ctx)
+	}
+	numOfTokens := req.GetNumberOfPromptTokens()
+	if req.GetMaxCompletionTokens() != nil {
+		numOfTokens += int(*req.GetMaxCompletionTokens())
+	}
+
+	if config.RateLimitTokensPerMinute != 0 && numOfTokens > config.RateLimitTokensPerMinute {
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(
+			fmt.Sprintf(requestTooLargeMessageTemplate, req.GetModel(), config.RateLimitTokensPerMinute, numOfTokens),
+			fasthttp.StatusTooManyRequests, nil), false)
+		return false
+	}
+
+	requests, tokens := s.rateLimiter.take(key, numOfTokens, config.RateLimitRequestsPerMinute,
+		config.RateLimitTokensPerMinute)
+	if requests != nil {
+		setRateLimitHeaders(ctx, requests, limitRequestsHeader, remainingRequestsHeader, resetRequestsHeader)
+	}
+	if tokens != nil {
+		setRateLimitHeaders(ctx, tokens, limitTokensHeader, remainingTokensHeader, resetTokensHeader)
+	}
+
+	var message string
+	var wait time.Duration
+	if requests != nil && requests.wait > 0 {
+		wait = requests.wait
+		message = fmt.Sprintf(rateLimitExceededMessageTemplate, req.GetModel(), "requests", "RPM",
+			requests.limit, requests.limit-requests.remaining, 1, formatRateLimitDuration(wait))
+	}
+	if tokens != nil && tokens.wait > wait {
+		wait = tokens.wait
+		message = fmt.Sprintf(rateLimitExceededMessageTemplate, req.GetModel(), "tokens", "TPM",
+			tokens.limit, tokens.limit-tokens.remaining, numOfTokens, formatRateLimitDuration(wait))
+	}
+	if wait == 0 {
+		return true
+	}
+
+	ctx.Response.Header.Set(retryAfterHeader, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(message, fasthttp.StatusTooManyRequests, nil), false)
+	return false
+}
+
+// setRateLimitHeaders sets the headers of the given limit in the response
+func setRateLimitHeaders(ctx *fasthttp.RequestCtx, limit *rateLimit, limitHeader string, remainingHeader string,
+	resetHeader string) {
+	ctx.Response.Header.Set(limitHeader, strconv.Itoa(limit.limit))
+	ctx.Response.Header.Set(remainingHeader, strconv.Itoa(limit.remaining))
+	ctx.Response.Header.Set(resetHeader, formatRateLimitDuration(limit.reset))
+}
+
+// formatRateLimitDuration formats the given duration like OpenAI's rate limit headers, e.g., 1s, 6m0s, 20ms
+func formatRateLimitDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
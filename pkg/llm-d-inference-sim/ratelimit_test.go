@@ -0,0 +1,205 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	zmq "github.com/pebbe/zmq4"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+var _ = Describe("Rate limits", func() {
+	It("should refill a token bucket continuously", func() {
+		now := time.Now()
+		bucket := newTokenBucket(60, now)
+		Expect(bucket.remaining()).To(Equal(60))
+		Expect(bucket.waitTime(60, 60)).To(BeZero())
+
+		bucket.available = 0
+		Expect(bucket.waitTime(1, 60)).To(Equal(time.Second))
+		bucket.refill(60, now.Add(10*time.Second))
+		Expect(bucket.remaining()).To(Equal(10))
+		bucket.refill(60, now.Add(10*time.Minute))
+		Expect(bucket.remaining()).To(Equal(60))
+	})
+
+	It("should not take from the buckets of a denied request", func() {
+		limiter := newRateLimiter()
+		requests, tokens := limiter.take("key", 80, 10, 100)
+		Expect(requests.wait).To(BeZero())
+		Expect(requests.remaining).To(Equal(9))
+		Expect(tokens.wait).To(BeZero())
+		Expect(tokens.remaining).To(Equal(20))
+
+		requests, tokens = limiter.take("key", 80, 10, 100)
+		Expect(tokens.wait).To(BeNumerically(">", 30*time.Second))
+		Expect(requests.remaining).To(Equal(9))
+
+		requests, tokens = limiter.take("key", 10, 10, 0)
+		Expect(tokens).To(BeNil())
+		Expect(requests.remaining).To(Equal(8))
+	})
+
+	It("should remove the full buckets", func() {
+		limiter := newRateLimiter()
+		limiter.take("key1", 10, 60, 100)
+		limiter.take("key2", 10, 60, 100)
+		Expect(limiter.requests).To(HaveLen(2))
+		Expect(limiter.tokens).To(HaveLen(2))
+
+		// the buckets of key1 are full again
+		limiter.requests["key1"].lastRefill = time.Now().Add(-time.Minute)
+		limiter.tokens["key1"].lastRefill = time.Now().Add(-time.Minute)
+		limiter.lastCleanup = time.Now().Add(-rateLimitCleanupInterval)
+		limiter.take("key3", 10, 60, 100)
+		for _, buckets := range []map[string]*tokenBucket{limiter.requests, limiter.tokens} {
+			Expect(buckets).NotTo(HaveKey("key1"))
+			Expect(buckets).To(HaveKey("key2"))
+			Expect(buckets).To(HaveKey("key3"))
+		}
+	})
+
+	Describe("Simulator with rate limits", func() {
+		const completionRequest = `{"prompt": "This is a test", "model": "my_model", "max_tokens": 10}`
+
+		sendRequest := func(client *http.Client, apiKey string) *http.Response {
+			req, err := http.NewRequest(http.MethodPost, "http://localhost/v1/completions",
+				strings.NewReader(completionRequest))
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			if apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+apiKey)
+			}
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			return resp
+		}
+
+		startRateLimitServer := func(args ...string) *http.Client {
+			client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+				append([]string{"cmd", "--model", model, "--mode", common.ModeRandom}, args...), nil)
+			Expect(err).NotTo(HaveOccurred())
+			return client
+		}
+
+		It("should limit the requests per minute of each API key", func() {
+			client := startRateLimitServer("--rate-limit-requests-per-minute", "2")
+
+			for _, remaining := range []string{"1", "0"} {
+				resp := sendRequest(client, "key1")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.Header.Get(limitRequestsHeader)).To(Equal("2"))
+				Expect(resp.Header.Get(remainingRequestsHeader)).To(Equal(remaining))
+				Expect(resp.Header.Get(resetRequestsHeader)).NotTo(BeEmpty())
+				Expect(resp.Header.Get(limitTokensHeader)).To(BeEmpty())
+				_ = readBody(resp)
+			}
+
+			resp := sendRequest(client, "key1")
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(resp.Header.Get(retryAfterHeader)).To(Equal("30"))
+			Expect(resp.Header.Get(remainingRequestsHeader)).To(Equal("0"))
+			Expect(readBody(resp)).To(ContainSubstring("on requests per min (RPM): Limit 2, Used 2"))
+
+			// another key has its own limit
+			resp = sendRequest(client, "key2")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			_ = readBody(resp)
+		})
+
+		It("should limit the tokens per minute of each model", func() {
+			client := startRateLimitServer("--rate-limit-tokens-per-minute", "20", "--rate-limit-by", common.RateLimitByModel)
+
+			resp := sendRequest(client, "key1")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get(limitTokensHeader)).To(Equal("20"))
+			Expect(resp.Header.Get(remainingTokensHeader)).To(Equal("6"))
+			_ = readBody(resp)
+
+			// the limit is shared by all the API keys
+			resp = sendRequest(client, "key2")
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(resp.Header.Get(retryAfterHeader)).To(Equal("24"))
+			Expect(readBody(resp)).To(ContainSubstring("on tokens per min (TPM): Limit 20, Used 14, Requested 14"))
+		})
+
+		It("should reject requests larger than the tokens limit", func() {
+			client := startRateLimitServer("--rate-limit-tokens-per-minute", "10")
+
+			resp := sendRequest(client, "")
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(resp.Header.Get(retryAfterHeader)).To(BeEmpty())
+			Expect(readBody(resp)).To(ContainSubstring("Request too large"))
+		})
+
+		It("should not store the blocks of rate limited requests in the kv cache", func() {
+			zctx, err := zmq.NewContext()
+			Expect(err).NotTo(HaveOccurred())
+			sub, err := zctx.NewSocket(zmq.SUB)
+			Expect(err).NotTo(HaveOccurred())
+			//nolint
+			defer sub.Close()
+			Expect(sub.Bind("tcp://*:5558")).To(Succeed())
+			Expect(sub.SetSubscribe("")).To(Succeed())
+			Expect(sub.SetRcvtimeo(2 * time.Second)).To(Succeed())
+
+			kvCacheModel := "Qwen/Qwen2-0.5B"
+			client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+				[]string{"cmd", "--model", kvCacheModel, "--mode", common.ModeRandom, "--enable-kvcache",
+					"--zmq-endpoint", "tcp://localhost:5558", "--event-batch-size", "1", "--block-size", "8",
+					"--rate-limit-requests-per-minute", "1"}, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			// Make sure that the subscriber listens before the events are published
+			time.Sleep(time.Second)
+
+			sendPrompt := func(prompt string) int {
+				resp, err := client.Post("http://localhost/v1/completions", "application/json",
+					strings.NewReader(`{"prompt": "`+prompt+`", "model": "`+kvCacheModel+`", "max_tokens": 2}`))
+				Expect(err).NotTo(HaveOccurred())
+				_ = readBody(resp)
+				return resp.StatusCode
+			}
+
+			// receiveEvents returns the number of event messages that are received until the receive times out
+			receiveEvents := func() int {
+				count := 0
+				for {
+					if _, err := sub.RecvMessageBytes(0); err != nil {
+						return count
+					}
+					count++
+				}
+			}
+
+			Expect(sendPrompt("The quick brown fox jumps over the lazy dog while the cat sleeps on the warm mat")).
+				To(Equal(http.StatusOK))
+			Expect(receiveEvents()).To(BeNumerically(">", 0))
+
+			Expect(sendPrompt("A rate limited request should never reach the kv cache of the simulated pod at all")).
+				To(Equal(http.StatusTooManyRequests))
+			Expect(receiveEvents()).To(BeZero())
+		})
+	})
+})
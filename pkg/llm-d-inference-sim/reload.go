@@ -0,0 +1,107 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains the reload of the configuration while the simulator is running
+
+package llmdinferencesim
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+// Reload requests the simulator to read its configuration file and command line parameters again,
+// and to apply the changes that can be applied at runtime
+func (s *VllmSimulator) Reload() {
+	notify(s.reload)
+}
+
+// watchConfig reloads the configuration when a reload is requested or when the configuration file
+// changes, and passes the reloaded configuration to apply, until the context is cancelled
+func watchConfig(ctx context.Context, logger logr.Logger, config *common.Configuration, reload chan struct{},
+	apply func(*common.Configuration)) {
+	// the file is polled, since the files mounted from a ConfigMap are replaced using symbolic links
+	var fileChanges <-chan time.Time
+	var content []byte
+	if config.ConfigFile != "" && config.ConfigReloadInterval > 0 {
+		ticker := time.NewTicker(time.Duration(config.ConfigReloadInterval) * time.Millisecond)
+		defer ticker.Stop()
+		fileChanges = ticker.C
+		content, _ = os.ReadFile(config.ConfigFile)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+			logger.Info("Configuration reload requested")
+		case <-fileChanges:
+			newContent, err := os.ReadFile(config.ConfigFile)
+			if err != nil {
+				logger.Error(err, "failed to read the configuration file", "file", config.ConfigFile)
+				continue
+			}
+			if bytes.Equal(newContent, content) {
+				continue
+			}
+			content = newContent
+			logger.Info("Configuration file changed", "file", config.ConfigFile)
+		}
+
+		reloaded, err := common.ParseCommandParamsAndLoadConfig()
+		if err != nil {
+			logger.Error(err, "failed to reload the configuration, the configuration is not changed")
+			continue
+		}
+		apply(reloaded)
+	}
+}
+
+// applyReloadedConfig applies the changes in the given reloaded configuration that can be applied
+// at runtime, the other changes are logged and ignored
+func (s *VllmSimulator) applyReloadedConfig(reloaded *common.Configuration) {
+	// the reload and the admin API change the configuration one at a time
+	s.adminMutex.Lock()
+	defer s.adminMutex.Unlock()
+
+	current := s.getConfig()
+	changes, restartParams, err := current.ReloadChanges(reloaded)
+	if err != nil {
+		s.logger.Error(err, "failed to compare the reloaded configuration")
+		return
+	}
+	if len(restartParams) > 0 {
+		s.logger.Info("Changed configuration parameters require a restart and are ignored", "parameters", restartParams)
+	}
+	if changes == nil {
+		return
+	}
+
+	config, err := current.WithRuntimeChanges(changes)
+	if err != nil {
+		s.logger.Error(err, "failed to apply the reloaded configuration")
+		return
+	}
+	s.updateConfig(config)
+	s.logger.Info("Configuration reloaded", "changes", string(changes))
+}
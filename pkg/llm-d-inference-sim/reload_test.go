@@ -0,0 +1,111 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/klog/v2"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+const reloadTestConfig = `model: "my_model"
+mode: "random"
+time-to-first-token: 0
+max-num-seqs: 5
+lora-modules:
+- '{"name":"lora1"}'
+`
+
+var _ = Describe("Configuration reload", func() {
+	var configFile string
+	var oldArgs []string
+
+	BeforeEach(func() {
+		configFile = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		Expect(os.WriteFile(configFile, []byte(reloadTestConfig), 0o644)).To(Succeed())
+		// the command line is parsed again on reload
+		oldArgs = os.Args
+		os.Args = []string{"cmd", "--config", configFile, "--config-reload-interval", "50"}
+	})
+
+	AfterEach(func() {
+		os.Args = oldArgs
+	})
+
+	startWatchedServer := func(ctx context.Context) (*VllmSimulator, func() int) {
+		simulator, client, err := startServerWithArgsAndMetrics(ctx, "", os.Args, nil, false)
+		Expect(err).NotTo(HaveOccurred())
+		go watchConfig(ctx, klog.Background(), simulator.config, simulator.reload, simulator.applyReloadedConfig)
+		// returns the status of a request to lora2
+		return simulator, func() int {
+			status, _ := sendLoraRequest(client, "completions", `{"prompt": "test", "model": "lora2", "max_tokens": 2}`)
+			return status
+		}
+	}
+
+	It("Should apply the changes of the configuration file at runtime", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		simulator, lora2Status := startWatchedServer(ctx)
+		Expect(lora2Status()).To(Equal(404))
+
+		changed := `model: "my_model"
+mode: "echo"
+time-to-first-token: 100
+max-num-seqs: 8
+lora-modules:
+- '{"name":"lora2"}'
+`
+		Expect(os.WriteFile(configFile, []byte(changed), 0o644)).To(Succeed())
+		Eventually(func() string {
+			return simulator.getConfig().Mode
+		}, 2*time.Second, 20*time.Millisecond).Should(Equal(common.ModeEcho))
+
+		config := simulator.getConfig()
+		Expect(config.TimeToFirstToken).To(Equal(100))
+		Expect(config.MaxNumSeqs).To(Equal(8))
+		Expect(simulator.isLora("lora2")).To(BeTrue())
+		Expect(simulator.isLora("lora1")).To(BeFalse())
+		Expect(lora2Status()).To(Equal(200))
+	})
+
+	It("Should reload the configuration on request and keep it when it is invalid", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		simulator, _ := startWatchedServer(ctx)
+
+		// an invalid command line is not applied
+		os.Args = append(os.Args, "--time-to-first-token", "-1")
+		simulator.Reload()
+		Consistently(func() int {
+			return simulator.getConfig().TimeToFirstToken
+		}, 200*time.Millisecond, 20*time.Millisecond).Should(Equal(0))
+
+		os.Args[len(os.Args)-1] = "300"
+		simulator.Reload()
+		Eventually(func() int {
+			return simulator.getConfig().TimeToFirstToken
+		}, 2*time.Second, 20*time.Millisecond).Should(Equal(300))
+	})
+})
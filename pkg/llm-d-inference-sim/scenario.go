@@ -0,0 +1,207 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains functions related to the playback of scenarios of timed phases
+
+package llmdinferencesim
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/valyala/fasthttp"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+// scenarioPlayer plays back the timed phases of a scenario
+type scenarioPlayer struct {
+	mutex  sync.Mutex
+	logger logr.Logger
+	// scenario is the played scenario, nil if no scenario is played
+	scenario *common.Scenario
+	// start is the time the scenario started
+	start time.Time
+	// phase is the index of the current phase, used to log phase changes
+	phase int
+}
+
+// scenarioStatus is the status of the scenario returned by the admin API
+type scenarioStatus struct {
+	// Running is true if a scenario is played
+	Running bool `json:"running"`
+	// Phase is the name of the current phase
+	Phase string `json:"phase,omitempty"`
+	// Type is the type of the current phase
+	Type string `json:"type,omitempty"`
+	// Elapsed is the time in milliseconds since the scenario started
+	Elapsed int64 `json:"elapsed,omitempty"`
+}
+
+func newScenarioPlayer(logger logr.Logger) *scenarioPlayer {
+	return &scenarioPlayer{logger: logger}
+}
+
+// play plays the given scenario from its beginning
+func (p *scenarioPlayer) play(scenario *common.Scenario) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.scenario = scenario
+	p.start = time.Now()
+	p.phase = -1
+	p.logger.Info("Scenario started", "phases", len(scenario.Phases), "loop", scenario.Loop)
+}
+
+// stop stops playing the scenario
+func (p *scenarioPlayer) stop() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.scenario != nil {
+		p.logger.Info("Scenario stopped")
+	}
+	p.scenario = nil
+}
+
+// currentPhase returns the current phase and the time since it started, nil if no scenario is played
+// or the scenario has ended
+func (p *scenarioPlayer) currentPhase() (*common.ScenarioPhase, time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.scenario == nil {
+		return nil, 0
+	}
+
+	index, elapsed := p.scenario.PhaseAt(time.Since(p.start))
+	if index != p.phase {
+		p.phase = index
+		if index == -1 {
+			p.logger.Info("Scenario ended")
+		} else {
+			phase := p.scenario.Phases[index]
+			p.logger.Info("Scenario phase started", "phase", phase.Name, "type", phase.Type)
+		}
+	}
+	if index == -1 {
+		return nil, 0
+	}
+	return &p.scenario.Phases[index], elapsed
+}
+
+// status returns the status of the scenario
+func (p *scenarioPlayer) status() scenarioStatus {
+	phase, _ := p.currentPhase()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.scenario == nil {
+		return scenarioStatus{}
+	}
+	status := scenarioStatus{Running: phase != nil, Elapsed: time.Since(p.start).Milliseconds()}
+	if phase != nil {
+		status.Phase = phase.Name
+		status.Type = phase.Type
+	}
+	return status
+}
+
+// scenarioHandler returns a handler that fails requests to the /v1 API with 503 during an outage phase,
+// before calling the given handler
+func (s *VllmSimulator) scenarioHandler(handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if strings.HasPrefix(string(ctx.Path()), apiPathPrefix) {
+			if phase, _ := s.scenario.currentPhase(); phase != nil && phase.Type == common.PhaseTypeOutage {
+				s.sendCompletionError(ctx, predefinedFailures[common.FailureTypeServerError], true)
+				return
+			}
+		}
+		handler(ctx)
+	}
+}
+
+// getScenarioFailure returns the failure of a request in an error burst phase, nil if the request
+// should not fail
+func (s *VllmSimulator) getScenarioFailure(config *common.Configuration) *openaiserverapi.CompletionError {
+	phase, _ := s.scenario.currentPhase()
+	if phase == nil || phase.Type != common.PhaseTypeErrorBurst || phase.FailureRate == 0 ||
+		common.RandomInt(1, 100) > phase.FailureRate {
+		return nil
+	}
+	failure := getRandomFailureOfTypes(config, phase.FailureTypes)
+	return &failure
+}
+
+// getScenarioLatencies returns the time to first token and the inter token latency of the current phase,
+// nil if not defined by the phase
+func (s *VllmSimulator) getScenarioLatencies() (*int, *int) {
+	phase, _ := s.scenario.currentPhase()
+	if phase == nil || phase.Type != common.PhaseTypeDegraded {
+		return nil, nil
+	}
+	return phase.TimeToFirstToken, phase.InterTokenLatency
+}
+
+// isScenarioReady returns false if the current phase makes the simulator not ready
+func (s *VllmSimulator) isScenarioReady() bool {
+	phase, elapsed := s.scenario.currentPhase()
+	return phase == nil || phase.IsReady(elapsed)
+}
+
+// HandleStartScenario http handler for POST /admin/scenario/start, plays the scenario from its beginning
+func (s *VllmSimulator) HandleStartScenario(ctx *fasthttp.RequestCtx) {
+	if !s.isAdminAuthorized(ctx) {
+		return
+	}
+	scenario := s.getConfig().GetScenario()
+	if scenario == nil {
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError("No scenario file is configured",
+			fasthttp.StatusBadRequest, nil), false)
+		return
+	}
+	s.scenario.play(scenario)
+	s.sendScenarioStatus(ctx)
+}
+
+// HandleStopScenario http handler for POST /admin/scenario/stop, stops playing the scenario
+func (s *VllmSimulator) HandleStopScenario(ctx *fasthttp.RequestCtx) {
+	if !s.isAdminAuthorized(ctx) {
+		return
+	}
+	s.scenario.stop()
+	s.sendScenarioStatus(ctx)
+}
+
+// HandleGetScenario http handler for GET /admin/scenario, returns the status of the scenario
+func (s *VllmSimulator) HandleGetScenario(ctx *fasthttp.RequestCtx) {
+	if !s.isAdminAuthorized(ctx) {
+		return
+	}
+	s.sendScenarioStatus(ctx)
+}
+
+func (s *VllmSimulator) sendScenarioStatus(ctx *fasthttp.RequestCtx) {
+	data, err := json.Marshal(s.scenario.status())
+	if err != nil {
+		s.logger.Error(err, "Failed to marshal scenario status")
+		ctx.Error("Failed to marshal scenario status, "+err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+	ctx.Response.SetBody(data)
+}
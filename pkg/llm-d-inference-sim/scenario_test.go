@@ -0,0 +1,141 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+const testScenario = `
+phases:
+  - name: slow
+    type: degraded
+    duration: 600
+    time-to-first-token: 300
+  - name: outage
+    type: outage
+    duration: 600
+  - name: errors
+    type: error_burst
+    duration: 600
+    failure-rate: 100
+    failure-types: [rate_limit]
+`
+
+var _ = Describe("Scenario", func() {
+	const completionRequest = `{"prompt": "This is a test", "model": "my_model", "max_tokens": 2}`
+
+	startScenarioServer := func(args ...string) *http.Client {
+		path := filepath.Join(GinkgoT().TempDir(), "scenario.yaml")
+		Expect(os.WriteFile(path, []byte(testScenario), 0o600)).To(Succeed())
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			append([]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--scenario-file", path}, args...), nil)
+		Expect(err).NotTo(HaveOccurred())
+		return client
+	}
+
+	sendCompletion := func(client *http.Client) (int, time.Duration) {
+		start := time.Now()
+		resp, err := client.Post("http://localhost/v1/completions", "application/json",
+			strings.NewReader(completionRequest))
+		Expect(err).NotTo(HaveOccurred())
+		_ = readBody(resp)
+		return resp.StatusCode, time.Since(start)
+	}
+
+	getReadiness := func(client *http.Client) int {
+		resp, err := client.Get("http://localhost/ready")
+		Expect(err).NotTo(HaveOccurred())
+		_ = readBody(resp)
+		return resp.StatusCode
+	}
+
+	It("should play the scenario's phases from the start", func() {
+		start := time.Now()
+		client := startScenarioServer()
+
+		// degraded phase
+		status, duration := sendCompletion(client)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(duration).To(BeNumerically(">=", 300*time.Millisecond))
+		Expect(getReadiness(client)).To(Equal(http.StatusOK))
+
+		// outage phase
+		time.Sleep(time.Until(start.Add(800 * time.Millisecond)))
+		status, _ = sendCompletion(client)
+		Expect(status).To(Equal(http.StatusServiceUnavailable))
+		Expect(getReadiness(client)).To(Equal(http.StatusServiceUnavailable))
+		resp, err := client.Get("http://localhost/health")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		_ = readBody(resp)
+
+		// error burst phase
+		time.Sleep(time.Until(start.Add(1400 * time.Millisecond)))
+		status, _ = sendCompletion(client)
+		Expect(status).To(Equal(http.StatusTooManyRequests))
+		Expect(getReadiness(client)).To(Equal(http.StatusOK))
+
+		// the scenario has ended
+		time.Sleep(time.Until(start.Add(2000 * time.Millisecond)))
+		status, duration = sendCompletion(client)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(duration).To(BeNumerically("<", 300*time.Millisecond))
+	})
+
+	It("should play the scenario when started by the admin API", func() {
+		client := startScenarioServer("--scenario-start", common.ScenarioStartAdmin, "--admin-api-key", "admin")
+
+		sendAdmin := func(method string, path string) scenarioStatus {
+			req, err := http.NewRequest(method, "http://localhost"+path, nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer admin")
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var status scenarioStatus
+			Expect(json.Unmarshal([]byte(readBody(resp)), &status)).To(Succeed())
+			return status
+		}
+
+		Expect(sendAdmin(http.MethodGet, "/admin/scenario").Running).To(BeFalse())
+		_, duration := sendCompletion(client)
+		Expect(duration).To(BeNumerically("<", 300*time.Millisecond))
+
+		status := sendAdmin(http.MethodPost, "/admin/scenario/start")
+		Expect(status.Running).To(BeTrue())
+		Expect(status.Phase).To(Equal("slow"))
+		Expect(status.Type).To(Equal(common.PhaseTypeDegraded))
+		_, duration = sendCompletion(client)
+		Expect(duration).To(BeNumerically(">=", 300*time.Millisecond))
+
+		Expect(sendAdmin(http.MethodPost, "/admin/scenario/stop").Running).To(BeFalse())
+		_, duration = sendCompletion(client)
+		Expect(duration).To(BeNumerically("<", 300*time.Millisecond))
+	})
+})
@@ -0,0 +1,575 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains the waiting requests queue and the scheduling of requests
+package llmdinferencesim
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+// scheduledRequest is a completion request managed by the scheduler
+type scheduledRequest struct {
+	reqCtx *openaiserverapi.CompletionReqCtx
+	// priority is the request's priority, lower values are handled first
+	priority int
+	// lora is the LoRA adapter of the request, empty for requests to the base model
+	lora string
+	// loraLoadLatency is the time in milliseconds to load the request's LoRA adapter
+	// to the GPU, calculated when the request is scheduled
+	loraLoadLatency int
+	// latencyFault is the latency fault injected in the request, nil if none
+	latencyFault *latencyFault
+	// injection is the behavior forced by the request's headers, nil if none
+	injection *requestInjection
+	// transcription is the audio transcription request, nil for completion requests
+	transcription *transcriptionRequest
+	// arrival is the arrival sequence number of the request, requests with the
+	// same priority are handled by their arrival order
+	arrival uint64
+	// started is true if the request's processing has started, i.e., the request
+	// was scheduled at least once
+	started bool
+	// scheduled is true if the request was dequeued by a worker at least once, protected by the queue's mutex
+	scheduled bool
+	// finished is true if the request's processing has finished
+	finished bool
+	// waiting is true while the request's processing waits in wait(), only waiting requests can be
+	// preempted, protected by the queue's mutex
+	waiting bool
+	// preempt is signaled to the request's processing when the request is preempted
+	preempt chan struct{}
+	// preemptWorker is signaled to the worker running the request when the request is preempted
+	preemptWorker chan struct{}
+	// resume is signaled when a preempted request is scheduled again
+	resume chan struct{}
+	// done is closed when the request's processing has finished
+	done chan struct{}
+	// aborted is closed when the request's processing should stop waiting
+	aborted   chan struct{}
+	abortOnce sync.Once
+}
+
+func newScheduledRequest(reqCtx *openaiserverapi.CompletionReqCtx, lora string) *scheduledRequest {
+	return &scheduledRequest{
+		reqCtx:        reqCtx,
+		priority:      reqCtx.CompletionReq.GetPriority(),
+		lora:          lora,
+		preempt:       make(chan struct{}, 1),
+		preemptWorker: make(chan struct{}, 1),
+		resume:        make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		aborted:       make(chan struct{}),
+	}
+}
+
+// abort signals the request's processing to stop waiting
+func (req *scheduledRequest) abort() {
+	req.abortOnce.Do(func() {
+		close(req.aborted)
+	})
+}
+
+// model returns the model of the request
+func (req *scheduledRequest) model() string {
+	return req.reqCtx.CompletionReq.GetModel()
+}
+
+// requestQueue holds the waiting requests and the requests that are currently processed by the workers,
+// requests are dequeued in arrival order, or by priority and then arrival order in case of priority
+// scheduling policy. At most maxLoras different LoRA adapters run at the same time, requests to
+// other LoRA adapters wait until one of the running adapters has no running requests.
+type requestQueue struct {
+	mutex sync.Mutex
+	// priorityPolicy is true if requests are dequeued by their priority
+	priorityPolicy bool
+	// preemption is true if lower priority running requests are preempted when a higher priority request arrives
+	preemption bool
+	waiting    []*scheduledRequest
+	running    map[*scheduledRequest]struct{}
+	// idleWorkers is the number of workers waiting for requests
+	idleWorkers int
+	nextArrival uint64
+	// available is signaled when requests are added to the queue or when the running requests change
+	available chan struct{}
+	// paused is true if the waiting requests are not scheduled, while the simulator is sleeping
+	paused bool
+	// maxRunning is the maximum number of running requests (max-num-seqs), there may be more workers,
+	// since the workers are not stopped when it is decreased
+	maxRunning int
+
+	maxLoras    int
+	maxCPULoras int
+	// loraLoadLatency is the time in milliseconds to load a LoRA adapter from the CPU memory to the GPU
+	loraLoadLatency int
+	// loraDiskLoadLatency is the time in milliseconds to load a LoRA adapter from the disk to the CPU memory
+	loraDiskLoadLatency int
+	// runningLoras is the number of running requests of each LoRA adapter
+	runningLoras map[string]int
+	// gpuLoras are the LoRA adapters loaded to the GPU, from the least recently used
+	gpuLoras []string
+	// cpuLoras are the LoRA adapters loaded to the CPU memory, from the least recently used
+	cpuLoras []string
+	// pendingLoraRemovals are the unregister functions of the LoRA adapters that were removed while
+	// they were in use, the adapters are removed when their last request finishes
+	pendingLoraRemovals map[string]func()
+}
+
+func newRequestQueue(config *common.Configuration) *requestQueue {
+	q := &requestQueue{
+		priorityPolicy:      config.SchedulingPolicy == common.SchedulingPolicyPriority,
+		preemption:          config.PriorityPreemption,
+		running:             make(map[*scheduledRequest]struct{}),
+		available:           make(chan struct{}, 1),
+		maxRunning:          config.MaxNumSeqs,
+		maxLoras:            config.MaxLoras,
+		maxCPULoras:         config.MaxCPULoras,
+		loraLoadLatency:     config.LoraLoadLatency,
+		loraDiskLoadLatency: config.LoraDiskLoadLatency,
+		runningLoras:        make(map[string]int),
+		pendingLoraRemovals: make(map[string]func()),
+	}
+	// LoRA adapters defined in the configuration are loaded to the CPU memory on startup
+	for _, lora := range config.LoraModules {
+		q.loadLoraToCPU(lora.Name)
+	}
+	return q
+}
+
+// setLimits updates the maximum number of running requests, the LoRA limits and load latencies of the
+// queue from the given configuration, running requests above the new limits are not stopped
+func (q *requestQueue) setLimits(config *common.Configuration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	defer notify(q.available)
+
+	q.maxRunning = config.MaxNumSeqs
+	q.maxLoras = config.MaxLoras
+	q.maxCPULoras = config.MaxCPULoras
+	q.loraLoadLatency = config.LoraLoadLatency
+	q.loraDiskLoadLatency = config.LoraDiskLoadLatency
+}
+
+// push adds a new request to the queue, if preemption is enabled and all the workers are busy, the running
+// request with the lowest priority is preempted in favor of the new request if its priority is lower,
+// returns the preempted request or nil. Only requests that wait in wait() are preempted, a request that
+// has finished waiting is about to send its response.
+func (q *requestQueue) push(req *scheduledRequest) *scheduledRequest {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	defer notify(q.available)
+
+	req.arrival = q.nextArrival
+	q.nextArrival++
+	q.waiting = append(q.waiting, req)
+
+	if !q.preemption || len(q.waiting) <= min(q.idleWorkers, q.maxRunning-len(q.running)) {
+		return nil
+	}
+	var victim *scheduledRequest
+	for running := range q.running {
+		if running.waiting && running.priority > req.priority && (victim == nil || q.before(victim, running)) {
+			victim = running
+		}
+	}
+	if victim != nil {
+		q.removeRunning(victim)
+		q.waiting = append(q.waiting, victim)
+		notify(victim.preempt)
+		notify(victim.preemptWorker)
+	}
+	return victim
+}
+
+// pop removes the next request from the queue and marks it as running, blocks until there is
+// a request in the queue, returns nil if the context is cancelled
+func (q *requestQueue) pop(ctx context.Context) *scheduledRequest {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	next := q.next()
+	for next < 0 {
+		q.idleWorkers++
+		q.mutex.Unlock()
+		select {
+		case <-ctx.Done():
+			q.mutex.Lock()
+			q.idleWorkers--
+			return nil
+		case <-q.available:
+		}
+		q.mutex.Lock()
+		q.idleWorkers--
+		next = q.next()
+	}
+
+	req := q.waiting[next]
+	q.waiting = append(q.waiting[:next], q.waiting[next+1:]...)
+	q.running[req] = struct{}{}
+	req.scheduled = true
+	req.loraLoadLatency = 0
+	if req.lora != "" {
+		req.loraLoadLatency = q.loadLoraToGPU(req.lora)
+		q.runningLoras[req.lora]++
+	}
+	if len(q.waiting) > 0 {
+		// let other idle workers handle the remaining requests
+		notify(q.available)
+	}
+	return req
+}
+
+// finish marks the request as finished and removes it from the queue
+func (q *requestQueue) finish(req *scheduledRequest) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if req.finished {
+		return
+	}
+	req.finished = true
+	q.removeRunning(req)
+	if unregister, ok := q.pendingLoraRemovals[req.lora]; ok && q.loraRequests(req.lora) == 0 {
+		delete(q.pendingLoraRemovals, req.lora)
+		q.unloadLora(req.lora, unregister)
+	}
+	close(req.done)
+	// requests to other LoRA adapters may be able to run now
+	notify(q.available)
+}
+
+// startWait marks the request as waiting in wait(), the request can be preempted until endWait
+func (q *requestQueue) startWait(req *scheduledRequest) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	req.waiting = true
+}
+
+// endWait marks the request as no longer waiting in wait(), returns false if the request was preempted,
+// in this case it remains waiting until it is scheduled again
+func (q *requestQueue) endWait(req *scheduledRequest) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, ok := q.running[req]; !ok {
+		return false
+	}
+	req.waiting = false
+	return true
+}
+
+// resumeAborted returns a preempted request that was aborted to the running requests, so its
+// processing can finish like the processing of a running request, returns false if the request
+// was not preempted
+func (q *requestQueue) resumeAborted(req *scheduledRequest) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	i := slices.Index(q.waiting, req)
+	if i < 0 {
+		return false
+	}
+	q.waiting = slices.Delete(q.waiting, i, i+1)
+	q.running[req] = struct{}{}
+	if req.lora != "" {
+		q.runningLoras[req.lora]++
+	}
+	return true
+}
+
+// setPaused pauses or resumes the scheduling of the waiting requests
+func (q *requestQueue) setPaused(paused bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.paused = paused
+	if !paused {
+		notify(q.available)
+	}
+}
+
+// abortWaiting removes the waiting requests that were never scheduled from the queue and marks them
+// as finished, returns the removed requests
+func (q *requestQueue) abortWaiting() []*scheduledRequest {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	aborted := make([]*scheduledRequest, 0)
+	waiting := make([]*scheduledRequest, 0, len(q.waiting))
+	for _, req := range q.waiting {
+		if req.scheduled {
+			waiting = append(waiting, req)
+			continue
+		}
+		req.finished = true
+		close(req.done)
+		aborted = append(aborted, req)
+	}
+	q.waiting = waiting
+	return aborted
+}
+
+// scheduledRequests returns the unfinished requests that were scheduled, the running requests
+// and the preempted requests
+func (q *requestQueue) scheduledRequests() []*scheduledRequest {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	requests := make([]*scheduledRequest, 0, len(q.running))
+	for req := range q.running {
+		requests = append(requests, req)
+	}
+	for _, req := range q.waiting {
+		if req.scheduled {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+// status returns the number of running and waiting requests, and the LoRA adapters of the running
+// and the waiting requests
+func (q *requestQueue) status() (int, int, []string, []string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	runningLoras := make([]string, 0, len(q.runningLoras))
+	for lora := range q.runningLoras {
+		runningLoras = append(runningLoras, lora)
+	}
+	waitingLoras := make([]string, 0)
+	for _, req := range q.waiting {
+		if req.lora != "" && !slices.Contains(waitingLoras, req.lora) {
+			waitingLoras = append(waitingLoras, req.lora)
+		}
+	}
+	slices.Sort(runningLoras)
+	slices.Sort(waitingLoras)
+	return len(q.running), len(q.waiting), runningLoras, waitingLoras
+}
+
+// next returns the index of the next waiting request that can run, or -1 if there is no such request
+func (q *requestQueue) next() int {
+	if q.paused || len(q.running) >= q.maxRunning {
+		return -1
+	}
+	next := -1
+	for i, req := range q.waiting {
+		if q.canRun(req) && (next < 0 || q.before(req, q.waiting[next])) {
+			next = i
+		}
+	}
+	return next
+}
+
+// canRun returns true if the request can run, i.e., it is a request to the base model, or its LoRA
+// adapter is already running, or the number of running LoRA adapters is less than maxLoras
+func (q *requestQueue) canRun(req *scheduledRequest) bool {
+	return req.lora == "" || q.runningLoras[req.lora] > 0 || len(q.runningLoras) < q.maxLoras
+}
+
+// removeRunning removes the request from the running requests
+func (q *requestQueue) removeRunning(req *scheduledRequest) {
+	if _, ok := q.running[req]; !ok {
+		return
+	}
+	delete(q.running, req)
+	if req.lora != "" {
+		q.runningLoras[req.lora]--
+		if q.runningLoras[req.lora] == 0 {
+			delete(q.runningLoras, req.lora)
+		}
+	}
+}
+
+// loadLoraToGPU loads the LoRA adapter to the GPU, if needed, the least recently used adapter that is
+// not running is evicted, returns the load time in milliseconds, which includes the time to load the
+// adapter from the disk if it is not in the CPU memory
+func (q *requestQueue) loadLoraToGPU(lora string) int {
+	latency := 0
+	if !slices.Contains(q.gpuLoras, lora) {
+		latency = q.loraLoadLatency
+		if !slices.Contains(q.cpuLoras, lora) {
+			latency += q.loraDiskLoadLatency
+		}
+		if len(q.gpuLoras) >= q.maxLoras {
+			for i, loaded := range q.gpuLoras {
+				if q.runningLoras[loaded] == 0 {
+					q.gpuLoras = slices.Delete(q.gpuLoras, i, i+1)
+					break
+				}
+			}
+		}
+	}
+	q.gpuLoras = moveToEnd(q.gpuLoras, lora)
+	q.loadLoraToCPU(lora)
+	return latency
+}
+
+// loadLoraToCPU loads the LoRA adapter to the CPU memory, if needed, the least recently used adapter
+// that is not loaded to the GPU is evicted
+func (q *requestQueue) loadLoraToCPU(lora string) {
+	q.cpuLoras = moveToEnd(q.cpuLoras, lora)
+	if len(q.cpuLoras) > q.maxCPULoras {
+		for i, loaded := range q.cpuLoras {
+			if !slices.Contains(q.gpuLoras, loaded) {
+				q.cpuLoras = slices.Delete(q.cpuLoras, i, i+1)
+				break
+			}
+		}
+	}
+}
+
+// addLora loads a new LoRA adapter to the CPU memory
+func (q *requestQueue) addLora(lora string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.loadLoraToCPU(lora)
+}
+
+// cancelLoraRemoval cancels the pending removal of the LoRA adapter, returns false if there is none
+func (q *requestQueue) cancelLoraRemoval(lora string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, ok := q.pendingLoraRemovals[lora]; !ok {
+		return false
+	}
+	delete(q.pendingLoraRemovals, lora)
+	return true
+}
+
+// removeLora unloads the LoRA adapter from the GPU and the CPU memory, the adapter is not unloaded if
+// there are running or waiting requests that use it, returns the number of these requests. unregister
+// is called under the queue's mutex when the adapter is unloaded, so no request to the adapter is queued
+// between the check and the unregistration.
+func (q *requestQueue) removeLora(lora string, unregister func()) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if numOfRequests := q.loraRequests(lora); numOfRequests > 0 {
+		return numOfRequests
+	}
+	q.unloadLora(lora, unregister)
+	return 0
+}
+
+// removeLoraWhenUnused removes the LoRA adapter like removeLora, if the adapter is in use it is removed
+// when its last request finishes, returns the number of requests that use it
+func (q *requestQueue) removeLoraWhenUnused(lora string, unregister func()) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if numOfRequests := q.loraRequests(lora); numOfRequests > 0 {
+		q.pendingLoraRemovals[lora] = unregister
+		return numOfRequests
+	}
+	q.unloadLora(lora, unregister)
+	return 0
+}
+
+// loraRequests returns the number of running and waiting requests that use the LoRA adapter
+func (q *requestQueue) loraRequests(lora string) int {
+	numOfRequests := q.runningLoras[lora]
+	for _, req := range q.waiting {
+		if req.lora == lora {
+			numOfRequests++
+		}
+	}
+	return numOfRequests
+}
+
+// unloadLora unloads the LoRA adapter from the GPU and the CPU memory and unregisters it
+func (q *requestQueue) unloadLora(lora string, unregister func()) {
+	q.gpuLoras = slices.DeleteFunc(q.gpuLoras, func(loaded string) bool { return loaded == lora })
+	q.cpuLoras = slices.DeleteFunc(q.cpuLoras, func(loaded string) bool { return loaded == lora })
+	delete(q.pendingLoraRemovals, lora)
+	unregister()
+}
+
+// moveToEnd moves the value to the end of the slice, adds it if it is not in the slice
+func moveToEnd(values []string, value string) []string {
+	if i := slices.Index(values, value); i >= 0 {
+		values = slices.Delete(values, i, i+1)
+	}
+	return append(values, value)
+}
+
+// before returns true if request1 should be handled before request2
+func (q *requestQueue) before(request1 *scheduledRequest, request2 *scheduledRequest) bool {
+	if q.priorityPolicy && request1.priority != request2.priority {
+		return request1.priority < request2.priority
+	}
+	return request1.arrival < request2.arrival
+}
+
+// notify signals the channel without blocking, the channel's buffer holds a pending signal
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// wait waits for the given number of milliseconds, if the request is preempted during the wait,
+// waits until the request is scheduled again and adds the time to first token to the remaining
+// time, since the request's KV-cache has to be recomputed, and the time to load its LoRA adapter.
+// Returns early if the request is aborted.
+func (s *VllmSimulator) wait(req *scheduledRequest, millis int, doRemotePrefill bool) {
+	remaining := time.Duration(millis) * time.Millisecond
+	if req == nil {
+		time.Sleep(remaining)
+		return
+	}
+	s.queue.startWait(req)
+	for {
+		preempted := false
+		if remaining > 0 {
+			start := time.Now()
+			timer := time.NewTimer(remaining)
+			select {
+			case <-timer.C:
+			case <-req.preempt:
+				timer.Stop()
+				remaining -= time.Since(start)
+				preempted = true
+			case <-req.aborted:
+				timer.Stop()
+				s.endAbortedWait(req)
+				return
+			}
+		}
+		if !preempted {
+			if s.queue.endWait(req) {
+				return
+			}
+			// the request was preempted when its wait ended
+			<-req.preempt
+			remaining = 0
+		}
+		s.logger.Info("Request preempted", "id", req.reqCtx.CompletionReq.GetRequestID())
+		select {
+		case <-req.resume:
+		case <-req.aborted:
+			s.endAbortedWait(req)
+			return
+		}
+		remaining += time.Duration(s.getTimeToFirstToken(req.model(), doRemotePrefill)+req.loraLoadLatency) * time.Millisecond
+	}
+}
+
+// endAbortedWait ends the wait of an aborted request, if the request is preempted it returns to the
+// running requests, so its processing finishes like the processing of a running request
+func (s *VllmSimulator) endAbortedWait(req *scheduledRequest) {
+	if !s.queue.endWait(req) && s.queue.resumeAborted(req) {
+		s.reportRequestRunning(req.model())
+	}
+}
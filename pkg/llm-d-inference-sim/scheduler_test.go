@@ -0,0 +1,327 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+func createScheduledRequest(priority int) *scheduledRequest {
+	var req openaiserverapi.TextCompletionRequest
+	err := json.Unmarshal([]byte(fmt.Sprintf(`{"prompt": "test", "priority": %d}`, priority)), &req)
+	Expect(err).NotTo(HaveOccurred())
+	return newScheduledRequest(&openaiserverapi.CompletionReqCtx{CompletionReq: &req}, "")
+}
+
+func createLoraScheduledRequest(lora string) *scheduledRequest {
+	req := createScheduledRequest(0)
+	req.lora = lora
+	return req
+}
+
+func sendPriorityRequest(client *http.Client, priority int) (*http.Response, error) {
+	reqBody := fmt.Sprintf(`{"prompt": "This is a test.", "model": "my_model", "max_tokens": 2, "priority": %d}`, priority)
+	return client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+}
+
+var _ = Describe("Scheduler", func() {
+	Describe("requestQueue", func() {
+		It("should dequeue requests by arrival order in fcfs policy", func() {
+			queue := newRequestQueue(&common.Configuration{MaxNumSeqs: 10, SchedulingPolicy: common.SchedulingPolicyFCFS})
+			requests := []*scheduledRequest{createScheduledRequest(0), createScheduledRequest(0), createScheduledRequest(0)}
+			for _, req := range requests {
+				Expect(queue.push(req)).To(BeNil())
+			}
+			for _, req := range requests {
+				Expect(queue.pop(context.TODO())).To(Equal(req))
+			}
+		})
+
+		It("should dequeue requests by priority and arrival order in priority policy", func() {
+			queue := newRequestQueue(&common.Configuration{MaxNumSeqs: 10, SchedulingPolicy: common.SchedulingPolicyPriority})
+			low1 := createScheduledRequest(5)
+			high := createScheduledRequest(-1)
+			low2 := createScheduledRequest(5)
+			medium := createScheduledRequest(1)
+			for _, req := range []*scheduledRequest{low1, high, low2, medium} {
+				Expect(queue.push(req)).To(BeNil())
+			}
+			for _, req := range []*scheduledRequest{high, medium, low1, low2} {
+				Expect(queue.pop(context.TODO())).To(Equal(req))
+			}
+		})
+
+		It("should preempt the running request with the lowest priority", func() {
+			queue := newRequestQueue(&common.Configuration{MaxNumSeqs: 10, SchedulingPolicy: common.SchedulingPolicyPriority,
+				PriorityPreemption: true})
+			low1 := createScheduledRequest(5)
+			low2 := createScheduledRequest(5)
+			medium := createScheduledRequest(1)
+			for _, req := range []*scheduledRequest{medium, low1, low2} {
+				Expect(queue.push(req)).To(BeNil())
+				Expect(queue.pop(context.TODO())).To(Equal(req))
+				queue.startWait(req)
+			}
+
+			// a request with the same priority as the lowest running priority does not preempt
+			Expect(queue.push(createScheduledRequest(5))).To(BeNil())
+			// the latest request with the lowest priority is preempted
+			high := createScheduledRequest(0)
+			Expect(queue.push(high)).To(Equal(low2))
+			Expect(low2.preempt).To(HaveLen(1))
+			Expect(low2.preemptWorker).To(HaveLen(1))
+			// the preempted request keeps waiting until it is scheduled again
+			Expect(queue.endWait(low2)).To(BeFalse())
+
+			Expect(queue.pop(context.TODO())).To(Equal(high))
+			// the preempted request is handled before the waiting request with the same priority
+			Expect(queue.pop(context.TODO())).To(Equal(low2))
+			queue.finish(medium)
+			Expect(medium.done).To(BeClosed())
+		})
+
+		It("should not preempt a request that has finished waiting", func() {
+			queue := newRequestQueue(&common.Configuration{MaxNumSeqs: 10, SchedulingPolicy: common.SchedulingPolicyPriority,
+				PriorityPreemption: true})
+			low := createScheduledRequest(5)
+			Expect(queue.push(low)).To(BeNil())
+			Expect(queue.pop(context.TODO())).To(Equal(low))
+			// the request is about to send its response
+			queue.startWait(low)
+			Expect(queue.endWait(low)).To(BeTrue())
+
+			Expect(queue.push(createScheduledRequest(0))).To(BeNil())
+			queue.finish(low)
+			running, waiting, _, _ := queue.status()
+			Expect(running).To(Equal(0))
+			Expect(waiting).To(Equal(1))
+		})
+
+		It("should return an aborted preempted request to the running requests", func() {
+			queue := newRequestQueue(&common.Configuration{MaxNumSeqs: 10, SchedulingPolicy: common.SchedulingPolicyPriority,
+				PriorityPreemption: true, MaxLoras: 1})
+			low := createLoraScheduledRequest("lora1")
+			low.priority = 5
+			Expect(queue.push(low)).To(BeNil())
+			Expect(queue.pop(context.TODO())).To(Equal(low))
+			queue.startWait(low)
+			Expect(queue.push(createScheduledRequest(0))).To(Equal(low))
+
+			Expect(queue.resumeAborted(low)).To(BeTrue())
+			running, waiting, runningLoras, _ := queue.status()
+			Expect(running).To(Equal(1))
+			Expect(waiting).To(Equal(1))
+			Expect(runningLoras).To(Equal([]string{"lora1"}))
+			// a running request is not resumed
+			Expect(queue.resumeAborted(low)).To(BeFalse())
+		})
+
+		It("should run at most max-loras LoRA adapters and charge their load latency", func() {
+			queue := newRequestQueue(&common.Configuration{MaxNumSeqs: 10, SchedulingPolicy: common.SchedulingPolicyFCFS,
+				MaxLoras: 1, MaxCPULoras: 2, LoraLoadLatency: 100, LoraDiskLoadLatency: 200,
+				LoraModules: []common.LoraModule{{Name: "lora1"}}})
+			lora1First := createLoraScheduledRequest("lora1")
+			lora2 := createLoraScheduledRequest("lora2")
+			lora1Second := createLoraScheduledRequest("lora1")
+			base := createScheduledRequest(0)
+			for _, req := range []*scheduledRequest{lora1First, lora2, lora1Second, base} {
+				Expect(queue.push(req)).To(BeNil())
+			}
+
+			// lora1 is loaded from the CPU memory
+			Expect(queue.pop(context.TODO())).To(Equal(lora1First))
+			Expect(lora1First.loraLoadLatency).To(Equal(100))
+			// lora2 waits since lora1 is running
+			Expect(queue.pop(context.TODO())).To(Equal(lora1Second))
+			Expect(lora1Second.loraLoadLatency).To(BeZero())
+			Expect(queue.pop(context.TODO())).To(Equal(base))
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			Expect(queue.pop(ctx)).To(BeNil())
+
+			queue.finish(lora1First)
+			queue.finish(lora1Second)
+			// lora2 is loaded from the disk
+			Expect(queue.pop(context.TODO())).To(Equal(lora2))
+			Expect(lora2.loraLoadLatency).To(Equal(300))
+			queue.finish(lora2)
+
+			// lora3 evicts lora1 from the CPU memory
+			lora3 := createLoraScheduledRequest("lora3")
+			Expect(queue.push(lora3)).To(BeNil())
+			Expect(queue.pop(context.TODO())).To(Equal(lora3))
+			Expect(lora3.loraLoadLatency).To(Equal(300))
+			queue.finish(lora3)
+
+			lora1Third := createLoraScheduledRequest("lora1")
+			Expect(queue.push(lora1Third)).To(BeNil())
+			Expect(queue.pop(context.TODO())).To(Equal(lora1Third))
+			Expect(lora1Third.loraLoadLatency).To(Equal(300))
+		})
+
+		It("should run at most max-num-seqs requests", func() {
+			config := &common.Configuration{MaxNumSeqs: 1, SchedulingPolicy: common.SchedulingPolicyFCFS}
+			queue := newRequestQueue(config)
+			first := createScheduledRequest(0)
+			second := createScheduledRequest(0)
+			Expect(queue.push(first)).To(BeNil())
+			Expect(queue.push(second)).To(BeNil())
+			Expect(queue.pop(context.TODO())).To(Equal(first))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			Expect(queue.pop(ctx)).To(BeNil())
+
+			// max-num-seqs is changed at runtime
+			config.MaxNumSeqs = 2
+			queue.setLimits(config)
+			Expect(queue.pop(context.TODO())).To(Equal(second))
+		})
+
+		It("should return nil when the context is cancelled", func() {
+			queue := newRequestQueue(&common.Configuration{MaxNumSeqs: 10, SchedulingPolicy: common.SchedulingPolicyFCFS})
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(queue.pop(ctx)).To(BeNil())
+		})
+	})
+
+	It("Should handle waiting requests by priority", func() {
+		ctx := context.TODO()
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--max-num-seqs", "1",
+			"--time-to-first-token", "500", "--scheduling-policy", "priority"}
+		client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		var mutex sync.Mutex
+		var order []int
+		var wg sync.WaitGroup
+		send := func(priority int) {
+			defer wg.Done()
+			defer GinkgoRecover()
+			resp, err := sendPriorityRequest(client, priority)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			mutex.Lock()
+			order = append(order, priority)
+			mutex.Unlock()
+		}
+
+		wg.Add(3)
+		// the first request occupies the only sequence, the other two wait
+		go send(0)
+		time.Sleep(100 * time.Millisecond)
+		go send(10)
+		time.Sleep(100 * time.Millisecond)
+		go send(1)
+		wg.Wait()
+
+		Expect(order).To(Equal([]int{0, 1, 10}))
+	})
+
+	It("Should preempt a lower priority running request", func() {
+		ctx := context.TODO()
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--max-num-seqs", "1",
+			"--time-to-first-token", "500", "--scheduling-policy", "priority", "--priority-preemption"}
+		client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		var lowPriorityEnd time.Time
+		var wg sync.WaitGroup
+		wg.Add(1)
+		start := time.Now()
+		go func() {
+			defer wg.Done()
+			defer GinkgoRecover()
+			resp, err := sendPriorityRequest(client, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			lowPriorityEnd = time.Now()
+		}()
+
+		time.Sleep(200 * time.Millisecond)
+		resp, err := sendPriorityRequest(client, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		highPriorityEnd := time.Now()
+		wg.Wait()
+
+		Expect(highPriorityEnd).To(BeTemporally("<", lowPriorityEnd))
+		// the preempted request waits for the high priority request and recomputes its prompt
+		Expect(lowPriorityEnd.Sub(start)).To(BeNumerically(">=", 1200*time.Millisecond))
+	})
+
+	It("Should wait for a running LoRA adapter when max-loras is reached", func() {
+		ctx := context.TODO()
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--time-to-first-token", "500",
+			"--max-loras", "1", "--lora-load-latency", "200",
+			"--lora-modules", "{\"name\":\"lora1\",\"path\":\"/path/to/lora1\"}",
+			"{\"name\":\"lora2\",\"path\":\"/path/to/lora2\"}"}
+		client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		send := func(lora string) {
+			reqBody := fmt.Sprintf(`{"prompt": "This is a test.", "model": "%s", "max_tokens": 2}`, lora)
+			resp, err := client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		}
+
+		var lora1End time.Time
+		var wg sync.WaitGroup
+		wg.Add(1)
+		start := time.Now()
+		go func() {
+			defer wg.Done()
+			defer GinkgoRecover()
+			send("lora1")
+			lora1End = time.Now()
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		send("lora2")
+		lora2End := time.Now()
+		wg.Wait()
+
+		// lora2 runs after lora1 has finished, both are loaded to the GPU
+		Expect(lora2End).To(BeTemporally(">", lora1End))
+		Expect(lora1End.Sub(start)).To(BeNumerically(">=", 700*time.Millisecond))
+		Expect(lora2End.Sub(start)).To(BeNumerically(">=", 1400*time.Millisecond))
+	})
+
+	It("Should reject priority when priority scheduling is not enabled", func() {
+		ctx := context.TODO()
+		client, err := startServer(ctx, common.ModeRandom)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err := sendPriorityRequest(client, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
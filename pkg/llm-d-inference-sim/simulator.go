@@ -22,10 +22,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/buaazp/fasthttprouter"
@@ -34,6 +37,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"k8s.io/klog/v2"
 
 	"github.com/llm-d/llm-d-inference-sim/pkg/common"
 	kvcache "github.com/llm-d/llm-d-inference-sim/pkg/kv-cache"
@@ -61,23 +65,81 @@ const (
 	waitingUsageState loraUsageState = iota
 	runningUsageState
 	doneUsageState
+	preemptedUsageState
+	abortedUsageState
 )
 
+// requestsUpdate is a change in the number of running or waiting requests of a base model
+type requestsUpdate struct {
+	// the base model's name
+	model string
+	// the change in the number of requests
+	inc int64
+}
+
 type loraUsage struct {
 	// the lora adapter name
 	name string
-	// state of the lora usage - waiting/running/done
+	// state of the lora usage - waiting/running/done/preempted
 	state loraUsageState
 }
 
+// responseChoice contains the generated content of a single choice in the response
+type responseChoice struct {
+	// tokens is the generated text, tokenized
+	tokens []string
+	// finishReason is the choice's finish reason
+	finishReason string
+	// prefix is the echoed prompt, returned before the generated text
+	prefix string
+	// logprobs are the log probabilities of the prefix's and the generated tokens, nil if not requested
+	logprobs *openaiserverapi.TextLogprobs
+}
+
+// text returns the choice's full text
+func (c *responseChoice) text() string {
+	return c.prefix + strings.Join(c.tokens, "")
+}
+
+// numOfPrefixTokens returns the number of the prefix's tokens in the log probabilities
+func (c *responseChoice) numOfPrefixTokens() int {
+	if c.logprobs == nil {
+		return 0
+	}
+	return len(c.logprobs.Tokens) - len(c.tokens)
+}
+
+// toBaseChoice creates the base response choice with the given index
+func (c *responseChoice) toBaseChoice(index int) openaiserverapi.BaseResponseChoice {
+	finishReason := c.finishReason
+	return openaiserverapi.BaseResponseChoice{Index: index, FinishReason: &finishReason}
+}
+
 // VllmSimulator simulates vLLM server supporting OpenAI API
 type VllmSimulator struct {
 	// logger is used for information and errors logging
 	logger logr.Logger
 	// config is the simulator's configuration
 	config *common.Configuration
-	// loraAdaptors contains list of LoRA available adaptors
+	// configMutex protects config, modelConfigs and baseModelNames, which are replaced when the configuration
+	// is changed with the admin API
+	configMutex sync.RWMutex
+	// adminMutex serializes the configuration changes of the admin API and of reloads
+	adminMutex sync.Mutex
+	// loraAdaptors contains list of LoRA available adaptors, the value is the name of the base model
+	// of an adapter that was resolved for an additional model, and an empty string otherwise
 	loraAdaptors sync.Map
+	// loraIDs contains the integer ids of the LoRA adapters, like vLLM's lora_int_id, the key
+	// is the LoRA's name
+	loraIDs sync.Map
+	// lastLoraID is the last id given to a LoRA adapter
+	lastLoraID atomic.Int64
+	// modelConfigs contains the configurations of LoRAs and served model names that override
+	// the global configuration, the key is the LoRA's or the served model name
+	modelConfigs map[string]*common.Configuration
+	// baseModelNames contains the names of the base models of the served model names and LoRAs
+	// of the additional models, the key is the served model name or the LoRA's name
+	baseModelNames map[string]string
 	// runningLoras is a collection of running loras,
 	// the key is lora's name, the value is the number of running requests using this lora
 	runningLoras sync.Map
@@ -86,14 +148,18 @@ type VllmSimulator struct {
 	waitingLoras sync.Map
 	// lorasChan is a channel to update waitingLoras and runningLoras
 	lorasChan chan loraUsage
-	// nRunningReqs is the number of inference requests that are currently being processed
-	nRunningReqs int64
+	// nRunningReqs is the number of inference requests that are currently being processed,
+	// the key is the base model's name
+	nRunningReqs map[string]int64
 	// runReqChan is a channel to update nRunningReqs
-	runReqChan chan int64
-	// nWaitingReqs is the number of inference requests that are waiting to be processed
-	nWaitingReqs int64
+	runReqChan chan requestsUpdate
+	// nWaitingReqs is the number of inference requests that are waiting to be processed,
+	// the key is the base model's name
+	nWaitingReqs map[string]int64
 	// waitingReqChan is a channel to update nWaitingReqs
-	waitingReqChan chan int64
+	waitingReqChan chan requestsUpdate
+	// registry is the prometheus registry of the simulator's metrics
+	registry *prometheus.Registry
 	// loraInfo is prometheus gauge
 	loraInfo *prometheus.GaugeVec
 	// runningRequests is prometheus gauge
@@ -102,8 +168,8 @@ type VllmSimulator struct {
 	waitingRequests *prometheus.GaugeVec
 	// kvCacheUsagePercentage is prometheus gauge
 	kvCacheUsagePercentage *prometheus.GaugeVec
-	// channel for requeasts to be passed to workers
-	reqChan chan *openaiserverapi.CompletionReqCtx
+	// queue of requests to be passed to workers
+	queue *requestQueue
 	// schema validator for tools parameters
 	toolsValidator *openaiserverapi.Validator
 	// kv cache functionality
@@ -112,6 +178,54 @@ type VllmSimulator struct {
 	namespace string
 	// pod name of simulator
 	pod string
+	// listener is the listener of the http server, if nil a listener on the configured port
+	// is created when the simulator starts
+	listener net.Listener
+	// stopMutex protects cancel, stopped and stopRequested
+	stopMutex sync.Mutex
+	// cancel stops a running simulator
+	cancel context.CancelFunc
+	// stopped is closed when a running simulator stops
+	stopped chan struct{}
+	// stopRequested is true if Stop was called before the simulator started running
+	stopRequested bool
+	// reload is signaled when a reload of the configuration is requested
+	reload chan struct{}
+	// workersMutex protects workersCtx and numOfWorkers
+	workersMutex sync.Mutex
+	// workersCtx is the context of the request processing workers, nil until the simulator runs
+	workersCtx context.Context
+	// numOfWorkers is the number of request processing workers
+	numOfWorkers int
+	// rateLimiter holds the state of the rate limits
+	rateLimiter *rateLimiter
+	// scenario plays back the scenario of timed phases
+	scenario *scenarioPlayer
+	// startTime is the time the HTTP server started, the simulator is ready after the startup delay
+	startTime time.Time
+	// draining is true when the simulator is shutting down, the simulator is not ready while draining
+	draining atomic.Bool
+	// sleepMutex serializes the transitions to and from sleep mode
+	sleepMutex sync.Mutex
+	// sleeping is true while the simulator is in sleep mode, including while it wakes up
+	sleeping atomic.Bool
+}
+
+// Option configures a simulator created by NewWithConfig
+type Option func(*VllmSimulator)
+
+// WithLogger sets the simulator's logger, the default is klog's logger
+func WithLogger(logger logr.Logger) Option {
+	return func(s *VllmSimulator) {
+		s.logger = logger
+	}
+}
+
+// WithListener sets the listener of the simulator's http server, the configured port is ignored
+func WithListener(listener net.Listener) Option {
+	return func(s *VllmSimulator) {
+		s.listener = listener
+	}
 }
 
 // New creates a new VllmSimulator instance with the given logger
@@ -123,28 +237,110 @@ func New(logger logr.Logger) (*VllmSimulator, error) {
 
 	return &VllmSimulator{
 		logger:         logger,
-		reqChan:        make(chan *openaiserverapi.CompletionReqCtx, maxNumberOfRequests),
 		toolsValidator: toolsValidator,
 		kvcacheHelper:  nil, // kvcache helper will be created only if required after reading configuration
 		namespace:      os.Getenv(podNsEnv),
 		pod:            os.Getenv(podNameEnv),
-		runReqChan:     make(chan int64, maxNumberOfRequests),
-		waitingReqChan: make(chan int64, maxNumberOfRequests),
+		nRunningReqs:   make(map[string]int64),
+		runReqChan:     make(chan requestsUpdate, maxNumberOfRequests),
+		nWaitingReqs:   make(map[string]int64),
+		waitingReqChan: make(chan requestsUpdate, maxNumberOfRequests),
 		lorasChan:      make(chan loraUsage, maxNumberOfRequests),
+		registry:       newPrometheusRegistry(),
+		reload:         make(chan struct{}, 1),
+		rateLimiter:    newRateLimiter(),
+		scenario:       newScenarioPlayer(logger),
 	}, nil
 }
 
-// Start starts the simulator
+// NewWithConfig creates a new VllmSimulator instance with the given configuration, without
+// parsing the command line. Unless a listener is set with WithListener, the simulator listens
+// on the configured port, a port of 0 selects a free port. The simulator runs when Start is
+// called and can be stopped with Stop. Fleet mode is not supported.
+func NewWithConfig(config *common.Configuration, opts ...Option) (*VllmSimulator, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.FleetSize > 1 {
+		return nil, errors.New("fleet mode is not supported by NewWithConfig")
+	}
+
+	s, err := New(klog.Background())
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.init(config); err != nil {
+		return nil, err
+	}
+	if s.listener == nil {
+		if s.listener, err = s.newListener(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Addr returns the address the simulator listens on, nil if the listener has not been created yet
+func (s *VllmSimulator) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop stops the simulator and waits until its http server is shut down
+func (s *VllmSimulator) Stop() {
+	s.stopMutex.Lock()
+	cancel, stopped := s.cancel, s.stopped
+	if cancel == nil {
+		// the simulator will not run if it is started later
+		s.stopRequested = true
+	}
+	s.stopMutex.Unlock()
+
+	if cancel == nil {
+		// the simulator is not running
+		if s.listener != nil {
+			_ = s.listener.Close()
+		}
+		return
+	}
+	cancel()
+	<-stopped
+}
+
+// Start starts the simulator, or a fleet of simulators if the fleet size is more than one.
+// If the simulator was created with NewWithConfig, the command line is not parsed.
+// Start blocks until the context is cancelled or Stop is called.
 func (s *VllmSimulator) Start(ctx context.Context) error {
+	if s.config != nil {
+		return s.run(ctx)
+	}
+
 	// parse command line parameters
 	config, err := common.ParseCommandParamsAndLoadConfig()
 	if err != nil {
 		return err
 	}
 
+	if config.FleetSize > 1 {
+		return startFleet(ctx, s.logger, config, s.reload)
+	}
+	if err := s.init(config); err != nil {
+		return err
+	}
+	go watchConfig(ctx, s.logger, config, s.reload, s.applyReloadedConfig)
+	return s.run(ctx)
+}
+
+// init initializes the simulator with the given configuration
+func (s *VllmSimulator) init(config *common.Configuration) error {
 	s.config = config
 
-	err = s.showConfig(s.logger)
+	err := s.showConfig(s.logger)
 	if err != nil {
 		return err
 	}
@@ -152,6 +348,8 @@ func (s *VllmSimulator) Start(ctx context.Context) error {
 	for _, lora := range config.LoraModules {
 		s.loraAdaptors.Store(lora.Name, "")
 	}
+	s.modelConfigs = config.ModelConfigs()
+	s.baseModelNames = config.BaseModelNames()
 
 	common.InitRandom(s.config.Seed)
 
@@ -166,24 +364,69 @@ func (s *VllmSimulator) Start(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+	}
 
-		go s.kvcacheHelper.Run(ctx)
+	s.queue = newRequestQueue(s.config)
+
+	if scenario := s.config.GetScenario(); scenario != nil && s.config.ScenarioStart == common.ScenarioStartStartup {
+		s.scenario.play(scenario)
 	}
+	return nil
+}
 
-	// run request processing workers
-	for i := 1; i <= s.config.MaxNumSeqs; i++ {
-		go s.reqProcessingWorker(ctx, i)
+// run runs the initialized simulator until the context is cancelled
+func (s *VllmSimulator) run(ctx context.Context) error {
+	s.stopMutex.Lock()
+	if s.cancel != nil {
+		s.stopMutex.Unlock()
+		return errors.New("the simulator is already running")
+	}
+	if s.stopRequested {
+		s.stopMutex.Unlock()
+		s.logger.Info("The simulator was stopped before it started")
+		return nil
 	}
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	s.cancel, s.stopped = cancel, stopped
+	s.stopMutex.Unlock()
+	defer func() {
+		cancel()
+		close(stopped)
+	}()
 
-	s.startMetricsUpdaters(ctx)
+	// the requests are processed until the HTTP server stops after draining them
+	processingCtx, stopProcessing := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopProcessing()
 
-	listener, err := s.newListener()
-	if err != nil {
-		return err
+	kvCacheStopped := make(chan struct{})
+	if s.kvcacheHelper != nil {
+		go func() {
+			s.kvcacheHelper.Run(processingCtx)
+			close(kvCacheStopped)
+		}()
+	} else {
+		close(kvCacheStopped)
+	}
+
+	s.startWorkers(processingCtx)
+
+	s.startMetricsUpdaters(processingCtx)
+
+	if s.listener == nil {
+		listener, err := s.newListener()
+		if err != nil {
+			return err
+		}
+		s.listener = listener
 	}
 
 	// start the http server with context support
-	return s.startServer(ctx, listener)
+	err := s.startServer(ctx, s.listener)
+	// wait for the pending KV events to be published
+	stopProcessing()
+	<-kvCacheStopped
+	return err
 }
 
 func (s *VllmSimulator) newListener() (net.Listener, error) {
@@ -202,23 +445,43 @@ func (s *VllmSimulator) startServer(ctx context.Context, listener net.Listener)
 	// support completion APIs
 	r.POST("/v1/chat/completions", s.HandleChatCompletions)
 	r.POST("/v1/completions", s.HandleTextCompletions)
+	r.POST("/v1/audio/transcriptions", s.HandleTranscriptions)
 	// supports /models API
 	r.GET("/v1/models", s.HandleModels)
 	// support load/unload of lora adapter
 	r.POST("/v1/load_lora_adapter", s.HandleLoadLora)
 	r.POST("/v1/unload_lora_adapter", s.HandleUnloadLora)
+	// supports the sleep mode and the prefix cache reset, if the sleep mode is enabled, like vLLM's
+	// development endpoints
+	if s.config.EnableSleepMode {
+		r.POST("/sleep", s.HandleSleep)
+		r.POST("/wake_up", s.HandleWakeUp)
+		r.GET("/is_sleeping", s.HandleIsSleeping)
+		r.POST("/reset_prefix_cache", s.HandleResetPrefixCache)
+	}
 	// supports /metrics prometheus API
-	r.GET("/metrics", fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler()))
+	r.GET("/metrics", fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})))
 	// supports standard Kubernetes health and readiness checks
 	r.GET("/health", s.HandleHealth)
 	r.GET("/ready", s.HandleReady)
+	// supports the admin API, if enabled
+	if s.config.AdminAPIKey != "" {
+		r.GET("/admin/config", s.HandleGetAdminConfig)
+		r.PATCH("/admin/config", s.HandlePatchAdminConfig)
+		r.GET("/admin/scenario", s.HandleGetScenario)
+		r.POST("/admin/scenario/start", s.HandleStartScenario)
+		r.POST("/admin/scenario/stop", s.HandleStopScenario)
+	}
 
 	server := fasthttp.Server{
 		ErrorHandler: s.HandleError,
-		Handler:      r.Handler,
+		Handler:      s.drainHandler(s.scenarioHandler(s.authenticate(r.Handler))),
 		Logger:       s,
 	}
 
+	s.startTime = time.Now()
+	s.draining.Store(false)
+
 	// Start server in a goroutine
 	serverErr := make(chan error, 1)
 	go func() {
@@ -230,9 +493,12 @@ func (s *VllmSimulator) startServer(ctx context.Context, listener net.Listener)
 	select {
 	case <-ctx.Done():
 		s.logger.Info("Shutdown signal received, shutting down HTTP server gracefully")
+		s.drain()
 
 		// Gracefully shutdown the server
-		if err := server.Shutdown(); err != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.ShutdownWithContext(shutdownCtx); err != nil {
 			s.logger.Error(err, "Error during server shutdown")
 			return err
 		}
@@ -278,6 +544,10 @@ func (s *VllmSimulator) readRequest(ctx *fasthttp.RequestCtx, isChatCompletion b
 				return nil, err
 			}
 		}
+		if err := req.CountMultimodalTokens(s.getConfig()); err != nil {
+			s.logger.Error(err, "failed to process multimodal content")
+			return nil, err
+		}
 		req.RequestID = requestID
 
 		return &req, nil
@@ -319,7 +589,26 @@ func (s *VllmSimulator) validateRequest(req openaiserverapi.CompletionRequest) (
 	}
 
 	if req.GetMaxCompletionTokens() != nil && *req.GetMaxCompletionTokens() <= 0 {
-		return "Max completion tokens and max tokens should be positive", fasthttp.StatusBadRequest
+		// zero max tokens is allowed when the prompt is echoed, for prompt scoring
+		if !req.IsEcho() || *req.GetMaxCompletionTokens() < 0 {
+			return "Max completion tokens and max tokens should be positive", fasthttp.StatusBadRequest
+		}
+	}
+
+	if req.GetBestOf() < 1 {
+		return "best_of must be at least 1", fasthttp.StatusBadRequest
+	}
+
+	if req.GetBestOf() > 1 && req.IsStream() {
+		return "best_of is not supported in streaming mode", fasthttp.StatusBadRequest
+	}
+
+	if req.GetLogprobs() != nil && (*req.GetLogprobs() < 0 || *req.GetLogprobs() > maxLogprobs) {
+		return fmt.Sprintf("logprobs must be between 0 and %d", maxLogprobs), fasthttp.StatusBadRequest
+	}
+
+	if req.GetPriority() != 0 && s.getConfig().SchedulingPolicy != common.SchedulingPolicyPriority {
+		return fmt.Sprintf("Got priority %d but priority scheduling is not enabled", req.GetPriority()), fasthttp.StatusBadRequest
 	}
 
 	if req.IsDoRemoteDecode() && req.IsStream() {
@@ -329,20 +618,32 @@ func (s *VllmSimulator) validateRequest(req openaiserverapi.CompletionRequest) (
 	return "", fasthttp.StatusOK
 }
 
-// isValidModel checks if the given model is the base model or one of "loaded" LoRAs
+// isValidModel checks if the given model is one of the base models or one of "loaded" LoRAs,
+// unknown LoRAs are resolved from the LoRA resolver directory if it is defined
 func (s *VllmSimulator) isValidModel(model string) bool {
-	for _, name := range s.config.ServedModelNames {
+	for _, name := range s.getConfig().ServedModelNames {
 		if model == name {
 			return true
 		}
 	}
+	if s.isBaseModel(model) {
+		return true
+	}
 	for _, lora := range s.getLoras() {
 		if model == lora {
 			return true
 		}
 	}
 
-	return false
+	return s.resolveLora(model)
+}
+
+// isBaseModel returns true if the given model name is a served model name of one of the base models
+func (s *VllmSimulator) isBaseModel(model string) bool {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+	_, ok := s.baseModelNames[model]
+	return ok
 }
 
 // isLora returns true if the given model name is one of loaded LoRAs
@@ -358,13 +659,6 @@ func (s *VllmSimulator) isLora(model string) bool {
 
 // handleCompletions general completion requests handler, support both text and chat completion APIs
 func (s *VllmSimulator) handleCompletions(ctx *fasthttp.RequestCtx, isChatCompletion bool) {
-	// Check if we should inject a failure
-	if shouldInjectFailure(s.config) {
-		failure := getRandomFailure(s.config)
-		s.sendCompletionError(ctx, failure, true)
-		return
-	}
-
 	vllmReq, err := s.readRequest(ctx, isChatCompletion)
 	if err != nil {
 		s.logger.Error(err, "failed to read and parse request body")
@@ -372,14 +666,56 @@ func (s *VllmSimulator) handleCompletions(ctx *fasthttp.RequestCtx, isChatComple
 		return
 	}
 
+	modelConfig := s.getModelConfig(vllmReq.GetModel())
+	// Check if the request's headers force a behavior
+	injection, err := s.getRequestInjection(ctx)
+	if err != nil {
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(err.Error(), fasthttp.StatusBadRequest, nil), false)
+		return
+	}
+	if injection != nil && injection.isRequestFailure() {
+		s.sendCompletionError(ctx, getFailure(modelConfig, injection.failure), true)
+		return
+	}
+	// Check if the current phase of the scenario fails the request or if we should inject a failure,
+	// these failures are not injected in requests with forced behavior
+	if injection == nil {
+		if failure := s.getScenarioFailure(modelConfig); failure != nil {
+			s.sendCompletionError(ctx, *failure, true)
+			return
+		}
+	}
+	if injection == nil && shouldInjectFailure(modelConfig) {
+		failure := getRandomFailure(modelConfig)
+		s.sendCompletionError(ctx, failure, true)
+		return
+	}
+
 	errMsg, errCode := s.validateRequest(vllmReq)
 	if errMsg != "" {
 		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(errMsg, errCode, nil), false)
 		return
 	}
 
+	// Validate context window constraints, each prompt is validated separately
+	promptTokens := vllmReq.GetMaxNumberOfPromptTokens()
+	completionTokens := vllmReq.GetMaxCompletionTokens()
+	isValid, actualCompletionTokens, totalTokens := common.ValidateContextWindow(promptTokens, completionTokens, modelConfig.MaxModelLen)
+	if !isValid {
+		message := fmt.Sprintf("This model's maximum context length is %d tokens. However, you requested %d tokens (%d in the messages, %d in the completion). Please reduce the length of the messages or completion",
+			modelConfig.MaxModelLen, totalTokens, promptTokens, actualCompletionTokens)
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(message, fasthttp.StatusBadRequest, nil), false)
+		return
+	}
+
+	if !s.checkRateLimits(ctx, vllmReq) {
+		return
+	}
+
+	// the blocks of the prompt are stored in the kv cache only if the request is not rejected
+	useKVCache := s.isKVCacheRequest(vllmReq, isChatCompletion)
 	defer func() {
-		if s.config.EnableKVCache && !isChatCompletion {
+		if useKVCache {
 			err := s.kvcacheHelper.OnRequestEnd(vllmReq)
 			if err != nil {
 				// TODO should it be an error with http response error or just a warning?
@@ -387,26 +723,19 @@ func (s *VllmSimulator) handleCompletions(ctx *fasthttp.RequestCtx, isChatComple
 			}
 		}
 	}()
-	if s.config.EnableKVCache && !isChatCompletion {
-		// kv cache is currently supported for /completion API only
-		err = s.kvcacheHelper.OnRequestStart(vllmReq)
+	if useKVCache {
+		var loraID *int
+		if s.isLora(vllmReq.GetModel()) {
+			id := s.getLoraID(vllmReq.GetModel())
+			loraID = &id
+		}
+		err = s.kvcacheHelper.OnRequestStart(vllmReq, loraID)
 		if err != nil {
 			// TODO should it be an error with http response error or just a warning?
 			s.logger.Error(err, "kv cache failed to process request start")
 		}
 	}
 
-	// Validate context window constraints
-	promptTokens := vllmReq.GetNumberOfPromptTokens()
-	completionTokens := vllmReq.GetMaxCompletionTokens()
-	isValid, actualCompletionTokens, totalTokens := common.ValidateContextWindow(promptTokens, completionTokens, s.config.MaxModelLen)
-	if !isValid {
-		message := fmt.Sprintf("This model's maximum context length is %d tokens. However, you requested %d tokens (%d in the messages, %d in the completion). Please reduce the length of the messages or completion",
-			s.config.MaxModelLen, totalTokens, promptTokens, actualCompletionTokens)
-		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(message, fasthttp.StatusBadRequest, nil), false)
-		return
-	}
-
 	var wg sync.WaitGroup
 	wg.Add(1)
 	reqCtx := &openaiserverapi.CompletionReqCtx{
@@ -415,114 +744,270 @@ func (s *VllmSimulator) handleCompletions(ctx *fasthttp.RequestCtx, isChatComple
 		IsChatCompletion: isChatCompletion,
 		Wg:               &wg,
 	}
-	// increment the waiting requests metric
-	s.waitingReqChan <- 1
+	lora := ""
 	if s.isLora(reqCtx.CompletionReq.GetModel()) {
-		// update loraInfo metrics with the new waiting request
-		s.lorasChan <- loraUsage{reqCtx.CompletionReq.GetModel(), waitingUsageState}
+		lora = reqCtx.CompletionReq.GetModel()
 	}
-	// send the request to the waiting queue (channel)
-	s.reqChan <- reqCtx
+	request := newScheduledRequest(reqCtx, lora)
+	request.injection = injection
+	s.enqueueRequest(request)
 	wg.Wait()
 }
 
+// isKVCacheRequest returns true if the blocks of the request's prompt are stored in the kv cache, the kv cache
+// is supported for the /completion API and for chat completions with image, audio or video content
+func (s *VllmSimulator) isKVCacheRequest(req openaiserverapi.CompletionRequest, isChatCompletion bool) bool {
+	if !s.getConfig().EnableKVCache {
+		return false
+	}
+	if !isChatCompletion {
+		return true
+	}
+	for _, segment := range req.GetPromptSegments() {
+		if segment.NumOfPlaceholders > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueRequest sends the request to the waiting queue and updates the waiting requests metrics
+func (s *VllmSimulator) enqueueRequest(request *scheduledRequest) {
+	// increment the waiting requests metric
+	s.waitingReqChan <- requestsUpdate{s.getBaseModelName(request.model()), 1}
+	if request.lora != "" {
+		// update loraInfo metrics with the new waiting request
+		s.lorasChan <- loraUsage{request.lora, waitingUsageState}
+	}
+	// send the request to the waiting queue
+	if preempted := s.queue.push(request); preempted != nil {
+		// the preempted request returns to the waiting queue
+		preemptedBaseModel := s.getBaseModelName(preempted.model())
+		s.runReqChan <- requestsUpdate{preemptedBaseModel, -1}
+		s.waitingReqChan <- requestsUpdate{preemptedBaseModel, 1}
+		if preempted.lora != "" {
+			s.lorasChan <- loraUsage{preempted.lora, preemptedUsageState}
+		}
+	}
+}
+
+// startWorkers runs max-num-seqs request processing workers with the given context
+func (s *VllmSimulator) startWorkers(ctx context.Context) {
+	s.workersMutex.Lock()
+	s.workersCtx = ctx
+	s.workersMutex.Unlock()
+	s.addWorkers(s.getConfig().MaxNumSeqs)
+}
+
+// addWorkers runs more request processing workers until there are at least the given number of workers,
+// the workers are not stopped when max-num-seqs decreases, the queue limits the number of running requests
+func (s *VllmSimulator) addWorkers(numOfWorkers int) {
+	s.workersMutex.Lock()
+	defer s.workersMutex.Unlock()
+	if s.workersCtx == nil {
+		// the simulator is not running yet
+		return
+	}
+	for s.numOfWorkers < numOfWorkers {
+		s.numOfWorkers++
+		go s.reqProcessingWorker(s.workersCtx, s.numOfWorkers)
+	}
+}
+
 func (s *VllmSimulator) reqProcessingWorker(ctx context.Context, id int) {
 	for {
+		request := s.queue.pop(ctx)
+		if request == nil {
+			s.logger.Info("reqProcessingWorker stopped:", "worker id", id)
+			return
+		}
+		s.reportRequestRunning(request.model())
+
+		if request.started {
+			// the request was preempted, continue its processing
+			notify(request.resume)
+		} else {
+			request.started = true
+			if request.transcription != nil {
+				go s.processTranscription(request)
+			} else {
+				go s.processRequest(request)
+			}
+		}
+
+		// the worker is busy until the request is finished or preempted
 		select {
 		case <-ctx.Done():
 			s.logger.Info("reqProcessingWorker stopped:", "worker id", id)
 			return
-		case reqCtx, ok := <-s.reqChan:
-			if !ok {
-				s.logger.Info("reqProcessingWorker worker exiting: reqChan closed")
-				return
-			}
+		case <-request.done:
+		case <-request.preemptWorker:
+		}
+	}
+}
 
-			req := reqCtx.CompletionReq
-			model := req.GetModel()
-			displayModel := s.getDisplayedModelName(model)
+// processRequest generates the response for the given request and sends it
+func (s *VllmSimulator) processRequest(request *scheduledRequest) {
+	reqCtx := request.reqCtx
+	req := reqCtx.CompletionReq
+	displayModel := s.getDisplayedModelName(req.GetModel())
 
-			// decriment waiting and increment running requests count
-			s.waitingReqChan <- -1
-			s.runReqChan <- 1
+	if request.loraLoadLatency > 0 {
+		s.logger.Info("Loading LoRA adapter", "lora", request.lora, "latency", request.loraLoadLatency)
+		s.wait(request, request.loraLoadLatency, false)
+	}
 
-			if s.isLora(model) {
-				// update loraInfo metric to reflect that
-				// the request has changed its status from waiting to running
-				s.lorasChan <- loraUsage{model, runningUsageState}
-			}
+	modelConfig := s.getModelConfig(req.GetModel())
+	if request.injection == nil {
+		request.latencyFault = getLatencyFault(modelConfig)
+	} else if request.injection.isLatencyFault() {
+		request.latencyFault = &latencyFault{faultType: request.injection.failure, config: modelConfig}
+	}
+	if request.latencyFault != nil {
+		s.logger.Info("Injecting latency fault", "type", request.latencyFault.faultType, "id", req.GetRequestID())
+		if request.latencyFault.faultType == latencyFaultHang {
+			s.hang(request, displayModel)
+			reqCtx.Wg.Done()
+			return
+		}
+	}
 
-			var responseTokens []string
-			var finishReason string
-			var err error
-			var toolCalls []openaiserverapi.ToolCall
-			var completionTokens int
-			if reqCtx.IsChatCompletion &&
-				req.GetToolChoice() != openaiserverapi.ToolChoiceNone &&
-				req.GetTools() != nil {
-				toolCalls, finishReason, completionTokens, err =
-					openaiserverapi.CreateToolCalls(req.GetTools(), req.GetToolChoice(), s.config)
+	var choices []responseChoice
+	var err error
+	var toolCalls []openaiserverapi.ToolCall
+	var completionTokens int
+	if reqCtx.IsChatCompletion &&
+		req.GetToolChoice() != openaiserverapi.ToolChoiceNone &&
+		req.GetTools() != nil {
+		var finishReason string
+		toolCalls, finishReason, completionTokens, err =
+			openaiserverapi.CreateToolCalls(req.GetTools(), req.GetToolChoice(), s.getConfig())
+		choices = []responseChoice{{finishReason: finishReason}}
+	}
+	if toolCalls == nil && err == nil {
+		// Either no tool calls were defined, or we randomly chose not to create tool calls,
+		// so we generate a response text, one choice for each prompt
+		choices, completionTokens, err = s.createResponseChoices(req, request.injection)
+	}
+	if err != nil {
+		prefix := ""
+		if reqCtx.IsChatCompletion {
+			prefix = "failed to create chat response"
+		} else {
+			prefix = "failed to create text response"
+		}
+		s.logger.Error(err, prefix)
+		reqCtx.HTTPReqCtx.Error(prefix+err.Error(), fasthttp.StatusBadRequest)
+		s.responseSentCallback(displayModel)
+		s.queue.finish(request)
+	} else {
+		usageData := openaiserverapi.Usage{
+			PromptTokens:     req.GetNumberOfPromptTokens(),
+			CompletionTokens: completionTokens,
+			TotalTokens:      req.GetNumberOfPromptTokens() + completionTokens,
+		}
+		if req.IsStream() {
+			var usageDataToSend *openaiserverapi.Usage
+			if req.IncludeUsage() {
+				usageDataToSend = &usageData
 			}
-			if toolCalls == nil && err == nil {
-				// Either no tool calls were defined, or we randomly chose not to create tool calls,
-				// so we generate a response text.
-				responseTokens, finishReason, completionTokens, err = req.CreateResponseText(s.config.Mode)
+			s.sendStreamingResponse(
+				&streamingContext{
+					ctx:              reqCtx.HTTPReqCtx,
+					isChatCompletion: reqCtx.IsChatCompletion,
+					model:            displayModel,
+					doRemotePrefill:  req.IsDoRemotePrefill(),
+					request:          request,
+				},
+				choices, toolCalls, usageDataToSend,
+			)
+		} else {
+			if req.IsDoRemoteDecode() {
+				// in case this is prefill pod processing, return special finish reason
+				for i := range choices {
+					choices[i].finishReason = common.RemoteDecodeFinishReason
+				}
 			}
+
+			s.sendResponse(reqCtx.IsChatCompletion,
+				reqCtx.HTTPReqCtx,
+				request,
+				choices,
+				toolCalls,
+				displayModel,
+				&usageData,
+				req.IsDoRemoteDecode(),
+				req.IsDoRemotePrefill())
+		}
+	}
+	reqCtx.Wg.Done()
+}
+
+// createResponseChoices generates the response text for each prompt of the given request,
+// with the number of tokens forced by the request's injection if set,
+// returns the choices and the total number of generated tokens
+func (s *VllmSimulator) createResponseChoices(req openaiserverapi.CompletionRequest,
+	injection *requestInjection) ([]responseChoice, int, error) {
+	choices := make([]responseChoice, req.GetNumberOfPrompts())
+	totalTokens := 0
+	for i := range choices {
+		prefix := ""
+		if req.IsEcho() {
+			prefix = req.GetPromptText(i)
+		}
+		// generate best-of completions, the one with the highest log probability per token is returned,
+		// all of them are counted in the usage
+		bestScore := math.Inf(-1)
+		for range req.GetBestOf() {
+			tokens, finishReason, numOfTokens, err := req.CreateResponseText(s.getModelConfig(req.GetModel()).Mode, i)
 			if err != nil {
-				prefix := ""
-				if reqCtx.IsChatCompletion {
-					prefix = "failed to create chat response"
-				} else {
-					prefix = "failed to create text response"
-				}
-				s.logger.Error(err, prefix)
-				reqCtx.HTTPReqCtx.Error(prefix+err.Error(), fasthttp.StatusBadRequest)
-			} else {
-				usageData := openaiserverapi.Usage{
-					PromptTokens:     req.GetNumberOfPromptTokens(),
-					CompletionTokens: completionTokens,
-					TotalTokens:      req.GetNumberOfPromptTokens() + completionTokens,
-				}
-				if req.IsStream() {
-					var usageDataToSend *openaiserverapi.Usage
-					if req.IncludeUsage() {
-						usageDataToSend = &usageData
-					}
-					s.sendStreamingResponse(
-						&streamingContext{
-							ctx:              reqCtx.HTTPReqCtx,
-							isChatCompletion: reqCtx.IsChatCompletion,
-							model:            displayModel,
-							doRemotePrefill:  req.IsDoRemotePrefill(),
-						},
-						responseTokens, toolCalls, finishReason, usageDataToSend,
-					)
-				} else {
-					if req.IsDoRemoteDecode() {
-						// in case this is prefill pod processing, return special finish reason
-						finishReason = common.RemoteDecodeFinishReason
-					}
-
-					s.sendResponse(reqCtx.IsChatCompletion,
-						reqCtx.HTTPReqCtx,
-						responseTokens,
-						toolCalls,
-						displayModel,
-						finishReason,
-						&usageData,
-						req.IsDoRemoteDecode(),
-						req.IsDoRemotePrefill())
-				}
+				return nil, 0, err
 			}
-			reqCtx.Wg.Done()
+			if injection != nil && injection.outputTokens != nil {
+				tokens, finishReason, numOfTokens = forcedResponseTokens(*injection.outputTokens), common.StopFinishReason,
+					*injection.outputTokens
+			}
+			totalTokens += numOfTokens
+
+			choice := responseChoice{tokens: tokens, finishReason: finishReason, prefix: prefix}
+			if req.GetLogprobs() == nil && req.GetBestOf() == 1 {
+				choices[i] = choice
+				break
+			}
+			topLogprobs := 0
+			if req.GetLogprobs() != nil {
+				topLogprobs = *req.GetLogprobs()
+			}
+			choice.logprobs = createChoiceLogprobs(prefix, tokens, topLogprobs)
+			if score := averageLogprob(choice.logprobs, len(tokens)); score > bestScore {
+				bestScore = score
+				choices[i] = choice
+			}
+		}
+		if req.GetLogprobs() == nil {
+			choices[i].logprobs = nil
 		}
 	}
+	return choices, totalTokens, nil
+}
+
+// reportRequestRunning updates the metrics of a request that has changed its status from waiting to running
+func (s *VllmSimulator) reportRequestRunning(model string) {
+	// decriment waiting and increment running requests count
+	s.waitingReqChan <- requestsUpdate{s.getBaseModelName(model), -1}
+	s.runReqChan <- requestsUpdate{s.getBaseModelName(model), 1}
+
+	if s.isLora(model) {
+		// update loraInfo metric to reflect that
+		// the request has changed its status from waiting to running
+		s.lorasChan <- loraUsage{model, runningUsageState}
+	}
 }
 
 // decrease model usage reference number
 func (s *VllmSimulator) responseSentCallback(model string) {
 	// decriment running requests count
-	s.runReqChan <- -1
+	s.runReqChan <- requestsUpdate{s.getBaseModelName(model), -1}
 
 	if s.isLora(model) {
 		// update loraInfo metrics to reflect that the request processing has been finished
@@ -576,14 +1061,13 @@ func (s *VllmSimulator) HandleError(_ *fasthttp.RequestCtx, err error) {
 
 // createCompletionResponse creates the response for completion requests, supports both completion request types (text and chat)
 // as defined by isChatCompletion
-// respTokens - tokenized content to be sent in the response
+// choices - generated content and finish reason of each choice to be sent in the response
 // toolCalls - tool calls to be sent in the response
-// finishReason - a pointer to string that represents finish reason, can be nil or stop or length, ...
 // usageData - usage (tokens statistics) for this response
 // modelName - display name returned to the client and used in metrics. It is either the first alias
 // from --served-model-name (for a base-model request) or the LoRA adapter name (for a LoRA request).
-func (s *VllmSimulator) createCompletionResponse(isChatCompletion bool, respTokens []string, toolCalls []openaiserverapi.ToolCall,
-	finishReason *string, usageData *openaiserverapi.Usage, modelName string, doRemoteDecode bool) openaiserverapi.CompletionResponse {
+func (s *VllmSimulator) createCompletionResponse(isChatCompletion bool, choices []responseChoice, toolCalls []openaiserverapi.ToolCall,
+	usageData *openaiserverapi.Usage, modelName string, doRemoteDecode bool) openaiserverapi.CompletionResponse {
 	baseResp := openaiserverapi.BaseCompletionResponse{
 		ID:      chatComplIDPrefix + common.GenerateUUIDString(),
 		Created: time.Now().Unix(),
@@ -602,42 +1086,50 @@ func (s *VllmSimulator) createCompletionResponse(isChatCompletion bool, respToke
 		baseResp.RemotePort = 1234
 	}
 
-	baseChoice := openaiserverapi.BaseResponseChoice{Index: 0, FinishReason: finishReason}
-
-	respText := strings.Join(respTokens, "")
 	if isChatCompletion {
 		baseResp.Object = chatCompletionObject
 
-		message := openaiserverapi.Message{Role: openaiserverapi.RoleAssistant}
-		if toolCalls != nil {
-			message.ToolCalls = toolCalls
-		} else {
-			message.Content = openaiserverapi.Content{Raw: respText}
+		respChoices := make([]openaiserverapi.ChatRespChoice, len(choices))
+		for i, choice := range choices {
+			message := openaiserverapi.Message{Role: openaiserverapi.RoleAssistant}
+			if toolCalls != nil {
+				message.ToolCalls = toolCalls
+			} else {
+				message.Content = openaiserverapi.Content{Raw: strings.Join(choice.tokens, "")}
+			}
+			respChoices[i] = openaiserverapi.ChatRespChoice{Message: message, BaseResponseChoice: choice.toBaseChoice(i)}
 		}
 		return &openaiserverapi.ChatCompletionResponse{
 			BaseCompletionResponse: baseResp,
-			Choices:                []openaiserverapi.ChatRespChoice{{Message: message, BaseResponseChoice: baseChoice}},
+			Choices:                respChoices,
 		}
 	}
 
 	baseResp.Object = textCompletionObject
+	respChoices := make([]openaiserverapi.TextRespChoice, len(choices))
+	for i, choice := range choices {
+		respChoices[i] = openaiserverapi.TextRespChoice{BaseResponseChoice: choice.toBaseChoice(i), Text: choice.text(),
+			Logprobs: choice.logprobs}
+	}
 	return &openaiserverapi.TextCompletionResponse{
 		BaseCompletionResponse: baseResp,
-		Choices:                []openaiserverapi.TextRespChoice{{BaseResponseChoice: baseChoice, Text: respText}},
+		Choices:                respChoices,
 	}
 }
 
 // sendResponse sends response for completion API, supports both completions (text and chat)
 // according the value of isChatCompletion
-// respTokens - tokenized content to be sent in the response
+// request - the scheduled request, its processing is finished after the response is sent
+// choices - generated content and finish reason of each choice to be sent in the response
 // toolCalls - tool calls to be sent in the response
 // modelName - display name returned to the client and used in metrics. It is either the first alias
 // from --served-model-name (for a base-model request) or the LoRA adapter name (for a LoRA request).
-// finishReason - a pointer to string that represents finish reason, can be nil, stop, length, or tools
 // usageData - usage (tokens statistics) for this response
-func (s *VllmSimulator) sendResponse(isChatCompletion bool, ctx *fasthttp.RequestCtx, respTokens []string, toolCalls []openaiserverapi.ToolCall,
-	modelName string, finishReason string, usageData *openaiserverapi.Usage, doRemoteDecode bool, doRemotePrefill bool) {
-	resp := s.createCompletionResponse(isChatCompletion, respTokens, toolCalls, &finishReason, usageData, modelName, doRemoteDecode)
+func (s *VllmSimulator) sendResponse(isChatCompletion bool, ctx *fasthttp.RequestCtx, request *scheduledRequest, choices []responseChoice,
+	toolCalls []openaiserverapi.ToolCall, modelName string, usageData *openaiserverapi.Usage, doRemoteDecode bool, doRemotePrefill bool) {
+	defer s.queue.finish(request)
+
+	resp := s.createCompletionResponse(isChatCompletion, choices, toolCalls, usageData, modelName, doRemoteDecode)
 
 	data, err := json.Marshal(resp)
 	if err != nil {
@@ -645,10 +1137,18 @@ func (s *VllmSimulator) sendResponse(isChatCompletion bool, ctx *fasthttp.Reques
 		return
 	}
 
-	// calculate how long to wait before returning the response, time is based on number of tokens
+	// calculate how long to wait before returning the response, time is based on number of tokens,
+	// choices are generated in parallel, so the longest one defines the time
 	numOfTokens := usageData.CompletionTokens
-	totalMillisToWait := s.getTimeToFirstToken(doRemotePrefill) + s.getTotalInterTokenLatency(numOfTokens)
-	time.Sleep(time.Duration(totalMillisToWait) * time.Millisecond)
+	if toolCalls == nil {
+		numOfTokens = 0
+		for _, choice := range choices {
+			numOfTokens = max(numOfTokens, len(choice.tokens))
+		}
+	}
+	totalMillisToWait := s.getRequestTimeToFirstToken(request, doRemotePrefill) +
+		s.getTotalInterTokenLatency(request, numOfTokens)
+	s.wait(request, totalMillisToWait, doRemotePrefill)
 
 	ctx.Response.Header.SetContentType("application/json")
 	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
@@ -664,39 +1164,102 @@ func (s *VllmSimulator) sendResponse(isChatCompletion bool, ctx *fasthttp.Reques
 	s.responseSentCallback(modelName)
 }
 
-// returns time to first token based on the current request's doRemotePrefill
-func (s *VllmSimulator) getTimeToFirstToken(doRemotePrefill bool) int {
-	mean := float64(s.config.TimeToFirstToken)
-	stddev := float64(s.config.TimeToFirstTokenStdDev)
+// returns time to first token of the given model based on the current request's doRemotePrefill
+func (s *VllmSimulator) getTimeToFirstToken(model string, doRemotePrefill bool) int {
+	config := s.getModelConfig(model)
+	mean := float64(config.TimeToFirstToken)
+	stddev := float64(config.TimeToFirstTokenStdDev)
 	if doRemotePrefill {
-		mean = float64(s.config.KVCacheTransferLatency)
-		stddev = float64(s.config.KVCacheTransferLatencyStdDev)
+		mean = float64(config.KVCacheTransferLatency)
+		stddev = float64(config.KVCacheTransferLatencyStdDev)
 	}
 	return int(common.RandomNorm(mean, stddev))
 }
 
-// returns inter token latency
-func (s *VllmSimulator) getInterTokenLatency() int {
-	mean := float64(s.config.InterTokenLatency)
-	stddev := float64(s.config.InterTokenLatencyStdDev)
+// returns inter token latency of the given model
+func (s *VllmSimulator) getInterTokenLatency(model string) int {
+	config := s.getModelConfig(model)
+	mean := float64(config.InterTokenLatency)
+	stddev := float64(config.InterTokenLatencyStdDev)
 	return int(common.RandomNorm(mean, stddev))
 }
 
-// returns total inter token latency for the given number of tokens
-func (s *VllmSimulator) getTotalInterTokenLatency(numOfTokens int) int {
+// returns total inter token latency of the given request for the given number of tokens
+func (s *VllmSimulator) getTotalInterTokenLatency(request *scheduledRequest, numOfTokens int) int {
 	total := 0
 	for range numOfTokens - 1 {
-		total += s.getInterTokenLatency()
+		total += s.getRequestInterTokenLatency(request)
 	}
 	return total
 }
 
+// getRequestTimeToFirstToken returns the time to first token of the given request, including its latency fault,
+// or the time forced by the request's injection
+func (s *VllmSimulator) getRequestTimeToFirstToken(request *scheduledRequest, doRemotePrefill bool) int {
+	if request.injection != nil && request.injection.timeToFirstToken != nil {
+		return *request.injection.timeToFirstToken
+	}
+	ttft := s.getTimeToFirstToken(request.model(), doRemotePrefill)
+	if scenarioTTFT, _ := s.getScenarioLatencies(); scenarioTTFT != nil {
+		ttft = *scenarioTTFT
+	}
+	if request.latencyFault != nil {
+		return request.latencyFault.timeToFirstToken(ttft)
+	}
+	return ttft
+}
+
+// getRequestInterTokenLatency returns the latency of the next token of the given request, including its latency fault
+func (s *VllmSimulator) getRequestInterTokenLatency(request *scheduledRequest) int {
+	itl := s.getInterTokenLatency(request.model())
+	if _, scenarioITL := s.getScenarioLatencies(); scenarioITL != nil {
+		itl = *scenarioITL
+	}
+	if request.latencyFault != nil {
+		return request.latencyFault.interTokenLatency(itl)
+	}
+	return itl
+}
+
+// hang keeps the given request without a response for the hang time, and then closes its connection
+func (s *VllmSimulator) hang(request *scheduledRequest, model string) {
+	defer s.queue.finish(request)
+	s.wait(request, request.latencyFault.config.HangTime, false)
+	if err := request.reqCtx.HTTPReqCtx.Conn().Close(); err != nil {
+		s.logger.Error(err, "failed to close connection of hanging request")
+	}
+	s.responseSentCallback(model)
+}
+
+// getModelConfig returns the configuration of the given model, the global configuration with the
+// overrides of the LoRA or the served model name, if defined
+func (s *VllmSimulator) getModelConfig(model string) *common.Configuration {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+	if config, ok := s.modelConfigs[model]; ok {
+		return config
+	}
+	return s.config
+}
+
+// getConfig returns the simulator's current configuration
+func (s *VllmSimulator) getConfig() *common.Configuration {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+	return s.config
+}
+
 // createModelsResponse creates and returns ModelResponse for the current state, returned array of models contains the base model + LoRA adapters if exist
 func (s *VllmSimulator) createModelsResponse() *vllmapi.ModelsResponse {
 	modelsResp := vllmapi.ModelsResponse{Object: "list", Data: []vllmapi.ModelsResponseModelInfo{}}
 
-	// Advertise every public model alias
-	for _, alias := range s.config.ServedModelNames {
+	// Advertise every public model alias of all the base models
+	config := s.getConfig()
+	aliases := slices.Clone(config.ServedModelNames)
+	for _, model := range config.AdditionalModels {
+		aliases = append(aliases, model.ServedModelNames...)
+	}
+	for _, alias := range aliases {
 		modelsResp.Data = append(modelsResp.Data, vllmapi.ModelsResponseModelInfo{
 			ID:      alias,
 			Object:  vllmapi.ObjectModel,
@@ -708,8 +1271,8 @@ func (s *VllmSimulator) createModelsResponse() *vllmapi.ModelsResponse {
 	}
 
 	// add LoRA adapter's info
-	parent := s.config.ServedModelNames[0]
 	for _, lora := range s.getLoras() {
+		parent := s.getBaseModelName(lora)
 		modelsResp.Data = append(modelsResp.Data, vllmapi.ModelsResponseModelInfo{
 			ID:      lora,
 			Object:  vllmapi.ObjectModel,
@@ -723,22 +1286,6 @@ func (s *VllmSimulator) createModelsResponse() *vllmapi.ModelsResponse {
 	return &modelsResp
 }
 
-// HandleHealth http handler for /health
-func (s *VllmSimulator) HandleHealth(ctx *fasthttp.RequestCtx) {
-	s.logger.V(4).Info("health request received")
-	ctx.Response.Header.SetContentType("application/json")
-	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
-	ctx.Response.SetBody([]byte("{}"))
-}
-
-// HandleReady http handler for /ready
-func (s *VllmSimulator) HandleReady(ctx *fasthttp.RequestCtx) {
-	s.logger.V(4).Info("readiness request received")
-	ctx.Response.Header.SetContentType("application/json")
-	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
-	ctx.Response.SetBody([]byte("{}"))
-}
-
 // getDisplayedModelName returns the model name that must appear in API
 // responses.  LoRA adapters keep their explicit name, while all base-model
 // requests are surfaced as the first alias from --served-model-name.
@@ -746,23 +1293,48 @@ func (s *VllmSimulator) getDisplayedModelName(reqModel string) string {
 	if s.isLora(reqModel) {
 		return reqModel
 	}
-	return s.config.ServedModelNames[0]
+	return s.getBaseModelName(reqModel)
+}
+
+// getBaseModelName returns the name of the base model of the given served model name or LoRA,
+// which is the first served model name of the base model
+func (s *VllmSimulator) getBaseModelName(model string) string {
+	s.configMutex.RLock()
+	name, ok := s.baseModelNames[model]
+	s.configMutex.RUnlock()
+	if ok {
+		return name
+	}
+	if name, ok := s.loraAdaptors.Load(model); ok && name != "" {
+		return name.(string)
+	}
+	return s.getConfig().ServedModelNames[0]
 }
 
 func (s *VllmSimulator) showConfig(tgtLgr logr.Logger) error {
 	if tgtLgr == logr.Discard() {
 		return errors.New("target logger is nil, cannot show configuration")
 	}
-	cfgJSON, err := json.Marshal(s.config)
+	cfgJSON, err := configToJSON(s.config)
 	if err != nil {
-		return fmt.Errorf("failed to marshal configuration to JSON: %w", err)
+		return err
+	}
+	tgtLgr.Info("Configuration:", "", string(cfgJSON))
+	return nil
+}
+
+// configToJSON returns the given configuration as indented JSON, without the internal fields
+func configToJSON(config *common.Configuration) ([]byte, error) {
+	cfgJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal configuration to JSON: %w", err)
 	}
 
 	// clean LoraModulesString field
 	var m map[string]interface{}
 	err = json.Unmarshal(cfgJSON, &m)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal JSON to map: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal JSON to map: %w", err)
 	}
 	m["lora-modules"] = m["LoraModules"]
 	delete(m, "LoraModules")
@@ -773,11 +1345,9 @@ func (s *VllmSimulator) showConfig(tgtLgr logr.Logger) error {
 		delete(field, "LorasString")
 	}
 
-	// show in JSON
 	cfgJSON, err = json.MarshalIndent(m, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal configuration to JSON: %w", err)
+		return nil, fmt.Errorf("failed to marshal configuration to JSON: %w", err)
 	}
-	tgtLgr.Info("Configuration:", "", string(cfgJSON))
-	return nil
+	return cfgJSON, nil
 }
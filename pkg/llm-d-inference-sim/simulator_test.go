@@ -17,9 +17,14 @@ limitations under the License.
 package llmdinferencesim
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"net"
 	"net/http"
@@ -28,6 +33,9 @@ import (
 	"time"
 
 	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	kvcache "github.com/llm-d/llm-d-inference-sim/pkg/kv-cache"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+	vllmapi "github.com/llm-d/llm-d-inference-sim/pkg/vllm-api"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/openai/openai-go"
@@ -95,6 +103,8 @@ func startServerWithArgsAndMetrics(ctx context.Context, mode string, args []stri
 	for _, lora := range config.LoraModules {
 		s.loraAdaptors.Store(lora.Name, "")
 	}
+	s.modelConfigs = config.ModelConfigs()
+	s.baseModelNames = config.BaseModelNames()
 
 	common.InitRandom(s.config.Seed)
 
@@ -105,21 +115,39 @@ func startServerWithArgsAndMetrics(ctx context.Context, mode string, args []stri
 		}
 	}
 
+	if config.EnableKVCache {
+		s.kvcacheHelper, err = kvcache.NewKVCacheHelper(s.config, s.logger)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// calculate number of tokens for user message,
 	// must be activated after parseCommandParamsAndLoadConfig since it initializes the random engine
 	userMsgTokens = int64(len(common.Tokenize(userMessage)))
 
-	// run request processing workers
-	for i := 1; i <= s.config.MaxNumSeqs; i++ {
-		go s.reqProcessingWorker(ctx, i)
+	if scenario := config.GetScenario(); scenario != nil && config.ScenarioStart == common.ScenarioStartStartup {
+		s.scenario.play(scenario)
 	}
 
-	s.startMetricsUpdaters(ctx)
+	// the requests are processed until the HTTP server stops after draining them
+	processingCtx, stopProcessing := context.WithCancel(context.WithoutCancel(ctx))
+
+	if s.kvcacheHelper != nil {
+		go s.kvcacheHelper.Run(processingCtx)
+	}
+
+	// run request processing workers
+	s.queue = newRequestQueue(s.config)
+	s.startWorkers(processingCtx)
+
+	s.startMetricsUpdaters(processingCtx)
 
 	listener := fasthttputil.NewInmemoryListener()
 
 	// start the http server
 	go func() {
+		defer stopProcessing()
 		if err := s.startServer(ctx, listener); err != nil {
 			logger.Error(err, "error starting server")
 		}
@@ -413,6 +441,275 @@ var _ = Describe("Simulator", func() {
 		Entry(nil, common.ModeEcho, -1),
 	)
 
+	DescribeTable("text completions with several prompts",
+		func(prompt openai.CompletionNewParamsPromptUnion, expectedTexts []string, expectedPromptTokens int64) {
+			ctx := context.TODO()
+			client, err := startServer(ctx, common.ModeEcho)
+			Expect(err).NotTo(HaveOccurred())
+
+			openaiclient := openai.NewClient(
+				option.WithBaseURL(baseURL),
+				option.WithHTTPClient(client))
+			params := openai.CompletionNewParams{
+				Prompt: prompt,
+				Model:  openai.CompletionNewParamsModel(model),
+			}
+			resp, err := openaiclient.Completions.New(ctx, params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Choices).To(HaveLen(len(expectedTexts)))
+			Expect(resp.Usage.PromptTokens).To(Equal(expectedPromptTokens))
+			Expect(resp.Usage.TotalTokens).To(Equal(resp.Usage.PromptTokens + resp.Usage.CompletionTokens))
+
+			for i, choice := range resp.Choices {
+				Expect(choice.Index).To(Equal(int64(i)))
+				Expect(choice.Text).To(Equal(expectedTexts[i]))
+			}
+		},
+		func(_ openai.CompletionNewParamsPromptUnion, expectedTexts []string, _ int64) string {
+			return fmt.Sprintf("prompts: %v", expectedTexts)
+		},
+		Entry(nil, openai.CompletionNewParamsPromptUnion{OfArrayOfStrings: []string{userMessage, "Hello world"}},
+			[]string{userMessage, "Hello world"}, int64(7)),
+		Entry(nil, openai.CompletionNewParamsPromptUnion{OfArrayOfTokens: []int64{1, 2, 3, 4}},
+			[]string{"1 2 3 4"}, int64(4)),
+		Entry(nil, openai.CompletionNewParamsPromptUnion{OfArrayOfTokenArrays: [][]int64{{1, 2}, {3, 4, 5}, {6}}},
+			[]string{"1 2", "3 4 5", "6"}, int64(6)),
+	)
+
+	It("Should stream a choice for each prompt", func() {
+		ctx := context.TODO()
+		client, err := startServer(ctx, common.ModeEcho)
+		Expect(err).NotTo(HaveOccurred())
+
+		openaiclient := openai.NewClient(
+			option.WithBaseURL(baseURL),
+			option.WithHTTPClient(client))
+		params := openai.CompletionNewParams{
+			Prompt: openai.CompletionNewParamsPromptUnion{
+				OfArrayOfStrings: []string{userMessage, "Hello world"},
+			},
+			Model:         openai.CompletionNewParamsModel(model),
+			StreamOptions: openai.ChatCompletionStreamOptionsParam{IncludeUsage: param.NewOpt(true)},
+		}
+		stream := openaiclient.Completions.NewStreaming(ctx, params)
+		defer func() {
+			err := stream.Close()
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		texts := make([]string, 2)
+		for stream.Next() {
+			chunk := stream.Current()
+			for _, choice := range chunk.Choices {
+				Expect(choice.Index).To(BeNumerically("<", 2))
+				texts[choice.Index] += choice.Text
+			}
+		}
+		Expect(texts).To(Equal([]string{userMessage, "Hello world"}))
+	})
+
+	Context("text completions echo, suffix and best_of", func() {
+		It("Should return the prompt and its log probabilities", func() {
+			ctx := context.TODO()
+			client, err := startServer(ctx, common.ModeRandom)
+			Expect(err).NotTo(HaveOccurred())
+
+			reqBody := `{"prompt": "This is a test.", "model": "my_model", "echo": true, "logprobs": 2, "max_tokens": 3}`
+			resp, err := client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+			Expect(err).NotTo(HaveOccurred())
+			defer func() {
+				err := resp.Body.Close()
+				Expect(err).NotTo(HaveOccurred())
+			}()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var completion openaiserverapi.TextCompletionResponse
+			err = json.NewDecoder(resp.Body).Decode(&completion)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(completion.Choices).To(HaveLen(1))
+			Expect(completion.Choices[0].Text).To(HavePrefix(userMessage))
+			Expect(completion.Usage.PromptTokens).To(Equal(int(userMsgTokens)))
+
+			logprobs := completion.Choices[0].Logprobs
+			numOfTokens := int(userMsgTokens) + completion.Usage.CompletionTokens
+			Expect(logprobs.Tokens).To(HaveLen(numOfTokens))
+			Expect(logprobs.TokenLogprobs).To(HaveLen(numOfTokens))
+			Expect(logprobs.TextOffset).To(HaveLen(numOfTokens))
+			Expect(logprobs.TopLogprobs).To(HaveLen(numOfTokens))
+			Expect(strings.Join(logprobs.Tokens, "")).To(Equal(completion.Choices[0].Text))
+			// the first token of the prompt has no log probability
+			Expect(logprobs.TokenLogprobs[0]).To(BeNil())
+			Expect(logprobs.TopLogprobs[0]).To(BeNil())
+			for i := 1; i < numOfTokens; i++ {
+				Expect(*logprobs.TokenLogprobs[i]).To(BeNumerically("<=", 0))
+				Expect(logprobs.TopLogprobs[i]).To(HaveLen(3))
+				Expect(logprobs.TopLogprobs[i]).To(HaveKey(logprobs.Tokens[i]))
+			}
+		})
+
+		It("Should score the prompt when max tokens is zero", func() {
+			ctx := context.TODO()
+			client, err := startServer(ctx, common.ModeRandom)
+			Expect(err).NotTo(HaveOccurred())
+
+			reqBody := `{"prompt": "This is a test.", "model": "my_model", "echo": true, "logprobs": 0, "max_tokens": 0}`
+			resp, err := client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+			Expect(err).NotTo(HaveOccurred())
+			defer func() {
+				err := resp.Body.Close()
+				Expect(err).NotTo(HaveOccurred())
+			}()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var completion openai.Completion
+			err = json.NewDecoder(resp.Body).Decode(&completion)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(completion.Choices[0].Text).To(Equal(userMessage))
+			Expect(completion.Usage.CompletionTokens).To(BeZero())
+			Expect(completion.Choices[0].Logprobs.Tokens).To(HaveLen(int(userMsgTokens)))
+		})
+
+		It("Should count all best_of completions in usage", func() {
+			ctx := context.TODO()
+			client, err := startServer(ctx, common.ModeEcho)
+			Expect(err).NotTo(HaveOccurred())
+
+			openaiclient := openai.NewClient(
+				option.WithBaseURL(baseURL),
+				option.WithHTTPClient(client))
+			params := openai.CompletionNewParams{
+				Prompt: openai.CompletionNewParamsPromptUnion{
+					OfString: openai.String(userMessage),
+				},
+				Model:  openai.CompletionNewParamsModel(model),
+				BestOf: param.NewOpt(int64(3)),
+				Suffix: param.NewOpt("Hello world"),
+			}
+			resp, err := openaiclient.Completions.New(ctx, params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Choices).To(HaveLen(1))
+			Expect(resp.Choices[0].Text).To(Equal(userMessage))
+			Expect(resp.Choices[0].Logprobs.Tokens).To(BeEmpty())
+			Expect(resp.Usage.CompletionTokens).To(Equal(3 * userMsgTokens))
+			// the suffix is part of the prompt
+			Expect(resp.Usage.PromptTokens).To(Equal(userMsgTokens + 2))
+		})
+
+		It("Should reject best_of in streaming mode", func() {
+			ctx := context.TODO()
+			client, err := startServer(ctx, common.ModeEcho)
+			Expect(err).NotTo(HaveOccurred())
+
+			reqBody := `{"prompt": "This is a test.", "model": "my_model", "best_of": 2, "stream": true}`
+			resp, err := client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+			Expect(err).NotTo(HaveOccurred())
+			defer func() {
+				err := resp.Body.Close()
+				Expect(err).NotTo(HaveOccurred())
+			}()
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("multimodal chat completions", func() {
+		sendMultimodalRequest := func(client *http.Client, content string) *http.Response {
+			reqBody := fmt.Sprintf(`{"model": "my_model", "max_tokens": 5, "messages": [{"role": "user", "content": [
+				{"type": "text", "text": "This is a test."}, %s]}]}`, content)
+			resp, err := client.Post("http://localhost/v1/chat/completions", "application/json", strings.NewReader(reqBody))
+			Expect(err).NotTo(HaveOccurred())
+			return resp
+		}
+
+		createImageDataURL := func(width int, height int) string {
+			var buf bytes.Buffer
+			err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+			Expect(err).NotTo(HaveOccurred())
+			return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+		}
+
+		DescribeTable("should count the tokens of the multimodal content",
+			func(args []string, content string, expectedTokens int) {
+				ctx := context.TODO()
+				client, err := startServerWithArgs(ctx, common.ModeEcho,
+					append([]string{"cmd", "--model", model, "--mode", common.ModeEcho}, args...), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := sendMultimodalRequest(client, content)
+				defer func() {
+					err := resp.Body.Close()
+					Expect(err).NotTo(HaveOccurred())
+				}()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var completion openai.ChatCompletion
+				err = json.NewDecoder(resp.Body).Decode(&completion)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(completion.Usage.PromptTokens).To(Equal(userMsgTokens + int64(expectedTokens)))
+				Expect(completion.Choices[0].Message.Content).To(HavePrefix(userMessage))
+			},
+			func(args []string, content string, expectedTokens int) string {
+				return fmt.Sprintf("args: %v, expected tokens: %d", args, expectedTokens)
+			},
+			Entry(nil, []string{}, `{"type": "image_url", "image_url": {"url": "https://example.com/image.png"}}`, 576),
+			Entry(nil, []string{"--image-tokens", "100"},
+				`{"type": "image_url", "image_url": {"url": "https://example.com/image.png"}},
+				{"type": "image_url", "image_url": {"url": "https://example.com/image2.png"}}`, 200),
+			Entry(nil, []string{"--image-tokens", "10", "--image-tile-size", "32"},
+				`{"type": "image_url", "image_url": {"url": "`+createImageDataURL(100, 50)+`"}}`, 80),
+			Entry(nil, []string{"--image-tokens", "10", "--image-tile-size", "32"},
+				`{"type": "image_url", "image_url": {"url": "https://example.com/image.png"}}`, 10),
+			Entry(nil, []string{"--audio-tokens", "30"},
+				`{"type": "input_audio", "input_audio": {"data": "UklGRg==", "format": "wav"}}`, 30),
+			Entry(nil, []string{"--video-tokens", "300"},
+				`{"type": "video_url", "video_url": {"url": "https://example.com/video.mp4"}}`, 300),
+		)
+
+		It("Should include images in the context window validation", func() {
+			ctx := context.TODO()
+			args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--max-model-len", "100"}
+			client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := sendMultimodalRequest(client, `{"type": "image_url", "image_url": {"url": "https://example.com/image.png"}}`)
+			defer func() {
+				err := resp.Body.Close()
+				Expect(err).NotTo(HaveOccurred())
+			}()
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(string(body)).To(ContainSubstring("%d in the messages", userMsgTokens+576))
+		})
+
+		It("Should reject an invalid image data URL", func() {
+			ctx := context.TODO()
+			args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--image-tile-size", "32"}
+			client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := sendMultimodalRequest(client, `{"type": "image_url", "image_url": {"url": "data:image/png;base64,aGVsbG8="}}`)
+			defer func() {
+				err := resp.Body.Close()
+				Expect(err).NotTo(HaveOccurred())
+			}()
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	It("Should reject an empty prompts array", func() {
+		ctx := context.TODO()
+		client, err := startServer(ctx, common.ModeEcho)
+		Expect(err).NotTo(HaveOccurred())
+
+		reqBody := `{"prompt": [], "model": "my_model"}`
+		resp, err := client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+		Expect(err).NotTo(HaveOccurred())
+		defer func() {
+			err := resp.Body.Close()
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
 	It("Should respond to /health", func() {
 		ctx := context.TODO()
 		client, err := startServer(ctx, common.ModeRandom)
@@ -747,6 +1044,104 @@ var _ = Describe("Simulator", func() {
 		})
 	})
 
+	Context("multiple base models", func() {
+		It("Should serve the additional base models with their settings", func() {
+			ctx := context.TODO()
+			args := []string{"cmd", "--model", model, "--mode", common.ModeRandom,
+				"--additional-models", `{"name":"model2","served-model-name":["model2","model2-alias"],"max-model-len":5}`,
+				`{"name":"model3","mode":"echo"}`,
+				"--lora-modules", `{"name":"model3-lora","base_model_name":"model3"}`}
+			client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := client.Get("http://localhost/v1/models")
+			Expect(err).NotTo(HaveOccurred())
+			var modelsResp vllmapi.ModelsResponse
+			err = json.Unmarshal([]byte(readBody(resp)), &modelsResp)
+			Expect(err).NotTo(HaveOccurred())
+			parents := make(map[string]string)
+			for _, info := range modelsResp.Data {
+				parents[info.ID] = ""
+				if info.Parent != nil {
+					parents[info.ID] = *info.Parent
+				}
+			}
+			Expect(parents).To(Equal(map[string]string{model: "", "model2": "", "model2-alias": "", "model3": "",
+				"model3-lora": "model3"}))
+
+			sendRequest := func(model string) (int, string) {
+				reqBody := fmt.Sprintf(`{"prompt": "%s", "model": "%s", "max_tokens": 10}`, userMessage, model)
+				resp, err := client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+				Expect(err).NotTo(HaveOccurred())
+				return resp.StatusCode, readBody(resp)
+			}
+
+			code, body := sendRequest("model2-alias")
+			Expect(code).To(Equal(http.StatusBadRequest))
+			Expect(body).To(ContainSubstring("This model's maximum context length is 5 tokens"))
+
+			for _, name := range []string{"model3", "model3-lora"} {
+				code, body = sendRequest(name)
+				Expect(code).To(Equal(http.StatusOK))
+				var completion openaiserverapi.TextCompletionResponse
+				err = json.Unmarshal([]byte(body), &completion)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(completion.Model).To(Equal(name))
+				Expect(completion.Choices[0].Text).To(Equal(userMessage))
+			}
+
+			code, _ = sendRequest("model4")
+			Expect(code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("model profiles", func() {
+		sendRequest := func(client *http.Client, model string, prompt string) (int, string, time.Duration) {
+			start := time.Now()
+			reqBody := fmt.Sprintf(`{"prompt": "%s", "model": "%s", "max_tokens": 10}`, prompt, model)
+			resp, err := client.Post("http://localhost/v1/completions", "application/json", strings.NewReader(reqBody))
+			Expect(err).NotTo(HaveOccurred())
+			body := readBody(resp)
+			return resp.StatusCode, body, time.Since(start)
+		}
+
+		It("Should apply the overrides of LoRAs and served model names", func() {
+			ctx := context.TODO()
+			args := []string{"cmd", "--model", model, "--mode", common.ModeRandom,
+				"--served-model-name", model, "echo-alias", "short-alias",
+				"--model-profiles", `{"name":"echo-alias","mode":"echo"}`, `{"name":"short-alias","max-model-len":5}`,
+				"--lora-modules", `{"name":"slow-lora","time-to-first-token":500}`,
+				`{"name":"flaky-lora","failure-injection-rate":100}`}
+			client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			// the base model uses the global settings
+			code, _, duration := sendRequest(client, model, userMessage)
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(duration).To(BeNumerically("<", 500*time.Millisecond))
+
+			code, body, _ := sendRequest(client, "echo-alias", userMessage)
+			Expect(code).To(Equal(http.StatusOK))
+			var completion openaiserverapi.TextCompletionResponse
+			err = json.Unmarshal([]byte(body), &completion)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(completion.Choices[0].Text).To(Equal(userMessage))
+
+			code, body, _ = sendRequest(client, "short-alias", userMessage)
+			Expect(code).To(Equal(http.StatusBadRequest))
+			Expect(body).To(ContainSubstring("This model's maximum context length is 5 tokens"))
+
+			code, _, duration = sendRequest(client, "slow-lora", userMessage)
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(duration).To(BeNumerically(">=", 500*time.Millisecond))
+
+			for range 5 {
+				code, _, _ = sendRequest(client, "flaky-lora", userMessage)
+				Expect(code).NotTo(Equal(http.StatusOK))
+			}
+		})
+	})
+
 	Describe("Check random latencies", Ordered, func() {
 		var simulator *VllmSimulator
 
@@ -769,7 +1164,7 @@ var _ = Describe("Simulator", func() {
 			func(interTokenLatency int, stddev int) {
 				simulator.config.InterTokenLatency = interTokenLatency
 				simulator.config.InterTokenLatencyStdDev = stddev
-				interToken := simulator.getInterTokenLatency()
+				interToken := simulator.getInterTokenLatency(model)
 				Expect(interToken).To(BeNumerically(">=", int(float32(interTokenLatency)*0.3)))
 				Expect(interToken).To(BeNumerically("<=", int(float32(interTokenLatency)*1.7)))
 			},
@@ -786,7 +1181,7 @@ var _ = Describe("Simulator", func() {
 			func(interTokenLatency int, stddev int, numberOfTokens int) {
 				simulator.config.InterTokenLatency = interTokenLatency
 				simulator.config.InterTokenLatencyStdDev = stddev
-				latency := simulator.getTotalInterTokenLatency(numberOfTokens)
+				latency := simulator.getTotalInterTokenLatency(createScheduledRequest(0), numberOfTokens)
 				Expect(latency).To(BeNumerically(">=", int(float32(interTokenLatency)*0.3*float32(numberOfTokens))))
 				Expect(latency).To(BeNumerically("<=", int(float32(interTokenLatency)*1.7*float32(numberOfTokens))))
 			},
@@ -807,7 +1202,7 @@ var _ = Describe("Simulator", func() {
 				simulator.config.TimeToFirstTokenStdDev = timeToFirstTokenStdDev
 				simulator.config.KVCacheTransferLatency = kvCacheLatency
 				simulator.config.KVCacheTransferLatencyStdDev = kvCacheLatencyStdDev
-				timeToFirst := simulator.getTimeToFirstToken(doREmotePrefill)
+				timeToFirst := simulator.getTimeToFirstToken(model, doREmotePrefill)
 				if doREmotePrefill {
 					Expect(timeToFirst).To(BeNumerically(">=", int(float32(kvCacheLatency)*0.3)))
 					Expect(timeToFirst).To(BeNumerically("<=", int(float32(kvCacheLatency)*1.7)))
@@ -829,4 +1224,93 @@ var _ = Describe("Simulator", func() {
 			Entry(nil, 10000, 0, 1000, 0, false),
 		)
 	})
+
+	Context("library API", func() {
+		newLibraryConfig := func(ttft int) *common.Configuration {
+			config := common.NewDefaultConfig()
+			config.Model = model
+			config.Port = 0
+			config.TimeToFirstToken = ttft
+			return config
+		}
+
+		It("Should reject an invalid configuration", func() {
+			_, err := NewWithConfig(common.NewDefaultConfig())
+			Expect(err).To(HaveOccurred())
+
+			config := newLibraryConfig(0)
+			config.FleetSize = 2
+			_, err = NewWithConfig(config)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should run two simulators with their own ports and metrics in one process", func() {
+			sim1, err := NewWithConfig(newLibraryConfig(1000), WithLogger(klog.Background()))
+			Expect(err).NotTo(HaveOccurred())
+			listener, err := net.Listen("tcp4", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			sim2, err := NewWithConfig(newLibraryConfig(0), WithListener(listener))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sim2.Addr()).To(Equal(listener.Addr()))
+
+			port1 := sim1.Addr().(*net.TCPAddr).Port
+			port2 := sim2.Addr().(*net.TCPAddr).Port
+			Expect(port1).NotTo(BeZero())
+			Expect(port1).NotTo(Equal(port2))
+
+			for _, sim := range []*VllmSimulator{sim1, sim2} {
+				go func() {
+					defer GinkgoRecover()
+					Expect(sim.Start(context.Background())).To(Succeed())
+				}()
+			}
+
+			// a slow request to the first simulator
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				reqBody := fmt.Sprintf(`{"prompt": "%s", "model": "%s", "max_tokens": 2}`, userMessage, model)
+				resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d/v1/completions", port1), "application/json",
+					strings.NewReader(reqBody))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				readBody(resp)
+			}()
+
+			// each simulator reports its own metrics
+			getMetrics := func(port int) string {
+				resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", port))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				return readBody(resp)
+			}
+			Eventually(func() string {
+				return getMetrics(port1)
+			}, 2*time.Second, 50*time.Millisecond).Should(ContainSubstring("vllm:num_requests_running{model_name=\"my_model\"} 1"))
+			Expect(getMetrics(port2)).To(ContainSubstring("vllm:num_requests_running{model_name=\"my_model\"} 0"))
+			<-done
+
+			sim1.Stop()
+			_, err = http.Get(fmt.Sprintf("http://127.0.0.1:%d/health", port1))
+			Expect(err).To(HaveOccurred())
+			resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/health", port2))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			readBody(resp)
+			sim2.Stop()
+		})
+
+		It("Should not run a simulator that was stopped before it started", func() {
+			sim, err := NewWithConfig(newLibraryConfig(0))
+			Expect(err).NotTo(HaveOccurred())
+			sim.Stop()
+
+			started := make(chan error, 1)
+			go func() {
+				started <- sim.Start(context.Background())
+			}()
+			Eventually(started, 2*time.Second).Should(Receive(BeNil()))
+		})
+	})
 })
@@ -0,0 +1,102 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains the sleep mode and the prefix cache reset endpoints of vLLM's development mode
+
+package llmdinferencesim
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+// isSleepingResponse is the response of /is_sleeping
+type isSleepingResponse struct {
+	IsSleeping bool `json:"is_sleeping"`
+}
+
+// HandleSleep http handler for /sleep, the waiting requests are not processed until the simulator
+// wakes up and the KV cache usage is reported as zero
+func (s *VllmSimulator) HandleSleep(ctx *fasthttp.RequestCtx) {
+	s.logger.Info("sleep request received")
+	level := 1
+	if arg := ctx.QueryArgs().Peek("level"); len(arg) > 0 {
+		var err error
+		level, err = strconv.Atoi(string(arg))
+		if err != nil || (level != 1 && level != 2) {
+			s.sendCompletionError(ctx, openaiserverapi.NewCompletionError("Invalid sleep level "+string(arg)+
+				", valid levels are 1 and 2", fasthttp.StatusBadRequest, nil), false)
+			return
+		}
+	}
+
+	s.sleepMutex.Lock()
+	defer s.sleepMutex.Unlock()
+	if !s.sleeping.Load() {
+		s.sleeping.Store(true)
+		s.queue.setPaused(true)
+		s.reportKVCacheUsage()
+		s.logger.Info("Simulator is sleeping", "level", level)
+	}
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+}
+
+// HandleWakeUp http handler for /wake_up, responds after the simulator woke up
+func (s *VllmSimulator) HandleWakeUp(ctx *fasthttp.RequestCtx) {
+	s.logger.Info("wake up request received")
+	s.sleepMutex.Lock()
+	defer s.sleepMutex.Unlock()
+	if s.sleeping.Load() {
+		time.Sleep(time.Duration(s.getConfig().WakeUpLatency) * time.Millisecond)
+		s.sleeping.Store(false)
+		s.queue.setPaused(false)
+		s.reportKVCacheUsage()
+		s.logger.Info("Simulator woke up")
+	}
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+}
+
+// HandleIsSleeping http handler for /is_sleeping, the simulator is sleeping until it finishes waking up
+func (s *VllmSimulator) HandleIsSleeping(ctx *fasthttp.RequestCtx) {
+	s.logger.V(4).Info("is sleeping request received")
+	data, err := json.Marshal(isSleepingResponse{IsSleeping: s.sleeping.Load()})
+	if err != nil {
+		ctx.Error("Failed to marshal is sleeping response, "+err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+	ctx.Response.SetBody(data)
+}
+
+// HandleResetPrefixCache http handler for /reset_prefix_cache, removes all the blocks from the KV cache
+// and emits an AllBlocksCleared event
+func (s *VllmSimulator) HandleResetPrefixCache(ctx *fasthttp.RequestCtx) {
+	s.logger.Info("reset prefix cache request received")
+	if s.kvcacheHelper != nil {
+		if err := s.kvcacheHelper.ResetPrefixCache(); err != nil {
+			s.sendCompletionError(ctx, openaiserverapi.NewCompletionError("Failed to reset prefix cache, "+err.Error(),
+				fasthttp.StatusBadRequest, nil), false)
+			return
+		}
+	}
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+var _ = Describe("Sleep mode", func() {
+	post := func(client *http.Client, path string) int {
+		resp, err := client.Post("http://localhost"+path, "application/json", nil)
+		Expect(err).NotTo(HaveOccurred())
+		_ = readBody(resp)
+		return resp.StatusCode
+	}
+
+	isSleeping := func(client *http.Client) bool {
+		resp, err := client.Get("http://localhost/is_sleeping")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var response isSleepingResponse
+		Expect(json.Unmarshal([]byte(readBody(resp)), &response)).To(Succeed())
+		return response.IsSleeping
+	}
+
+	It("should not process requests while sleeping", func() {
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--enable-sleep-mode",
+				"--wake-up-latency", "300"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(isSleeping(client)).To(BeFalse())
+		Expect(post(client, "/sleep?level=3")).To(Equal(http.StatusBadRequest))
+		Expect(post(client, "/sleep?level=2")).To(Equal(http.StatusOK))
+		Expect(isSleeping(client)).To(BeTrue())
+
+		done := make(chan int, 1)
+		go func() {
+			defer GinkgoRecover()
+			resp, err := client.Post("http://localhost/v1/completions", "application/json",
+				strings.NewReader(`{"prompt": "This is a test", "model": "my_model", "max_tokens": 2}`))
+			Expect(err).NotTo(HaveOccurred())
+			_ = readBody(resp)
+			done <- resp.StatusCode
+		}()
+		Consistently(done).WithTimeout(300 * time.Millisecond).ShouldNot(Receive())
+
+		start := time.Now()
+		Expect(post(client, "/wake_up")).To(Equal(http.StatusOK))
+		Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+		Expect(isSleeping(client)).To(BeFalse())
+		Eventually(done).WithTimeout(time.Second).Should(Receive(Equal(http.StatusOK)))
+	})
+
+	It("should report zero KV cache usage while sleeping", func() {
+		s, client, err := startServerWithArgsAndMetrics(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--enable-sleep-mode",
+				"--fake-metrics", "{\"running-requests\":1,\"waiting-requests\":2,\"kv-cache-usage\":0.4}"}, nil, true)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.unregisterPrometheus()
+
+		getMetrics := func() string {
+			resp, err := client.Get(metricsUrl)
+			Expect(err).NotTo(HaveOccurred())
+			return readBody(resp)
+		}
+
+		Expect(post(client, "/sleep")).To(Equal(http.StatusOK))
+		Expect(getMetrics()).To(ContainSubstring("vllm:gpu_cache_usage_perc{model_name=\"my_model\"} 0\n"))
+		Expect(post(client, "/wake_up")).To(Equal(http.StatusOK))
+		Expect(getMetrics()).To(ContainSubstring("vllm:gpu_cache_usage_perc{model_name=\"my_model\"} 0.4"))
+	})
+
+	It("should support the endpoints only in sleep mode", func() {
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(post(client, "/sleep")).To(Equal(http.StatusNotFound))
+		Expect(post(client, "/wake_up")).To(Equal(http.StatusNotFound))
+		Expect(post(client, "/reset_prefix_cache")).To(Equal(http.StatusNotFound))
+	})
+
+	It("should reset the prefix cache in sleep mode", func() {
+		client, err := startServerWithArgs(context.Background(), common.ModeRandom,
+			[]string{"cmd", "--model", model, "--mode", common.ModeRandom, "--enable-sleep-mode"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(post(client, "/reset_prefix_cache")).To(Equal(http.StatusOK))
+	})
+})
@@ -19,6 +19,7 @@ package llmdinferencesim
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -33,14 +34,29 @@ type streamingContext struct {
 	model            string
 	creationTime     int64
 	doRemotePrefill  bool
+	// request is the scheduled request the response is sent for
+	request *scheduledRequest
+	// failure is the failure to inject in the middle of the stream, nil if none
+	failure *streamFailure
+	// sentTokens is the number of generated tokens sent so far
+	sentTokens int
 }
 
+// connectionCloseDelay is the time given to the server to write the sent chunks before the connection
+// is closed by an injected stream failure
+const connectionCloseDelay = 100 * time.Millisecond
+
+// errStreamFailureInjected is returned when a stream is stopped by an injected failure
+var errStreamFailureInjected = errors.New("stream failure injected")
+
 // sendStreamingResponse creates and sends a streaming response for completion requests of both types (text and chat)
 // as defined by isChatCompletion
 // response content is wrapped according SSE format
 // First token is send after timeToFirstToken milliseconds, every other token is sent after interTokenLatency milliseconds
-func (s *VllmSimulator) sendStreamingResponse(context *streamingContext, responseTokens []string, toolCalls []openaiserverapi.ToolCall,
-	finishReason string, usageData *openaiserverapi.Usage) {
+// In case of several choices (a text completion request with several prompts), the choices are sent one after another,
+// each chunk contains the index of its choice
+func (s *VllmSimulator) sendStreamingResponse(context *streamingContext, choices []responseChoice, toolCalls []openaiserverapi.ToolCall,
+	usageData *openaiserverapi.Usage) {
 	context.ctx.SetContentType("text/event-stream")
 	context.ctx.SetStatusCode(fasthttp.StatusOK)
 
@@ -53,9 +69,17 @@ func (s *VllmSimulator) sendStreamingResponse(context *streamingContext, respons
 	}
 
 	context.ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer s.queue.finish(context.request)
+		defer s.responseSentCallback(context.model)
 		context.creationTime = time.Now().Unix()
+		config := s.getModelConfig(context.request.model())
+		if injection := context.request.injection; injection == nil {
+			context.failure = getStreamFailure(config, numOfTokens(choices, toolCalls))
+		} else if injection.isStreamFailure() {
+			context.failure = newStreamFailure(config, injection.failure, numOfTokens(choices, toolCalls))
+		}
 
-		if len(responseTokens) > 0 || len(toolCalls) > 0 {
+		if hasContent(choices) || len(toolCalls) > 0 {
 			if context.isChatCompletion {
 				// in chat completion first chunk contains the role
 				chunk := s.createChatCompletionChunk(context, "", nil, openaiserverapi.RoleAssistant, nil)
@@ -67,11 +91,26 @@ func (s *VllmSimulator) sendStreamingResponse(context *streamingContext, respons
 			if len(toolCalls) > 0 {
 				s.logger.Info("Going to send tools calls")
 				for _, tc := range toolCalls {
-					s.sendTokenChunks(context, w, tc.Function.TokenizedArguments, &tc, finishReason)
+					choice := responseChoice{tokens: tc.Function.TokenizedArguments, finishReason: choices[0].finishReason}
+					if err := s.sendTokenChunks(context, w, &choice, &tc, 0, true); err != nil {
+						return
+					}
 				}
 			} else {
-				s.logger.Info("Going to send text", "number of tokens", len(responseTokens))
-				s.sendTokenChunks(context, w, responseTokens, nil, finishReason)
+				for i, choice := range choices {
+					s.logger.Info("Going to send text", "choice", i, "number of tokens", len(choice.tokens))
+					// choices are generated in parallel, so time to first token is applied only once
+					if err := s.sendTokenChunks(context, w, &choice, nil, i, i == 0); err != nil {
+						return
+					}
+				}
+			}
+		}
+
+		// a failure that should be injected after all the tokens
+		if context.failure != nil {
+			if err := s.injectStreamFailure(context, w); err != nil {
+				return
 			}
 		}
 
@@ -89,18 +128,128 @@ func (s *VllmSimulator) sendStreamingResponse(context *streamingContext, respons
 			context.ctx.Error("Sending last stream chunk failed, "+err.Error(), fasthttp.StatusInternalServerError)
 			return
 		}
-		s.responseSentCallback(context.model)
 	})
 }
 
-// sendTokenChunks creates and sends response chunks
-func (s *VllmSimulator) sendTokenChunks(context *streamingContext, w *bufio.Writer, tokens []string, tc *openaiserverapi.ToolCall, finishReason string) {
-	// time to first token delay
-	time.Sleep(time.Duration(s.getTimeToFirstToken(context.doRemotePrefill)) * time.Millisecond)
+// numOfTokens returns the number of generated tokens in the given choices or tool calls
+func numOfTokens(choices []responseChoice, toolCalls []openaiserverapi.ToolCall) int {
+	count := 0
+	if len(toolCalls) > 0 {
+		for _, tc := range toolCalls {
+			count += len(tc.Function.TokenizedArguments)
+		}
+		return count
+	}
+	for _, choice := range choices {
+		count += len(choice.tokens)
+	}
+	return count
+}
+
+// injectStreamFailure injects the failure of the stream, returns an error if the stream should be stopped
+func (s *VllmSimulator) injectStreamFailure(context *streamingContext, w *bufio.Writer) error {
+	failure := context.failure
+	context.failure = nil
+	s.logger.Info("Injecting stream failure", "type", failure.failureType, "sent tokens", context.sentTokens)
+
+	switch failure.failureType {
+	case common.StreamFailureTypeConnectionClose:
+		s.closeConnection(context)
+		return errStreamFailureInjected
+	case common.StreamFailureTypeErrorEvent:
+		data, err := json.Marshal(openaiserverapi.ErrorResponse{Error: streamFailureError})
+		if err != nil {
+			return err
+		}
+		if err := s.sendChunk(w, nil, string(data)); err != nil {
+			return err
+		}
+		if err := s.sendChunk(w, nil, "[DONE]"); err != nil {
+			return err
+		}
+		return errStreamFailureInjected
+	case common.StreamFailureTypeStall:
+		// no more data is sent, and the stream is never finished
+		select {
+		case <-time.After(time.Duration(failure.stallTime) * time.Millisecond):
+		case <-context.request.aborted:
+		}
+		s.closeConnection(context)
+		return errStreamFailureInjected
+	case common.StreamFailureTypeMalformedChunk:
+		// a truncated chunk is sent, and the stream continues
+		var chunk openaiserverapi.CompletionRespChunk
+		if context.isChatCompletion {
+			chunk = s.createChatCompletionChunk(context, "", nil, "", nil)
+		} else {
+			chunk = s.createTextCompletionChunk(context, "", nil, 0, nil)
+		}
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		return s.sendChunk(w, nil, string(data[:len(data)/2]))
+	}
+	return nil
+}
+
+// closeConnection closes the connection of the streamed response abruptly
+func (s *VllmSimulator) closeConnection(context *streamingContext) {
+	time.Sleep(connectionCloseDelay)
+	if err := context.ctx.Conn().Close(); err != nil {
+		s.logger.Error(err, "failed to close connection")
+	}
+}
+
+// hasContent returns true if at least one of the choices contains generated tokens or an echoed prompt
+func hasContent(choices []responseChoice) bool {
+	for _, choice := range choices {
+		if len(choice.tokens) > 0 || choice.prefix != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sendTokenChunks creates and sends response chunks of the choice with the given index,
+// waits for the time to first token before the first chunk if firstTokenDelay is true,
+// returns an error if the stream should be stopped
+func (s *VllmSimulator) sendTokenChunks(context *streamingContext, w *bufio.Writer, choice *responseChoice, tc *openaiserverapi.ToolCall,
+	index int, firstTokenDelay bool) error {
+	tokens := choice.tokens
+	finishReason := choice.finishReason
+	if firstTokenDelay {
+		// time to first token delay
+		s.wait(context.request, s.getRequestTimeToFirstToken(context.request, context.doRemotePrefill),
+			context.doRemotePrefill)
+	}
+
+	// the echoed prompt is sent in a single chunk
+	numOfPrefixTokens := choice.numOfPrefixTokens()
+	if choice.prefix != "" {
+		var finishReasonToSend *string
+		if len(tokens) == 0 {
+			finishReasonToSend = &finishReason
+		}
+		chunk := s.createTextCompletionChunk(context, choice.prefix, finishReasonToSend, index,
+			sliceLogprobs(choice.logprobs, 0, numOfPrefixTokens))
+		if err := s.sendChunk(w, chunk, ""); err != nil {
+			context.ctx.Error("Sending stream chunk failed, "+err.Error(), fasthttp.StatusInternalServerError)
+			return err
+		}
+		if len(tokens) == 0 {
+			return nil
+		}
+	}
 
 	for i, token := range tokens {
-		if i != 0 {
-			time.Sleep(time.Duration(s.getInterTokenLatency()) * time.Millisecond)
+		if i != 0 || !firstTokenDelay {
+			s.wait(context.request, s.getRequestInterTokenLatency(context.request), context.doRemotePrefill)
+		}
+		if context.failure != nil && context.sentTokens >= context.failure.afterTokens {
+			if err := s.injectStreamFailure(context, w); err != nil {
+				return err
+			}
 		}
 		var toolChunkInsert *openaiserverapi.ToolCall
 		if tc != nil {
@@ -125,13 +274,15 @@ func (s *VllmSimulator) sendTokenChunks(context *streamingContext, w *bufio.Writ
 		if context.isChatCompletion {
 			chunk = s.createChatCompletionChunk(context, token, toolChunkInsert, "", finishReasonToSend)
 		} else {
-			chunk = s.createTextCompletionChunk(context, token, finishReasonToSend)
+			chunk = s.createTextCompletionChunk(context, token, finishReasonToSend, index,
+				sliceLogprobs(choice.logprobs, numOfPrefixTokens+i, numOfPrefixTokens+i+1))
 		}
 
 		if err := s.sendChunk(w, chunk, ""); err != nil {
 			context.ctx.Error("Sending stream chunk failed, "+err.Error(), fasthttp.StatusInternalServerError)
-			return
+			return err
 		}
+		context.sentTokens++
 	}
 
 	// send the last chunk if finish reason is stop
@@ -140,13 +291,14 @@ func (s *VllmSimulator) sendTokenChunks(context *streamingContext, w *bufio.Writ
 		if context.isChatCompletion {
 			chunk = s.createChatCompletionChunk(context, "", nil, "", &finishReason)
 		} else {
-			chunk = s.createTextCompletionChunk(context, "", &finishReason)
+			chunk = s.createTextCompletionChunk(context, "", &finishReason, index, nil)
 		}
 		if err := s.sendChunk(w, chunk, ""); err != nil {
 			context.ctx.Error("Sending last stream chunk failed, "+err.Error(), fasthttp.StatusInternalServerError)
-			return
+			return err
 		}
 	}
+	return nil
 }
 
 // createUsageChunk creates and returns a CompletionRespChunk with usage data, a single chunk of streamed completion API response,
@@ -174,8 +326,10 @@ func (s *VllmSimulator) createUsageChunk(context *streamingContext, usageData *o
 }
 
 // createTextCompletionChunk creates and returns a CompletionRespChunk, a single chunk of streamed completion API response,
-// for text completion
-func (s *VllmSimulator) createTextCompletionChunk(context *streamingContext, token string, finishReason *string) openaiserverapi.CompletionRespChunk {
+// for text completion, index is the index of the choice the chunk belongs to, logprobs are the log probabilities
+// of the chunk's tokens
+func (s *VllmSimulator) createTextCompletionChunk(context *streamingContext, token string, finishReason *string,
+	index int, logprobs *openaiserverapi.TextLogprobs) openaiserverapi.CompletionRespChunk {
 	return &openaiserverapi.TextCompletionResponse{
 		BaseCompletionResponse: openaiserverapi.BaseCompletionResponse{
 			ID:      chatComplIDPrefix + common.GenerateUUIDString(),
@@ -185,8 +339,9 @@ func (s *VllmSimulator) createTextCompletionChunk(context *streamingContext, tok
 		},
 		Choices: []openaiserverapi.TextRespChoice{
 			{
-				BaseResponseChoice: openaiserverapi.BaseResponseChoice{Index: 0, FinishReason: finishReason},
+				BaseResponseChoice: openaiserverapi.BaseResponseChoice{Index: index, FinishReason: finishReason},
 				Text:               token,
+				Logprobs:           logprobs,
 			},
 		},
 	}
@@ -0,0 +1,322 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Contains functions related to the simulation of audio transcription requests
+package llmdinferencesim
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	transcriptionFormatJSON        = "json"
+	transcriptionFormatText        = "text"
+	transcriptionFormatVerboseJSON = "verbose_json"
+	transcriptionFormatSRT         = "srt"
+	transcriptionFormatVTT         = "vtt"
+
+	// the number of generated tokens per second of audio
+	transcriptionTokensPerSecond = 3
+	// the maximum number of tokens in a segment of a verbose transcription
+	maxTokensPerSegment = 20
+	// the assumed bitrate (128 kbps) of a non WAV audio file, used to estimate its duration
+	defaultAudioBytesPerSecond = 16000
+	// the size of the vocabulary of Whisper models, used to create synthetic token ids
+	whisperVocabularySize = 51865
+)
+
+// transcriptionRequest contains the fields of a transcription request
+type transcriptionRequest struct {
+	model          string
+	audio          []byte
+	responseFormat string
+	language       string
+	prompt         string
+}
+
+// HandleTranscriptions http handler for /v1/audio/transcriptions
+func (s *VllmSimulator) HandleTranscriptions(ctx *fasthttp.RequestCtx) {
+	s.logger.Info("transcription request received")
+
+	req, err := s.readTranscriptionRequest(ctx)
+	if err != nil {
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(err.Error(), fasthttp.StatusBadRequest, nil), false)
+		return
+	}
+	if !s.isValidModel(req.model) {
+		s.sendCompletionError(ctx, openaiserverapi.NewCompletionError(
+			fmt.Sprintf("The model `%s` does not exist.", req.model), fasthttp.StatusNotFound, nil), false)
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	// the request is scheduled like a completion request to its model
+	completionReq := &openaiserverapi.TextCompletionRequest{}
+	completionReq.RequestID = common.GenerateUUIDString()
+	completionReq.Model = req.model
+	reqCtx := &openaiserverapi.CompletionReqCtx{
+		CompletionReq: completionReq,
+		HTTPReqCtx:    ctx,
+		Wg:            &wg,
+	}
+	lora := ""
+	if s.isLora(req.model) {
+		lora = req.model
+	}
+	request := newScheduledRequest(reqCtx, lora)
+	request.transcription = req
+	s.enqueueRequest(request)
+	wg.Wait()
+}
+
+// processTranscription generates the transcription of the given request and sends it
+func (s *VllmSimulator) processTranscription(request *scheduledRequest) {
+	req := request.transcription
+	ctx := request.reqCtx.HTTPReqCtx
+	defer request.reqCtx.Wg.Done()
+	defer s.queue.finish(request)
+	defer s.responseSentCallback(req.model)
+
+	if request.loraLoadLatency > 0 {
+		s.logger.Info("Loading LoRA adapter", "lora", request.lora, "latency", request.loraLoadLatency)
+		s.wait(request, request.loraLoadLatency, false)
+	}
+
+	duration := audioDuration(req.audio)
+	text := req.prompt
+	if s.getModelConfig(req.model).Mode == common.ModeRandom || text == "" {
+		numOfTokens := max(1, int(math.Round(duration*transcriptionTokensPerSecond)))
+		text = common.GetRandomText(numOfTokens)
+	}
+
+	// transcription time is proportional to the audio length
+	millisToWait := s.getTimeToFirstToken(req.model, false) + int(duration*float64(s.getConfig().TranscriptionLatencyPerSecond))
+	s.wait(request, millisToWait, false)
+
+	body, contentType, err := createTranscriptionResponse(req, text, duration)
+	if err != nil {
+		ctx.Error("Response body creation failed, "+err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.Response.Header.SetContentType(contentType)
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+	if s.pod != "" {
+		ctx.Response.Header.Add(podHeader, s.pod)
+	}
+	if s.namespace != "" {
+		ctx.Response.Header.Add(namespaceHeader, s.namespace)
+	}
+	ctx.Response.SetBody(body)
+}
+
+// readTranscriptionRequest reads the multipart form of a transcription request
+func (s *VllmSimulator) readTranscriptionRequest(ctx *fasthttp.RequestCtx) (*transcriptionRequest, error) {
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read multipart form: %w", err)
+	}
+
+	files := form.File["file"]
+	if len(files) == 0 {
+		return nil, fmt.Errorf("audio file is missing")
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	audio, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio file: %w", err)
+	}
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("audio file is empty")
+	}
+
+	req := transcriptionRequest{
+		model:          formValue(form.Value, "model"),
+		audio:          audio,
+		responseFormat: formValue(form.Value, "response_format"),
+		language:       formValue(form.Value, "language"),
+		prompt:         formValue(form.Value, "prompt"),
+	}
+	if req.model == "" {
+		req.model = s.getConfig().ServedModelNames[0]
+	}
+	if req.responseFormat == "" {
+		req.responseFormat = transcriptionFormatJSON
+	}
+	if req.language == "" {
+		req.language = "en"
+	}
+
+	switch req.responseFormat {
+	case transcriptionFormatJSON, transcriptionFormatText, transcriptionFormatVerboseJSON,
+		transcriptionFormatSRT, transcriptionFormatVTT:
+	default:
+		return nil, fmt.Errorf("invalid response format '%s', valid values are: %s, %s, %s, %s, %s", req.responseFormat,
+			transcriptionFormatJSON, transcriptionFormatText, transcriptionFormatVerboseJSON,
+			transcriptionFormatSRT, transcriptionFormatVTT)
+	}
+	return &req, nil
+}
+
+// formValue returns the first value of the given multipart form field, or an empty string
+func formValue(values map[string][]string, key string) string {
+	if len(values[key]) == 0 {
+		return ""
+	}
+	return values[key][0]
+}
+
+// audioDuration returns the duration of the audio in seconds, the duration of a WAV file is calculated
+// from its header, the duration of other formats is estimated from their size
+func audioDuration(audio []byte) float64 {
+	if duration, ok := wavDuration(audio); ok {
+		return duration
+	}
+	return float64(len(audio)) / defaultAudioBytesPerSecond
+}
+
+// wavDuration returns the duration in seconds of WAV audio, the second return value is false if
+// the audio is not a valid WAV file
+func wavDuration(audio []byte) (float64, bool) {
+	if len(audio) < 12 || string(audio[0:4]) != "RIFF" || string(audio[8:12]) != "WAVE" {
+		return 0, false
+	}
+	byteRate := 0
+	offset := 12
+	for offset+8 <= len(audio) {
+		chunkID := string(audio[offset : offset+4])
+		chunkSize := int(binary.LittleEndian.Uint32(audio[offset+4 : offset+8]))
+		chunkStart := offset + 8
+		switch chunkID {
+		case "fmt ":
+			if chunkSize < 12 || chunkStart+12 > len(audio) {
+				return 0, false
+			}
+			byteRate = int(binary.LittleEndian.Uint32(audio[chunkStart+8 : chunkStart+12]))
+		case "data":
+			if byteRate == 0 {
+				return 0, false
+			}
+			// the size in the header may be larger than the actual data, e.g., in streamed WAV files
+			dataSize := min(chunkSize, len(audio)-chunkStart)
+			return float64(dataSize) / float64(byteRate), true
+		}
+		// chunks are aligned to two bytes
+		offset = chunkStart + chunkSize + chunkSize%2
+	}
+	return 0, false
+}
+
+// createTranscriptionResponse creates the body of the transcription response in the requested format,
+// returns the body and its content type
+func createTranscriptionResponse(req *transcriptionRequest, text string, duration float64) ([]byte, string, error) {
+	usage := &openaiserverapi.TranscriptionUsage{Type: "duration", Seconds: int(math.Ceil(duration))}
+
+	switch req.responseFormat {
+	case transcriptionFormatText:
+		return []byte(text), "text/plain; charset=utf-8", nil
+	case transcriptionFormatSRT, transcriptionFormatVTT:
+		return []byte(createSubtitles(createTranscriptionSegments(text, duration), req.responseFormat)),
+			"text/plain; charset=utf-8", nil
+	case transcriptionFormatVerboseJSON:
+		data, err := json.Marshal(openaiserverapi.VerboseTranscriptionResponse{
+			Task:     "transcribe",
+			Language: req.language,
+			Duration: duration,
+			Text:     text,
+			Segments: createTranscriptionSegments(text, duration),
+			Usage:    usage,
+		})
+		return data, "application/json", err
+	}
+	data, err := json.Marshal(openaiserverapi.TranscriptionResponse{Text: text, Usage: usage})
+	return data, "application/json", err
+}
+
+// createTranscriptionSegments splits the text into segments, a segment ends at the end of a sentence
+// or after maxTokensPerSegment tokens, the duration of the audio is divided between the segments
+// according to their number of tokens
+func createTranscriptionSegments(text string, duration float64) []openaiserverapi.TranscriptionSegment {
+	tokens := common.Tokenize(text)
+	segments := make([]openaiserverapi.TranscriptionSegment, 0)
+	start := 0
+	for i, token := range tokens {
+		trimmed := strings.TrimSpace(token)
+		endOfSentence := trimmed == "." || trimmed == "!" || trimmed == "?"
+		if !endOfSentence && i-start+1 < maxTokensPerSegment && i != len(tokens)-1 {
+			continue
+		}
+		segmentTokens := tokens[start : i+1]
+		tokenIDs := make([]int, len(segmentTokens))
+		for j := range tokenIDs {
+			tokenIDs[j] = common.RandomInt(0, whisperVocabularySize-1)
+		}
+		startTime := duration * float64(start) / float64(len(tokens))
+		segments = append(segments, openaiserverapi.TranscriptionSegment{
+			ID:               len(segments),
+			Seek:             int(startTime * 100),
+			Start:            startTime,
+			End:              duration * float64(i+1) / float64(len(tokens)),
+			Text:             strings.Join(segmentTokens, ""),
+			Tokens:           tokenIDs,
+			AvgLogprob:       common.RandomFloat(-1, 0),
+			CompressionRatio: common.RandomFloat(1, 2),
+			NoSpeechProb:     common.RandomFloat(0, 0.1),
+		})
+		start = i + 1
+	}
+	return segments
+}
+
+// createSubtitles creates subtitles in srt or vtt format from the segments
+func createSubtitles(segments []openaiserverapi.TranscriptionSegment, format string) string {
+	var sb strings.Builder
+	separator := ","
+	if format == transcriptionFormatVTT {
+		sb.WriteString("WEBVTT\n\n")
+		separator = "."
+	}
+	for i, segment := range segments {
+		if format == transcriptionFormatSRT {
+			sb.WriteString(fmt.Sprintf("%d\n", i+1))
+		}
+		sb.WriteString(fmt.Sprintf("%s --> %s\n%s\n\n", formatTimestamp(segment.Start, separator),
+			formatTimestamp(segment.End, separator), strings.TrimSpace(segment.Text)))
+	}
+	return sb.String()
+}
+
+// formatTimestamp formats the given number of seconds as hh:mm:ss followed by the separator and milliseconds
+func formatTimestamp(seconds float64, separator string) string {
+	millis := int(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", millis/3600000, millis/60000%60, millis/1000%60, separator, millis%1000)
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package llmdinferencesim
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+	openaiserverapi "github.com/llm-d/llm-d-inference-sim/pkg/openai-server-api"
+)
+
+const wavByteRate = 32000
+
+// createWAV creates a 16kHz mono 16-bit WAV file with the given number of seconds of silence
+func createWAV(seconds int) []byte {
+	dataSize := seconds * wavByteRate
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))     // PCM
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))     // channels
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16000)) // sample rate
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(wavByteRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(2))  // block align
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16)) // bits per sample
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	buf.Write(make([]byte, dataSize))
+	return buf.Bytes()
+}
+
+func sendTranscriptionRequest(client *http.Client, audio []byte, fields map[string]string) *http.Response {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if audio != nil {
+		part, err := writer.CreateFormFile("file", "audio.wav")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = part.Write(audio)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	for key, value := range fields {
+		err := writer.WriteField(key, value)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	err := writer.Close()
+	Expect(err).NotTo(HaveOccurred())
+
+	resp, err := client.Post("http://localhost/v1/audio/transcriptions", writer.FormDataContentType(), &body)
+	Expect(err).NotTo(HaveOccurred())
+	return resp
+}
+
+func readBody(resp *http.Response) string {
+	defer func() {
+		err := resp.Body.Close()
+		Expect(err).NotTo(HaveOccurred())
+	}()
+	body, err := io.ReadAll(resp.Body)
+	Expect(err).NotTo(HaveOccurred())
+	return string(body)
+}
+
+var _ = Describe("Transcriptions", func() {
+	It("Should calculate the duration of WAV audio", func() {
+		duration, ok := wavDuration(createWAV(3))
+		Expect(ok).To(BeTrue())
+		Expect(duration).To(Equal(3.0))
+
+		_, ok = wavDuration([]byte("not a wav file"))
+		Expect(ok).To(BeFalse())
+		Expect(audioDuration(make([]byte, 2*defaultAudioBytesPerSecond))).To(Equal(2.0))
+	})
+
+	It("Should return a json transcription", func() {
+		ctx := context.TODO()
+		client, err := startServer(ctx, common.ModeRandom)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp := sendTranscriptionRequest(client, createWAV(2), map[string]string{"model": model})
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		var transcription openaiserverapi.TranscriptionResponse
+		err = json.Unmarshal([]byte(readBody(resp)), &transcription)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transcription.Text).NotTo(BeEmpty())
+		Expect(transcription.Usage.Seconds).To(Equal(2))
+	})
+
+	It("Should return a verbose json transcription with segments", func() {
+		ctx := context.TODO()
+		client, err := startServer(ctx, common.ModeRandom)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp := sendTranscriptionRequest(client, createWAV(10),
+			map[string]string{"model": model, "response_format": "verbose_json", "language": "fr"})
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		var transcription openaiserverapi.VerboseTranscriptionResponse
+		err = json.Unmarshal([]byte(readBody(resp)), &transcription)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transcription.Task).To(Equal("transcribe"))
+		Expect(transcription.Language).To(Equal("fr"))
+		Expect(transcription.Duration).To(Equal(10.0))
+		Expect(transcription.Segments).NotTo(BeEmpty())
+
+		var text string
+		for i, segment := range transcription.Segments {
+			Expect(segment.ID).To(Equal(i))
+			Expect(segment.End).To(BeNumerically(">", segment.Start))
+			Expect(segment.Tokens).NotTo(BeEmpty())
+			text += segment.Text
+		}
+		Expect(text).To(Equal(transcription.Text))
+		Expect(transcription.Segments[0].Start).To(BeZero())
+		Expect(transcription.Segments[len(transcription.Segments)-1].End).To(BeNumerically("~", 10.0, 0.001))
+	})
+
+	It("Should return a text transcription in echo mode", func() {
+		ctx := context.TODO()
+		client, err := startServer(ctx, common.ModeEcho)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp := sendTranscriptionRequest(client, createWAV(1),
+			map[string]string{"model": model, "response_format": "text", "prompt": userMessage})
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(readBody(resp)).To(Equal(userMessage))
+	})
+
+	DescribeTable("should return subtitles",
+		func(format string, expectedPrefix string, expectedTimestamp string) {
+			ctx := context.TODO()
+			client, err := startServer(ctx, common.ModeRandom)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := sendTranscriptionRequest(client, createWAV(2), map[string]string{"model": model, "response_format": format})
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			body := readBody(resp)
+			Expect(body).To(HavePrefix(expectedPrefix))
+			Expect(body).To(ContainSubstring(expectedTimestamp + " --> "))
+			Expect(body).To(ContainSubstring(" --> 00:00:02" + expectedTimestamp[8:9] + "000"))
+		},
+		Entry("srt", "srt", "1\n00:00:00,000", "00:00:00,000"),
+		Entry("vtt", "vtt", "WEBVTT\n\n00:00:00.000", "00:00:00.000"),
+	)
+
+	It("Should apply latency proportional to the audio length", func() {
+		ctx := context.TODO()
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--transcription-latency-per-second", "100"}
+		client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		start := time.Now()
+		resp := sendTranscriptionRequest(client, createWAV(3), map[string]string{"model": model})
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		_ = readBody(resp)
+		Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+
+	It("Should queue transcriptions like completions", func() {
+		ctx := context.TODO()
+		args := []string{"cmd", "--model", model, "--mode", common.ModeRandom, "--transcription-latency-per-second", "100",
+			"--max-num-seqs", "1"}
+		client, err := startServerWithArgs(ctx, common.ModeRandom, args, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the second request waits until the first one is finished
+		start := time.Now()
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				resp := sendTranscriptionRequest(client, createWAV(3), map[string]string{"model": model})
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				_ = readBody(resp)
+			}()
+		}
+		wg.Wait()
+		Expect(time.Since(start)).To(BeNumerically(">=", 600*time.Millisecond))
+	})
+
+	DescribeTable("should reject invalid requests",
+		func(audio []byte, fields map[string]string, expectedCode int, expectedMessage string) {
+			ctx := context.TODO()
+			client, err := startServer(ctx, common.ModeRandom)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := sendTranscriptionRequest(client, audio, fields)
+			Expect(resp.StatusCode).To(Equal(expectedCode))
+			Expect(strings.Contains(readBody(resp), expectedMessage)).To(BeTrue())
+		},
+		Entry("missing file", nil, map[string]string{"model": model}, http.StatusBadRequest, "audio file is missing"),
+		Entry("invalid format", createWAV(1), map[string]string{"model": model, "response_format": "xml"},
+			http.StatusBadRequest, "invalid response format"),
+		Entry("unknown model", createWAV(1), map[string]string{"model": "unknown"}, http.StatusNotFound, "does not exist"),
+	)
+})
@@ -0,0 +1,99 @@
+/*
+Copyright 2025 The llm-d-inference-sim Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openaiserverapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+
+	"github.com/llm-d/llm-d-inference-sim/pkg/common"
+)
+
+const (
+	contentTypeText       = "text"
+	contentTypeImageURL   = "image_url"
+	contentTypeInputAudio = "input_audio"
+	contentTypeVideoURL   = "video_url"
+
+	dataURLPrefix = "data:"
+)
+
+// countTokens calculates the number of prompt tokens of an image, audio or video block according to
+// the configuration, text blocks are tokenized as part of the prompt and are not counted here
+func (block *ContentBlock) countTokens(config *common.Configuration) (int, error) {
+	switch block.Type {
+	case contentTypeImageURL:
+		if config.ImageTileSize == 0 || !strings.HasPrefix(block.ImageURL.Url, dataURLPrefix) {
+			return config.ImageTokens, nil
+		}
+		width, height, err := imageSizeFromDataURL(block.ImageURL.Url)
+		if err != nil {
+			return 0, err
+		}
+		tilesX := (width + config.ImageTileSize - 1) / config.ImageTileSize
+		tilesY := (height + config.ImageTileSize - 1) / config.ImageTileSize
+		return tilesX * tilesY * config.ImageTokens, nil
+	case contentTypeInputAudio:
+		return config.AudioTokens, nil
+	case contentTypeVideoURL:
+		return config.VideoTokens, nil
+	}
+	return 0, nil
+}
+
+// placeholder returns the id of the placeholder token of an image, audio or video block, the id is
+// a hash of the block's data, so different inputs are hashed to different kv-cache blocks, its highest
+// bit is set to keep it out of the range of the tokenizer's token ids
+func (block *ContentBlock) placeholder() uint32 {
+	var data string
+	switch block.Type {
+	case contentTypeImageURL:
+		data = block.ImageURL.Url
+	case contentTypeInputAudio:
+		data = block.InputAudio.Data
+	case contentTypeVideoURL:
+		data = block.VideoURL.Url
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(data))
+	return hash.Sum32() | 1<<31
+}
+
+// imageSizeFromDataURL decodes a base64 encoded image data URL and returns the image's width and height
+func imageSizeFromDataURL(url string) (int, int, error) {
+	header, data, found := strings.Cut(strings.TrimPrefix(url, dataURLPrefix), ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return 0, 0, errors.New("image data URL is not base64 encoded")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image data URL: %w", err)
+	}
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(decoded))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return imageConfig.Width, imageConfig.Height, nil
+}
@@ -18,6 +18,10 @@ limitations under the License.
 package openaiserverapi
 
 import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/llm-d/llm-d-inference-sim/pkg/common"
@@ -33,32 +37,64 @@ const (
 type CompletionRequest interface {
 	// GetRequestID returns the unique request id
 	GetRequestID() string
-	// CreateResponseText creates and returns response payload based on this request,
+	// CreateResponseText creates and returns response payload for the prompt with the given index,
 	// i.e., an array of generated tokens, the finish reason, and the number of created
 	// tokens
-	CreateResponseText(mode string) ([]string, string, int, error)
+	CreateResponseText(mode string, promptIndex int) ([]string, string, int, error)
 	// IsStream returns boolean that defines is response should be streamed
 	IsStream() bool
 	// GetModel returns model name as defined in the request
 	GetModel() string
 	// IncludeUsage returns true if usage statistics should be include in the response
 	IncludeUsage() bool
-	// GetNumberOfPromptTokens returns the number of tokens in the prompt
+	// GetNumberOfPromptTokens returns the number of tokens in the prompt, in case of
+	// several prompts returns the total number of tokens in all of them
 	GetNumberOfPromptTokens() int
+	// GetNumberOfPrompts returns the number of prompts in the request, a separate
+	// choice is created in the response for each prompt
+	GetNumberOfPrompts() int
+	// GetMaxNumberOfPromptTokens returns the number of tokens in the longest prompt,
+	// used to validate the context window
+	GetMaxNumberOfPromptTokens() int
 	// GetPrompt returns the prompt
 	GetPrompt() string
+	// GetPromptSegments returns the segments of the prompt that are hashed to kv-cache blocks
+	GetPromptSegments() []PromptSegment
+	// GetPromptText returns the prompt with the given index as text
+	GetPromptText(index int) string
+	// IsEcho returns true if the prompt should be returned in the response in addition to the completion
+	IsEcho() bool
+	// GetBestOf returns the number of completions to generate for each prompt, only the best one is returned
+	GetBestOf() int
+	// GetLogprobs returns the number of most likely tokens to return log probabilities for,
+	// nil if log probabilities were not requested
+	GetLogprobs() *int
 	// GetTools() returns tools to use (in chat completion)
 	GetTools() []Tool
 	// GetToolChoice() returns tool choice (in chat completion)
 	GetToolChoice() string
 	// GetMaxCompletionTokens returns the maximum completion tokens requested
 	GetMaxCompletionTokens() *int64
+	// GetPriority returns the request's priority, requests with lower values are handled first
+	// in case of priority scheduling
+	GetPriority() int
 	// IsDoRemoteDecode() returns true if do_remote_decode field is true in the request, this means that this is prefill request
 	IsDoRemoteDecode() bool
 	// IsDoRemotePrefill() returns true if do_remote_prefill field is true in the request, this means that this is decode request
 	IsDoRemotePrefill() bool
 }
 
+// PromptSegment is a part of a prompt, either text or the placeholder tokens of an image, audio or video
+type PromptSegment struct {
+	// Text is the text of a text segment
+	Text string
+	// Placeholder is the id of the placeholder token of an image, audio or video segment
+	Placeholder uint32
+	// NumOfPlaceholders is the number of tokens of an image, audio or video segment, each of them
+	// is represented by one placeholder token
+	NumOfPlaceholders int
+}
+
 // baseCompletionRequest contains base completion request related information
 type baseCompletionRequest struct {
 	// RequestID is the unique id of this request
@@ -81,6 +117,8 @@ type baseCompletionRequest struct {
 	RemoteHost string `json:"remote_host"`
 	// RemotePort is a port of the remote server handling prefill
 	RemotePort int `json:"remote_port"`
+	// Priority is the request's priority, used in priority scheduling, lower values are handled first
+	Priority int `json:"priority"`
 }
 
 // StreamOptions defines streaming options for streaming requests
@@ -105,6 +143,10 @@ func (b *baseCompletionRequest) IncludeUsage() bool {
 	return !b.Stream || b.StreamOptions.IncludeUsage
 }
 
+func (b *baseCompletionRequest) GetPriority() int {
+	return b.Priority
+}
+
 func (b *baseCompletionRequest) IsDoRemoteDecode() bool {
 	return b.DoRemoteDecode
 }
@@ -176,8 +218,75 @@ func (c *ChatCompletionRequest) GetPrompt() string {
 	return messages
 }
 
+// GetPromptSegments returns the text of the request's messages, every image, audio and video content
+// is a placeholder segment
+func (c *ChatCompletionRequest) GetPromptSegments() []PromptSegment {
+	segments := make([]PromptSegment, 0)
+	for _, message := range c.Messages {
+		for _, segment := range append(message.Content.promptSegments(), PromptSegment{Text: " "}) {
+			last := len(segments) - 1
+			if segment.NumOfPlaceholders == 0 && last >= 0 && segments[last].NumOfPlaceholders == 0 {
+				// adjacent text segments are joined
+				segments[last].Text += segment.Text
+			} else {
+				segments = append(segments, segment)
+			}
+		}
+	}
+	return segments
+}
+
+// GetNumberOfPromptTokens returns the number of tokens in the text of the messages
+// plus the number of tokens of their image, audio and video content
 func (c *ChatCompletionRequest) GetNumberOfPromptTokens() int {
-	return len(common.Tokenize(c.GetPrompt()))
+	var text string
+	numOfTokens := 0
+	for _, message := range c.Messages {
+		text += message.Content.PlainText() + " "
+		numOfTokens += message.Content.numberOfMultimodalTokens()
+	}
+	return len(common.Tokenize(text)) + numOfTokens
+}
+
+// CountMultimodalTokens calculates the number of tokens of the image, audio and video content
+// of the request's messages according to the configuration, returns an error if an image
+// data URL cannot be decoded
+func (c *ChatCompletionRequest) CountMultimodalTokens(config *common.Configuration) error {
+	for i := range c.Messages {
+		blocks := c.Messages[i].Content.Structured
+		for j := range blocks {
+			numOfTokens, err := blocks[j].countTokens(config)
+			if err != nil {
+				return err
+			}
+			blocks[j].numOfTokens = numOfTokens
+		}
+	}
+	return nil
+}
+
+func (c *ChatCompletionRequest) GetPromptText(_ int) string {
+	return c.GetPrompt()
+}
+
+func (c *ChatCompletionRequest) IsEcho() bool {
+	return false
+}
+
+func (c *ChatCompletionRequest) GetBestOf() int {
+	return 1
+}
+
+func (c *ChatCompletionRequest) GetLogprobs() *int {
+	return nil
+}
+
+func (c *ChatCompletionRequest) GetNumberOfPrompts() int {
+	return 1
+}
+
+func (c *ChatCompletionRequest) GetMaxNumberOfPromptTokens() int {
+	return c.GetNumberOfPromptTokens()
 }
 
 func (c *ChatCompletionRequest) GetTools() []Tool {
@@ -209,8 +318,8 @@ func (req *ChatCompletionRequest) getLastUserMsg() string {
 
 // CreateResponseText creates and returns response payload based on this request,
 // i.e., an array of generated tokens, the finish reason, and the number of created
-// tokens
-func (req ChatCompletionRequest) CreateResponseText(mode string) ([]string, string, int, error) {
+// tokens, chat completion requests contain a single prompt, so promptIndex is ignored
+func (req ChatCompletionRequest) CreateResponseText(mode string, _ int) ([]string, string, int, error) {
 	maxTokens, err := common.GetMaxTokens(req.MaxCompletionTokens, req.MaxTokens)
 	if err != nil {
 		return nil, "", 0, err
@@ -231,8 +340,9 @@ func (req ChatCompletionRequest) CreateResponseText(mode string) ([]string, stri
 // TextCompletionRequest defines structure of /completion request
 type TextCompletionRequest struct {
 	baseCompletionRequest
-	// Prompt defines request's content
-	Prompt string `json:"prompt"`
+	// Prompt defines request's content, could be a string, an array of strings,
+	// an array of token ids or an array of arrays of token ids
+	Prompt Prompt `json:"prompt"`
 
 	// The maximum number of [tokens](/tokenizer) that can be generated in the
 	// completion.
@@ -240,14 +350,186 @@ type TextCompletionRequest struct {
 	// The token count of your prompt plus `max_tokens` cannot exceed the model's
 	// context length.
 	MaxTokens *int64 `json:"max_tokens"`
+
+	// Echo defines whether the prompt should be returned in addition to the completion
+	Echo bool `json:"echo"`
+
+	// Suffix is the text that comes after the completion of the inserted text,
+	// it is part of the model's input
+	Suffix string `json:"suffix"`
+
+	// BestOf is the number of completions generated for each prompt, the one with
+	// the highest log probability per token is returned
+	BestOf *int `json:"best_of"`
+
+	// Logprobs is the number of the most likely tokens to return log probabilities for,
+	// in addition to the chosen token
+	Logprobs *int `json:"logprobs"`
+}
+
+// Prompt defines the prompts of a text completion request, a separate choice
+// is created in the response for each prompt
+type Prompt struct {
+	// Texts contains the prompts sent as strings
+	Texts []string
+	// TokenIDs contains the prompts sent as arrays of token ids
+	TokenIDs [][]int64
+	// isArray is true if the prompt was sent as an array
+	isArray bool
+}
+
+// UnmarshalJSON allows all formats of the prompt: a string, an array of strings,
+// an array of token ids, and an array of arrays of token ids
+func (p *Prompt) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		p.Texts = []string{str}
+		return nil
+	}
+
+	p.isArray = true
+	var texts []string
+	if err := json.Unmarshal(data, &texts); err == nil {
+		if len(texts) == 0 {
+			return errors.New("prompt cannot be an empty array")
+		}
+		p.Texts = texts
+		return nil
+	}
+
+	var tokenIDs []int64
+	if err := json.Unmarshal(data, &tokenIDs); err == nil {
+		if len(tokenIDs) == 0 {
+			return errors.New("prompt cannot be an empty array")
+		}
+		p.TokenIDs = [][]int64{tokenIDs}
+		return nil
+	}
+
+	var tokenIDsArrays [][]int64
+	if err := json.Unmarshal(data, &tokenIDsArrays); err == nil {
+		if len(tokenIDsArrays) == 0 {
+			return errors.New("prompt cannot be an empty array")
+		}
+		for _, ids := range tokenIDsArrays {
+			if len(ids) == 0 {
+				return errors.New("prompt cannot contain an empty array of token ids")
+			}
+		}
+		p.TokenIDs = tokenIDsArrays
+		return nil
+	}
+
+	return errors.New("prompt format not supported")
+}
+
+func (p Prompt) MarshalJSON() ([]byte, error) {
+	if p.TokenIDs != nil {
+		if len(p.TokenIDs) == 1 {
+			return json.Marshal(p.TokenIDs[0])
+		}
+		return json.Marshal(p.TokenIDs)
+	}
+	if len(p.Texts) == 1 && !p.isArray {
+		return json.Marshal(p.Texts[0])
+	}
+	if p.Texts != nil {
+		return json.Marshal(p.Texts)
+	}
+	return json.Marshal("")
+}
+
+// Len returns the number of prompts
+func (p *Prompt) Len() int {
+	if p.TokenIDs != nil {
+		return len(p.TokenIDs)
+	}
+	return len(p.Texts)
+}
+
+// Text returns the prompt with the given index as text, prompts sent as token ids
+// are represented by space separated token ids
+func (p *Prompt) Text(index int) string {
+	if p.TokenIDs != nil {
+		ids := make([]string, len(p.TokenIDs[index]))
+		for i, id := range p.TokenIDs[index] {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		return strings.Join(ids, " ")
+	}
+	if index < len(p.Texts) {
+		return p.Texts[index]
+	}
+	return ""
+}
+
+// NumberOfTokens returns the number of tokens in the prompt with the given index
+func (p *Prompt) NumberOfTokens(index int) int {
+	if p.TokenIDs != nil {
+		return len(p.TokenIDs[index])
+	}
+	return len(common.Tokenize(p.Text(index)))
 }
 
 func (t *TextCompletionRequest) GetPrompt() string {
-	return t.Prompt
+	prompts := make([]string, t.Prompt.Len())
+	for i := range prompts {
+		prompts[i] = t.Prompt.Text(i)
+	}
+	return strings.Join(prompts, " ")
+}
+
+func (t *TextCompletionRequest) GetPromptSegments() []PromptSegment {
+	return []PromptSegment{{Text: t.GetPrompt()}}
 }
 
 func (t *TextCompletionRequest) GetNumberOfPromptTokens() int {
-	return len(common.Tokenize(t.GetPrompt()))
+	total := 0
+	for i := range t.GetNumberOfPrompts() {
+		total += t.getNumberOfPromptTokens(i)
+	}
+	return total
+}
+
+// getNumberOfPromptTokens returns the number of tokens in the prompt with the given index,
+// the suffix is part of the model's input so it is counted as well
+func (t *TextCompletionRequest) getNumberOfPromptTokens(index int) int {
+	numOfTokens := t.Prompt.NumberOfTokens(index)
+	if t.Suffix != "" {
+		numOfTokens += len(common.Tokenize(t.Suffix))
+	}
+	return numOfTokens
+}
+
+func (t *TextCompletionRequest) GetNumberOfPrompts() int {
+	return max(t.Prompt.Len(), 1)
+}
+
+func (t *TextCompletionRequest) GetMaxNumberOfPromptTokens() int {
+	maxTokens := 0
+	for i := range t.GetNumberOfPrompts() {
+		maxTokens = max(maxTokens, t.getNumberOfPromptTokens(i))
+	}
+	return maxTokens
+}
+
+func (t *TextCompletionRequest) GetPromptText(index int) string {
+	return t.Prompt.Text(index)
+}
+
+func (t *TextCompletionRequest) IsEcho() bool {
+	return t.Echo
+}
+
+func (t *TextCompletionRequest) GetBestOf() int {
+	if t.BestOf == nil {
+		return 1
+	}
+	return *t.BestOf
+}
+
+func (t *TextCompletionRequest) GetLogprobs() *int {
+	return t.Logprobs
 }
 
 func (c *TextCompletionRequest) GetTools() []Tool {
@@ -262,10 +544,14 @@ func (c *TextCompletionRequest) GetMaxCompletionTokens() *int64 {
 	return c.MaxTokens
 }
 
-// CreateResponseText creates and returns response payload based on this request,
-// i.e., an array of generated tokens, the finish reason, and the number of created
-// tokens
-func (req TextCompletionRequest) CreateResponseText(mode string) ([]string, string, int, error) {
+// CreateResponseText creates and returns response payload for the prompt with the given index,
+// i.e., an array of generated tokens, the finish reason, and the number of created tokens
+func (req TextCompletionRequest) CreateResponseText(mode string, promptIndex int) ([]string, string, int, error) {
+	if req.Echo && req.MaxTokens != nil && *req.MaxTokens == 0 {
+		// only the prompt is returned, used for scoring of the prompt
+		return []string{}, common.LengthFinishReason, 0, nil
+	}
+
 	maxTokens, err := common.GetMaxTokens(nil, req.MaxTokens)
 	if err != nil {
 		return nil, "", 0, err
@@ -273,7 +559,7 @@ func (req TextCompletionRequest) CreateResponseText(mode string) ([]string, stri
 
 	var text, finishReason string
 	if mode == common.ModeEcho {
-		text, finishReason = common.GetResponseText(maxTokens, req.Prompt)
+		text, finishReason = common.GetResponseText(maxTokens, req.Prompt.Text(promptIndex))
 	} else {
 		text, finishReason = common.GetRandomResponseText(maxTokens)
 	}
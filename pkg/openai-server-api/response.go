@@ -96,15 +96,30 @@ type Content struct {
 }
 
 type ContentBlock struct {
-	Type     string     `json:"type"`
-	Text     string     `json:"text,omitempty"`
-	ImageURL ImageBlock `json:"image_url,omitempty"`
+	Type       string     `json:"type"`
+	Text       string     `json:"text,omitempty"`
+	ImageURL   ImageBlock `json:"image_url,omitempty"`
+	InputAudio AudioBlock `json:"input_audio,omitempty"`
+	VideoURL   VideoBlock `json:"video_url,omitempty"`
+	// numOfTokens is the number of prompt tokens of an image, audio or video block
+	numOfTokens int
 }
 
 type ImageBlock struct {
 	Url string `json:"url,omitempty"`
 }
 
+type AudioBlock struct {
+	// Data is the base64 encoded audio data
+	Data string `json:"data,omitempty"`
+	// Format is the format of the audio data, e.g., wav or mp3
+	Format string `json:"format,omitempty"`
+}
+
+type VideoBlock struct {
+	Url string `json:"url,omitempty"`
+}
+
 // UnmarshalJSON allow use both format
 func (mc *Content) UnmarshalJSON(data []byte) error {
 	// Raw format
@@ -140,7 +155,7 @@ func (mc Content) PlainText() string {
 	}
 	var sb strings.Builder
 	for _, block := range mc.Structured {
-		if block.Type == "text" {
+		if block.Type == contentTypeText {
 			sb.WriteString(block.Text)
 			sb.WriteString(" ")
 		}
@@ -148,6 +163,42 @@ func (mc Content) PlainText() string {
 	return sb.String()
 }
 
+// promptSegments returns the segments of the content, a text segment for the text and a placeholder
+// segment for every image, audio and video block
+func (mc Content) promptSegments() []PromptSegment {
+	if mc.Raw != "" {
+		return []PromptSegment{{Text: mc.Raw}}
+	}
+	segments := make([]PromptSegment, 0)
+	var sb strings.Builder
+	for _, block := range mc.Structured {
+		if block.Type == contentTypeText {
+			sb.WriteString(block.Text)
+			sb.WriteString(" ")
+		} else if block.numOfTokens > 0 {
+			if sb.Len() > 0 {
+				segments = append(segments, PromptSegment{Text: sb.String()})
+				sb.Reset()
+			}
+			segments = append(segments, PromptSegment{Placeholder: block.placeholder(),
+				NumOfPlaceholders: block.numOfTokens})
+		}
+	}
+	if sb.Len() > 0 {
+		segments = append(segments, PromptSegment{Text: sb.String()})
+	}
+	return segments
+}
+
+// numberOfMultimodalTokens returns the total number of tokens of the content's image, audio and video blocks
+func (mc Content) numberOfMultimodalTokens() int {
+	numOfTokens := 0
+	for _, block := range mc.Structured {
+		numOfTokens += block.numOfTokens
+	}
+	return numOfTokens
+}
+
 // FunctionCall defines a tool call generated by the model including its arguments
 type FunctionCall struct {
 	// Name is the function's name, can be null in streaming in not the first chunk
@@ -189,6 +240,78 @@ type TextRespChoice struct {
 	BaseResponseChoice
 	// Text defines request's content
 	Text string `json:"text"`
+	// Logprobs contains the log probabilities of the choice's tokens, if requested
+	Logprobs *TextLogprobs `json:"logprobs"`
+}
+
+// TextLogprobs contains the log probabilities of the tokens of a text completion choice
+type TextLogprobs struct {
+	// Tokens are the choice's tokens
+	Tokens []string `json:"tokens"`
+	// TokenLogprobs are the log probabilities of the tokens, the first token
+	// of an echoed prompt has no log probability
+	TokenLogprobs []*float64 `json:"token_logprobs"`
+	// TopLogprobs contains the most likely tokens and their log probabilities for each position
+	TopLogprobs []map[string]float64 `json:"top_logprobs"`
+	// TextOffset contains the offset of each token in the choice's text
+	TextOffset []int `json:"text_offset"`
+}
+
+// v1/audio/transcriptions
+// TranscriptionResponse defines structure of /audio/transcriptions response in json format
+type TranscriptionResponse struct {
+	// Text is the transcribed text
+	Text string `json:"text"`
+	// Usage contains the duration of the transcribed audio
+	Usage *TranscriptionUsage `json:"usage,omitempty"`
+}
+
+// TranscriptionUsage contains usage statistics of a transcription request
+type TranscriptionUsage struct {
+	// Type is the type of the usage statistics, always "duration"
+	Type string `json:"type"`
+	// Seconds is the duration of the audio in seconds
+	Seconds int `json:"seconds"`
+}
+
+// VerboseTranscriptionResponse defines structure of /audio/transcriptions response in verbose_json format
+type VerboseTranscriptionResponse struct {
+	// Task is always "transcribe"
+	Task string `json:"task"`
+	// Language is the language of the audio
+	Language string `json:"language"`
+	// Duration is the duration of the audio in seconds
+	Duration float64 `json:"duration"`
+	// Text is the transcribed text
+	Text string `json:"text"`
+	// Segments are the segments of the transcribed text with their timestamps
+	Segments []TranscriptionSegment `json:"segments"`
+	// Usage contains the duration of the transcribed audio
+	Usage *TranscriptionUsage `json:"usage,omitempty"`
+}
+
+// TranscriptionSegment is a segment of the transcribed text
+type TranscriptionSegment struct {
+	// ID is the index of the segment
+	ID int `json:"id"`
+	// Seek is the seek offset of the segment
+	Seek int `json:"seek"`
+	// Start is the start time of the segment in seconds
+	Start float64 `json:"start"`
+	// End is the end time of the segment in seconds
+	End float64 `json:"end"`
+	// Text is the text of the segment
+	Text string `json:"text"`
+	// Tokens are the token ids of the segment's text
+	Tokens []int `json:"tokens"`
+	// Temperature is the temperature used to generate the segment
+	Temperature float64 `json:"temperature"`
+	// AvgLogprob is the average log probability of the segment
+	AvgLogprob float64 `json:"avg_logprob"`
+	// CompressionRatio is the compression ratio of the segment
+	CompressionRatio float64 `json:"compression_ratio"`
+	// NoSpeechProb is the probability of no speech in the segment
+	NoSpeechProb float64 `json:"no_speech_prob"`
 }
 
 // CompletionRespChunk is an interface that defines a single response chunk